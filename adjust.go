@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 	"math"
+	"sort"
 )
 
 // AdjustFunc applies the fn function to each pixel of the img image and returns the adjusted image.
@@ -52,6 +53,81 @@ func AdjustFunc(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGB
 	return dst
 }
 
+// Apply calls the fn function for each pixel of the img image with the pixel coordinates
+// (relative to the image bounds minimum) and color, and returns the image made of the returned
+// colors. The pixels are visited sequentially row by row, so fn may keep state between the calls.
+// Use ApplyColor for the position-independent functions, it processes the rows in parallel.
+//
+// Example:
+//
+//	// a checkerboard overlay
+//	dstImage := imaging.Apply(srcImage, func(x, y int, c color.NRGBA) color.NRGBA {
+//		if (x/8+y/8)%2 == 0 {
+//			c.A /= 2
+//		}
+//		return c
+//	})
+//
+func Apply(img image.Image, fn func(x, y int, c color.NRGBA) color.NRGBA) *image.NRGBA {
+	dst := Clone(img)
+	width := dst.Bounds().Dx()
+	height := dst.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*dst.Stride + x*4
+			c := fn(x, y, color.NRGBA{dst.Pix[i+0], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3]})
+			dst.Pix[i+0] = c.R
+			dst.Pix[i+1] = c.G
+			dst.Pix[i+2] = c.B
+			dst.Pix[i+3] = c.A
+		}
+	}
+
+	return dst
+}
+
+// ApplyColor applies the fn function to each pixel of the img image and returns the result.
+// The rows are processed in parallel, so fn must be safe for concurrent use. It's equivalent to AdjustFunc.
+//
+// Example:
+//
+//	dstImage := imaging.ApplyColor(srcImage, func(c color.NRGBA) color.NRGBA {
+//		c.R, c.B = c.B, c.R
+//		return c
+//	})
+//
+func ApplyColor(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGBA {
+	return AdjustFunc(img, fn)
+}
+
+// AdjustFuncInPlace applies the fn function to each pixel of the dst image, modifying it in place.
+//
+// Example:
+//
+//	imaging.AdjustFuncInPlace(dstImage, func(c color.NRGBA) color.NRGBA {
+//		return color.NRGBA{c.G, c.B, c.R, c.A}
+//	})
+//
+func AdjustFuncInPlace(dst *image.NRGBA, fn func(c color.NRGBA) color.NRGBA) {
+	bounds := dst.Bounds()
+	width := bounds.Dx()
+
+	parallel(bounds.Dy(), func(partStart, partEnd int) {
+		for y := bounds.Min.Y + partStart; y < bounds.Min.Y+partEnd; y++ {
+			i := dst.PixOffset(bounds.Min.X, y)
+			for x := 0; x < width; x++ {
+				c := fn(color.NRGBA{dst.Pix[i+0], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3]})
+				dst.Pix[i+0] = c.R
+				dst.Pix[i+1] = c.G
+				dst.Pix[i+2] = c.B
+				dst.Pix[i+3] = c.A
+				i += 4
+			}
+		}
+	})
+}
+
 // AdjustGamma performs a gamma correction on the image and returns the adjusted image.
 // Gamma parameter must be positive. Gamma = 1.0 gives the original image.
 // Gamma less than 1.0 darkens the image and gamma greater than 1.0 lightens it.
@@ -61,17 +137,108 @@ func AdjustFunc(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGB
 //	dstImage = imaging.AdjustGamma(srcImage, 0.7)
 //
 func AdjustGamma(img image.Image, gamma float64) *image.NRGBA {
-	e := 1.0 / math.Max(gamma, 0.0001)
-	lut := make([]uint8, 256)
+	return AdjustGammaRGB(img, gamma, gamma, gamma)
+}
 
-	for i := 0; i < 256; i++ {
-		lut[i] = clamp(math.Pow(float64(i)/255.0, e) * 255.0)
+// AdjustGammaInPlace performs a gamma correction on the dst image like AdjustGamma,
+// modifying it in place.
+func AdjustGammaInPlace(dst *image.NRGBA, gamma float64) {
+	AdjustFuncInPlace(dst, gammaFunc(gamma, gamma, gamma))
+}
+
+// AdjustGammaRGB performs a gamma correction with separate gamma values for the R, G and B
+// channels of the image and returns the adjusted image. Gamma parameters must be positive
+// (non-positive values are treated as 0.0001). Gamma = 1.0 gives the original channel.
+// The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.AdjustGammaRGB(srcImage, 1.1, 1.0, 0.9)
+//
+func AdjustGammaRGB(img image.Image, gr, gg, gb float64) *image.NRGBA {
+	return AdjustFunc(img, gammaFunc(gr, gg, gb))
+}
+
+func gammaFunc(gr, gg, gb float64) func(c color.NRGBA) color.NRGBA {
+	var luts [3][256]uint8
+	for k, gamma := range []float64{gr, gg, gb} {
+		e := 1.0 / math.Max(gamma, 0.0001)
+		for i := 0; i < 256; i++ {
+			luts[k][i] = clamp(math.Pow(float64(i)/255.0, e) * 255.0)
+		}
 	}
 
-	fn := func(c color.NRGBA) color.NRGBA {
-		return color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A}
+	return func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{luts[0][c.R], luts[1][c.G], luts[2][c.B], c.A}
 	}
+}
+
+// GrayscaleMode is the method used to compute the gray value of a color.
+type GrayscaleMode int
 
+// Grayscale modes.
+const (
+	// GrayscaleAverage uses the average of the R, G and B values.
+	GrayscaleAverage GrayscaleMode = iota
+	// GrayscaleLightness uses the average of the largest and the smallest of the R, G and B values.
+	GrayscaleLightness
+	// GrayscaleLuminosity601 uses the Rec. 601 luma weights (0.299, 0.587, 0.114), same as Grayscale.
+	GrayscaleLuminosity601
+	// GrayscaleLuminosity709 uses the Rec. 709 luma weights (0.2126, 0.7152, 0.0722).
+	GrayscaleLuminosity709
+)
+
+// GrayscaleWithMode produces grayscale version of the image using the specified mode.
+//
+// Example:
+//
+//	dstImage = imaging.GrayscaleWithMode(srcImage, imaging.GrayscaleLuminosity709)
+//
+func GrayscaleWithMode(img image.Image, mode GrayscaleMode) *image.NRGBA {
+	switch mode {
+	case GrayscaleAverage:
+		return GrayscaleWithWeights(img, 1, 1, 1)
+	case GrayscaleLightness:
+		fn := func(c color.NRGBA) color.NRGBA {
+			max, min := c.R, c.R
+			for _, v := range []uint8{c.G, c.B} {
+				if v > max {
+					max = v
+				}
+				if v < min {
+					min = v
+				}
+			}
+			y := uint8((int(max) + int(min) + 1) / 2)
+			return color.NRGBA{y, y, y, c.A}
+		}
+		return AdjustFunc(img, fn)
+	case GrayscaleLuminosity709:
+		return GrayscaleWithWeights(img, 0.2126, 0.7152, 0.0722)
+	}
+	return Grayscale(img)
+}
+
+// GrayscaleWithWeights produces grayscale version of the image using the specified weights of
+// the R, G and B channels. The weights are normalized so that their sum is 1. Negative weights
+// are treated as zero; if all the weights are zero, the Rec. 601 weights are used (like Grayscale).
+//
+// Example:
+//
+//	dstImage = imaging.GrayscaleWithWeights(srcImage, 0.2126, 0.7152, 0.0722)
+//
+func GrayscaleWithWeights(img image.Image, wr, wg, wb float64) *image.NRGBA {
+	wr, wg, wb = math.Max(wr, 0), math.Max(wg, 0), math.Max(wb, 0)
+	sum := wr + wg + wb
+	if sum <= 0 {
+		return Grayscale(img)
+	}
+	wr, wg, wb = wr/sum, wg/sum, wb/sum
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		y := clamp(wr*float64(c.R) + wg*float64(c.G) + wb*float64(c.B))
+		return color.NRGBA{y, y, y, c.A}
+	}
 	return AdjustFunc(img, fn)
 }
 
@@ -135,6 +302,16 @@ func AdjustSigmoid(img image.Image, midpoint, factor float64) *image.NRGBA {
 //	dstImage = imaging.AdjustContrast(srcImage, 20) // increase image contrast by 20%
 //
 func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
+	return AdjustFunc(img, contrastFunc(percentage))
+}
+
+// AdjustContrastInPlace changes the contrast of the dst image like AdjustContrast,
+// modifying it in place.
+func AdjustContrastInPlace(dst *image.NRGBA, percentage float64) {
+	AdjustFuncInPlace(dst, contrastFunc(percentage))
+}
+
+func contrastFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -149,11 +326,9 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 		}
 	}
 
-	fn := func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
 		return color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A}
 	}
-
-	return AdjustFunc(img, fn)
 }
 
 // AdjustBrightness changes the brightness of the image using the percentage parameter and returns the adjusted image.
@@ -166,6 +341,16 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 //	dstImage = imaging.AdjustBrightness(srcImage, 10) // increase image brightness by 10%
 //
 func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
+	return AdjustFunc(img, brightnessFunc(percentage))
+}
+
+// AdjustBrightnessInPlace changes the brightness of the dst image like AdjustBrightness,
+// modifying it in place.
+func AdjustBrightnessInPlace(dst *image.NRGBA, percentage float64) {
+	AdjustFuncInPlace(dst, brightnessFunc(percentage))
+}
+
+func brightnessFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -174,27 +359,877 @@ func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
 		lut[i] = clamp(float64(i) + shift)
 	}
 
-	fn := func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
 		return color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A}
 	}
-
-	return AdjustFunc(img, fn)
 }
 
 // Grayscale produces grayscale version of the image.
 func Grayscale(img image.Image) *image.NRGBA {
-	fn := func(c color.NRGBA) color.NRGBA {
-		f := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
-		y := uint8(f + 0.5)
+	return AdjustFunc(img, grayscaleFunc())
+}
+
+// GrayscaleInPlace converts the dst image to grayscale like Grayscale, modifying it in place.
+func GrayscaleInPlace(dst *image.NRGBA) {
+	AdjustFuncInPlace(dst, grayscaleFunc())
+}
+
+func grayscaleFunc() func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
+		y := luminance(c)
 		return color.NRGBA{y, y, y, c.A}
 	}
-	return AdjustFunc(img, fn)
 }
 
 // Invert produces inverted (negated) version of the image.
 func Invert(img image.Image) *image.NRGBA {
+	return InvertChannels(img, true, true, true, false)
+}
+
+// InvertInPlace inverts the dst image like Invert, modifying it in place.
+func InvertInPlace(dst *image.NRGBA) {
+	AdjustFuncInPlace(dst, invertFunc(true, true, true, false))
+}
+
+// Solarize produces a solarized version of the image: each color channel value that is
+// greater than the threshold is inverted. The threshold must be in range (0, 1).
+// Threshold = 0 gives almost fully inverted image, threshold = 1 gives the original image.
+// The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.Solarize(srcImage, 0.5)
+//
+func Solarize(img image.Image, threshold float64) *image.NRGBA {
+	threshold = math.Min(math.Max(threshold, 0.0), 1.0)
+	level := threshold * 255.0
+
+	lut := make([]uint8, 256)
+	for i := 0; i < 256; i++ {
+		if float64(i) > level {
+			lut[i] = 255 - uint8(i)
+		} else {
+			lut[i] = uint8(i)
+		}
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A}
+	}
+	return AdjustFunc(img, fn)
+}
+
+// InvertChannels produces a version of the image with the selected channels inverted.
+//
+// Example:
+//
+//	dstImage = imaging.InvertChannels(maskImage, false, false, false, true) // invert the alpha channel
+//
+func InvertChannels(img image.Image, r, g, b, a bool) *image.NRGBA {
+	return AdjustFunc(img, invertFunc(r, g, b, a))
+}
+
+func invertFunc(r, g, b, a bool) func(c color.NRGBA) color.NRGBA {
+	var masks [4]uint8
+	for k, invert := range []bool{r, g, b, a} {
+		if invert {
+			masks[k] = 0xff
+		}
+	}
+
+	return func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{c.R ^ masks[0], c.G ^ masks[1], c.B ^ masks[2], c.A ^ masks[3]}
+	}
+}
+
+// Sepia produces a sepia-toned version of the image using the percentage parameter and returns the adjusted image.
+// The percentage must be in range (0, 100). The percentage = 0 gives the original image.
+// The percentage = 100 gives the full sepia tone.
+//
+// Example:
+//
+//	dstImage = imaging.Sepia(srcImage, 80)
+//
+func Sepia(img image.Image, percentage float64) *image.NRGBA {
+	percentage = math.Min(math.Max(percentage, 0.0), 100.0)
+	if percentage == 0 {
+		return Clone(img)
+	}
+
+	p := percentage / 100.0
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		r := float64(c.R)
+		g := float64(c.G)
+		b := float64(c.B)
+		sr := 0.393*r + 0.769*g + 0.189*b
+		sg := 0.349*r + 0.686*g + 0.168*b
+		sb := 0.272*r + 0.534*g + 0.131*b
+		return color.NRGBA{
+			clamp(r + (sr-r)*p),
+			clamp(g + (sg-g)*p),
+			clamp(b + (sb-b)*p),
+			c.A,
+		}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// AdjustSaturation changes the saturation of the image using the percentage parameter and returns the adjusted image.
+// The percentage must be in range (-100, 100). The percentage = 0 gives the original image.
+// The percentage = -100 gives the same result as Grayscale.
+//
+// Examples:
+//
+//	dstImage = imaging.AdjustSaturation(srcImage, 25) // increase image saturation by 25%
+//	dstImage = imaging.AdjustSaturation(srcImage, -10) // decrease image saturation by 10%
+//
+func AdjustSaturation(img image.Image, percentage float64) *image.NRGBA {
+	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
+	if percentage == 0 {
+		return Clone(img)
+	}
+
+	v := (100.0 + percentage) / 100.0
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		y := luma(c.R, c.G, c.B)
+		r := clamp(y + (float64(c.R)-y)*v)
+		g := clamp(y + (float64(c.G)-y)*v)
+		b := clamp(y + (float64(c.B)-y)*v)
+		return color.NRGBA{r, g, b, c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// AdjustHue changes the hue of the image by rotating it by the specified angle in degrees
+// and returns the adjusted image. The angle = 0 (or any multiple of 360) gives the original image.
+//
+// Example:
+//
+//	dstImage = imaging.AdjustHue(srcImage, 90) // rotate the hue by 90 degrees
+//
+func AdjustHue(img image.Image, degrees float64) *image.NRGBA {
+	shift := math.Mod(degrees, 360.0) / 360.0
+	if shift == 0 {
+		return Clone(img)
+	}
+
 	fn := func(c color.NRGBA) color.NRGBA {
-		return color.NRGBA{255 - c.R, 255 - c.G, 255 - c.B, c.A}
+		h, s, l := rgbToHSL(c.R, c.G, c.B)
+		h += shift
+		if h < 0 {
+			h++
+		} else if h >= 1 {
+			h--
+		}
+		r, g, b := hslToRGB(h, s, l)
+		return color.NRGBA{r, g, b, c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// rgbToHSL converts the RGB color to hue, saturation and lightness, all in range 0..1.
+func rgbToHSL(r, g, b uint8) (h, s, l float64) {
+	rr := float64(r) / 255.0
+	gg := float64(g) / 255.0
+	bb := float64(b) / 255.0
+
+	max := math.Max(rr, math.Max(gg, bb))
+	min := math.Min(rr, math.Min(gg, bb))
+	l = (max + min) / 2
+
+	if max == min {
+		return 0, 0, l
+	}
+
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+
+	switch max {
+	case rr:
+		h = (gg - bb) / d
+		if gg < bb {
+			h += 6
+		}
+	case gg:
+		h = (bb-rr)/d + 2
+	default:
+		h = (rr-gg)/d + 4
+	}
+	h /= 6
+
+	return h, s, l
+}
+
+// hslToRGB converts the hue, saturation and lightness (all in range 0..1) to the RGB color.
+func hslToRGB(h, s, l float64) (r, g, b uint8) {
+	if s == 0 {
+		v := clamp(l * 255.0)
+		return v, v, v
+	}
+
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	r = clamp(hueToRGB(p, q, h+1.0/3.0) * 255.0)
+	g = clamp(hueToRGB(p, q, h) * 255.0)
+	b = clamp(hueToRGB(p, q, h-1.0/3.0) * 255.0)
+	return r, g, b
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t++
+	}
+	if t > 1 {
+		t--
+	}
+	if t < 1.0/6.0 {
+		return p + (q-p)*6*t
+	}
+	if t < 0.5 {
+		return q
+	}
+	if t < 2.0/3.0 {
+		return p + (q-p)*(2.0/3.0-t)*6
+	}
+	return p
+}
+
+// AdjustRGB multiplies the R, G and B channels of the image by the specified scale factors
+// and returns the adjusted image. The results are clamped to 255, negative factors are treated
+// as zero. The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.AdjustRGB(srcImage, 1.1, 1.0, 0.9) // warmer image
+//
+func AdjustRGB(img image.Image, rScale, gScale, bScale float64) *image.NRGBA {
+	var luts [3][256]uint8
+	for k, scale := range []float64{rScale, gScale, bScale} {
+		scale = math.Max(scale, 0.0)
+		for i := 0; i < 256; i++ {
+			luts[k][i] = clamp(float64(i) * scale)
+		}
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{luts[0][c.R], luts[1][c.G], luts[2][c.B], c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// AdjustTemperature changes the color temperature of the image and returns the adjusted image.
+// The kelvin parameter is the target correlated color temperature, it must be in range (1000, 40000).
+// The neutral point is 6500K which gives the original image. Lower values give warmer (more
+// red and less blue) images, higher values give cooler (more blue and less red) images.
+// The R and B channels are scaled relative to the G channel using an approximation of the
+// black-body radiation colors. The alpha channel is preserved.
+//
+// Examples:
+//
+//	dstImage = imaging.AdjustTemperature(srcImage, 4000) // warmer image
+//	dstImage = imaging.AdjustTemperature(srcImage, 9000) // cooler image
+//
+func AdjustTemperature(img image.Image, kelvin float64) *image.NRGBA {
+	kelvin = math.Min(math.Max(kelvin, 1000.0), 40000.0)
+	r, g, b := blackBodyRGB(kelvin)
+	r0, g0, b0 := blackBodyRGB(6500.0)
+	return AdjustRGB(img, (r/g)/(r0/g0), 1.0, (b/g)/(b0/g0))
+}
+
+// blackBodyRGB returns the approximate sRGB color (0..255) of the black body at the specified
+// temperature (from 1000K to 40000K), using the curve fit by Tanner Helland.
+func blackBodyRGB(kelvin float64) (r, g, b float64) {
+	t := kelvin / 100.0
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	r = math.Min(math.Max(r, 0.0), 255.0)
+	g = math.Min(math.Max(g, 0.0), 255.0)
+	b = math.Min(math.Max(b, 0.0), 255.0)
+	return r, g, b
+}
+
+// WhiteBalanceMode is the method used by AutoWhiteBalanceWithMode to estimate the color cast.
+type WhiteBalanceMode int
+
+// White balance modes.
+const (
+	// WhiteBalanceGrayWorld assumes that the average color of the image is neutral gray.
+	WhiteBalanceGrayWorld WhiteBalanceMode = iota
+	// WhiteBalanceWhitePatch assumes that the brightest values of each channel are white.
+	WhiteBalanceWhitePatch
+)
+
+// AutoWhiteBalance removes the color cast of the image using the gray world assumption
+// and returns the adjusted image. The R, G and B channels are scaled so that their means
+// are equal. Fully transparent pixels are ignored. The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.AutoWhiteBalance(srcImage)
+//
+func AutoWhiteBalance(img image.Image) *image.NRGBA {
+	return AutoWhiteBalanceWithMode(img, WhiteBalanceGrayWorld)
+}
+
+// AutoWhiteBalanceWithMode removes the color cast of the image using the specified method
+// and returns the adjusted image. With WhiteBalanceGrayWorld the R, G and B channels are scaled
+// so that their means are equal, with WhiteBalanceWhitePatch they are scaled so that their
+// maximum values become 255. Fully transparent pixels are ignored. The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.AutoWhiteBalanceWithMode(srcImage, imaging.WhiteBalanceWhitePatch)
+//
+func AutoWhiteBalanceWithMode(img image.Image, mode WhiteBalanceMode) *image.NRGBA {
+	src := toNRGBA(img)
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+
+	var sum, max [3]float64
+	n := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			if src.Pix[i+3] == 0 {
+				continue
+			}
+			n++
+			for k := 0; k < 3; k++ {
+				v := float64(src.Pix[i+k])
+				sum[k] += v
+				max[k] = math.Max(max[k], v)
+			}
+		}
+	}
+	if n == 0 {
+		return Clone(img)
+	}
+
+	scales := [3]float64{1, 1, 1}
+	switch mode {
+	case WhiteBalanceWhitePatch:
+		for k := 0; k < 3; k++ {
+			if max[k] > 0 {
+				scales[k] = 255.0 / max[k]
+			}
+		}
+	default:
+		gray := (sum[0] + sum[1] + sum[2]) / 3
+		for k := 0; k < 3; k++ {
+			if sum[k] > 0 {
+				scales[k] = gray / sum[k]
+			}
+		}
+	}
+
+	return AdjustRGB(src, scales[0], scales[1], scales[2])
+}
+
+// ChannelMixer applies the 3x3 color matrix to the R, G and B channels of the image
+// and returns the adjusted image. Each row of the matrix contains the weights of the input
+// R, G and B values in the corresponding output channel. The results are clamped to 0..255.
+// The alpha channel is preserved.
+//
+// Example:
+//
+//	// swap the red and blue channels
+//	dstImage = imaging.ChannelMixer(srcImage, [3][3]float64{
+//		{0, 0, 1},
+//		{0, 1, 0},
+//		{1, 0, 0},
+//	})
+//
+func ChannelMixer(img image.Image, matrix [3][3]float64) *image.NRGBA {
+	fn := func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return color.NRGBA{
+			clamp(matrix[0][0]*r + matrix[0][1]*g + matrix[0][2]*b),
+			clamp(matrix[1][0]*r + matrix[1][1]*g + matrix[1][2]*b),
+			clamp(matrix[2][0]*r + matrix[2][1]*g + matrix[2][2]*b),
+			c.A,
+		}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// Colorize maps the luminance of the image onto the gradient between the shadow and highlight
+// colors (a duotone) and returns the adjusted image. Black pixels get the shadow color, white pixels
+// get the highlight color, other pixels get the colors linearly interpolated by their luminance.
+// The alpha channel of the image is preserved, the alpha of the colors is ignored.
+//
+// Example:
+//
+//	dstImage = imaging.Colorize(srcImage, color.NRGBA{20, 30, 90, 255}, color.NRGBA{250, 220, 120, 255})
+//
+func Colorize(img image.Image, shadow, highlight color.Color) *image.NRGBA {
+	c0 := color.NRGBAModel.Convert(shadow).(color.NRGBA)
+	c1 := color.NRGBAModel.Convert(highlight).(color.NRGBA)
+
+	var lut [256][3]uint8
+	for i := 0; i < 256; i++ {
+		t := float64(i) / 255.0
+		lut[i][0] = clamp(float64(c0.R) + (float64(c1.R)-float64(c0.R))*t)
+		lut[i][1] = clamp(float64(c0.G) + (float64(c1.G)-float64(c0.G))*t)
+		lut[i][2] = clamp(float64(c0.B) + (float64(c1.B)-float64(c0.B))*t)
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		v := lut[luminance(c)]
+		return color.NRGBA{v[0], v[1], v[2], c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// Posterize reduces the number of tonal levels of each color channel of the image
+// and returns the adjusted image. The levels parameter must be in range (2, 256).
+// Levels = 256 gives the original image. The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.Posterize(srcImage, 4)
+//
+func Posterize(img image.Image, levels int) *image.NRGBA {
+	if levels < 2 {
+		levels = 2
+	}
+	if levels > 256 {
+		levels = 256
+	}
+
+	n := float64(levels - 1)
+	lut := make([]uint8, 256)
+	for i := 0; i < 256; i++ {
+		lut[i] = clamp(math.Floor(float64(i)/255.0*n+0.5) / n * 255.0)
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// luminance returns the Rec. 601 luma of the color rounded to uint8, as used by Grayscale.
+func luminance(c color.NRGBA) uint8 {
+	return uint8(luma(c.R, c.G, c.B) + 0.5)
+}
+
+// luma returns the Rec. 601 luma of the color channels in range 0..255.
+func luma(r, g, b uint8) float64 {
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
+
+// Histogram returns the histogram of the image. The first three arrays contain the pixel counts
+// for each value of the red, green and blue channels, the fourth one contains the pixel counts
+// for each luminance value.
+//
+// Example:
+//
+//	hist := imaging.Histogram(srcImage)
+//	redCount := hist[0][255]
+//
+func Histogram(img image.Image) [4][256]int {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	var hist [4][256]int
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			c := color.NRGBA{src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+			hist[0][c.R]++
+			hist[1][c.G]++
+			hist[2][c.B]++
+			hist[3][luminance(c)]++
+		}
+	}
+	return hist
+}
+
+// HistogramNormalized returns the histogram of the image like Histogram does, with each pixel count
+// divided by the total number of pixels.
+func HistogramNormalized(img image.Image) [4][256]float64 {
+	hist := Histogram(img)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+
+	var norm [4][256]float64
+	if total == 0 {
+		return norm
+	}
+	for c := 0; c < 4; c++ {
+		for i, n := range hist[c] {
+			norm[c][i] = float64(n) / float64(total)
+		}
+	}
+	return norm
+}
+
+type colorBucket struct {
+	index   int
+	count   int
+	r, g, b int
+}
+
+type colorBuckets []colorBucket
+
+func (s colorBuckets) Len() int      { return len(s) }
+func (s colorBuckets) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s colorBuckets) Less(i, j int) bool {
+	if s[i].count != s[j].count {
+		return s[i].count > s[j].count
+	}
+	return s[i].index < s[j].index
+}
+
+// DominantColors returns up to n most frequent colors of the image sorted by frequency.
+// Pixels are grouped into buckets using the 4 most significant bits of each color channel
+// and the average color of each of the most populated buckets is returned.
+// Fully transparent pixels are ignored.
+//
+// Example:
+//
+//	palette := imaging.DominantColors(srcImage, 5)
+//
+func DominantColors(img image.Image, n int) []color.NRGBA {
+	if n <= 0 {
+		return nil
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	buckets := make(colorBuckets, 4096)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			if src.Pix[i+3] == 0 {
+				continue
+			}
+			r, g, b := src.Pix[i+0], src.Pix[i+1], src.Pix[i+2]
+			k := int(r>>4)<<8 | int(g>>4)<<4 | int(b>>4)
+			buckets[k].count++
+			buckets[k].r += int(r)
+			buckets[k].g += int(g)
+			buckets[k].b += int(b)
+		}
+	}
+	for k := range buckets {
+		buckets[k].index = k
+	}
+	sort.Sort(buckets)
+
+	var colors []color.NRGBA
+	for _, b := range buckets {
+		if len(colors) == n || b.count == 0 {
+			break
+		}
+		cnt := float64(b.count)
+		colors = append(colors, color.NRGBA{
+			clamp(float64(b.r) / cnt),
+			clamp(float64(b.g) / cnt),
+			clamp(float64(b.b) / cnt),
+			255,
+		})
+	}
+	return colors
+}
+
+// UniqueColors returns the number of distinct colors (including the alpha channel) in the image.
+//
+// Example:
+//
+//	if imaging.UniqueColors(srcImage) <= 256 {
+//		// the image can be saved as a paletted image without losing colors
+//	}
+//
+func UniqueColors(img image.Image) int {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	seen := make(map[uint32]struct{})
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			seen[uint32(src.Pix[i+0])<<24|uint32(src.Pix[i+1])<<16|uint32(src.Pix[i+2])<<8|uint32(src.Pix[i+3])] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// ColorCount returns the number of pixels of each distinct color (including the alpha channel) in the image.
+//
+// Example:
+//
+//	counts := imaging.ColorCount(srcImage)
+//	whitePixels := counts[color.NRGBA{255, 255, 255, 255}]
+//
+func ColorCount(img image.Image) map[color.NRGBA]int {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	counts := make(map[uint32]int)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			counts[uint32(src.Pix[i+0])<<24|uint32(src.Pix[i+1])<<16|uint32(src.Pix[i+2])<<8|uint32(src.Pix[i+3])]++
+		}
+	}
+
+	result := make(map[color.NRGBA]int, len(counts))
+	for k, n := range counts {
+		result[color.NRGBA{uint8(k >> 24), uint8(k >> 16), uint8(k >> 8), uint8(k)}] = n
+	}
+	return result
+}
+
+// AverageColor returns the average color of the image. The color channels are weighted
+// by the alpha channel, so transparent pixels don't affect the resulting color.
+func AverageColor(img image.Image) color.NRGBA {
+	return AverageColorRect(img, img.Bounds())
+}
+
+// AverageColorRect returns the average color of the rectangular region of the image like
+// AverageColor does. If the region doesn't overlap the image, a transparent color is returned.
+//
+// Example:
+//
+//	c := imaging.AverageColorRect(srcImage, image.Rect(0, 0, 10, 10))
+//
+func AverageColorRect(img image.Image, rect image.Rectangle) color.NRGBA {
+	srcBounds := img.Bounds()
+	r := rect.Intersect(srcBounds).Sub(srcBounds.Min)
+	if r.Empty() {
+		return color.NRGBA{}
+	}
+
+	src := toNRGBA(img)
+	var sumR, sumG, sumB, sumA float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := y*src.Stride + x*4
+			a := float64(src.Pix[i+3])
+			sumR += float64(src.Pix[i+0]) * a
+			sumG += float64(src.Pix[i+1]) * a
+			sumB += float64(src.Pix[i+2]) * a
+			sumA += a
+		}
+	}
+	if sumA == 0 {
+		return color.NRGBA{}
+	}
+
+	n := float64(r.Dx() * r.Dy())
+	return color.NRGBA{
+		clamp(sumR / sumA),
+		clamp(sumG / sumA),
+		clamp(sumB / sumA),
+		clamp(sumA / n),
+	}
+}
+
+// Threshold converts the image to black and white using the specified luminance level
+// and returns the adjusted image. The level must be in range (0, 1). Pixels with the luminance
+// greater than or equal to the level become white, other pixels become black.
+// The alpha channel is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.Threshold(srcImage, 0.5)
+//
+func Threshold(img image.Image, level float64) *image.NRGBA {
+	level = math.Min(math.Max(level, 0.0), 1.0)
+	return threshold(img, level*255.0)
+}
+
+// ThresholdOtsu converts the image to black and white like Threshold does, using the luminance
+// level computed automatically from the image histogram with the Otsu's method, and returns
+// the adjusted image.
+func ThresholdOtsu(img image.Image) *image.NRGBA {
+	hist := Histogram(img)[3]
+
+	total := 0
+	sum := 0.0
+	for i, n := range hist {
+		total += n
+		sum += float64(i * n)
+	}
+
+	// find the level maximizing the variance between the two classes
+	best, bestVar := 0, -1.0
+	n0 := 0
+	sum0 := 0.0
+	for t := 1; t < 256; t++ {
+		n0 += hist[t-1]
+		sum0 += float64((t - 1) * hist[t-1])
+		n1 := total - n0
+		if n0 == 0 || n1 == 0 {
+			continue
+		}
+		m0 := sum0 / float64(n0)
+		m1 := (sum - sum0) / float64(n1)
+		v := float64(n0) * float64(n1) * (m0 - m1) * (m0 - m1)
+		if v > bestVar {
+			best, bestVar = t, v
+		}
+	}
+
+	return threshold(img, float64(best))
+}
+
+// EqualizeHistogram performs a histogram equalization of the image luminance and returns
+// the adjusted image. Only the luminance is remapped, so the hue of the colors is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.EqualizeHistogram(srcImage)
+//
+func EqualizeHistogram(img image.Image) *image.NRGBA {
+	hist := Histogram(img)[3]
+
+	cdf := make([]int, 256)
+	total := 0
+	for i, n := range hist {
+		total += n
+		cdf[i] = total
+	}
+
+	cdfMin := 0
+	for _, c := range cdf {
+		if c > 0 {
+			cdfMin = c
+			break
+		}
+	}
+	if total == cdfMin {
+		return Clone(img)
+	}
+
+	lut := make([]float64, 256)
+	for i := 0; i < 256; i++ {
+		lut[i] = float64(cdf[i]-cdfMin) / float64(total-cdfMin) * 255.0
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		delta := lut[luminance(c)] - luma(c.R, c.G, c.B)
+		r := clamp(float64(c.R) + delta)
+		g := clamp(float64(c.G) + delta)
+		b := clamp(float64(c.B) + delta)
+		return color.NRGBA{r, g, b, c.A}
+	}
+
+	return AdjustFunc(img, fn)
+}
+
+// AutoContrast stretches the luminance range of the image to the full black to white range
+// and returns the adjusted image. The clip parameter is the fraction of the darkest and
+// the brightest pixels that are ignored when the range is computed, it must be in range (0, 0.5).
+// If perChannel is true, the range of each color channel is stretched independently.
+//
+// Examples:
+//
+//	dstImage = imaging.AutoContrast(srcImage, 0.01, false)
+//	dstImage = imaging.AutoContrast(srcImage, 0.005, true)
+//
+func AutoContrast(img image.Image, clip float64, perChannel bool) *image.NRGBA {
+	src := toNRGBA(img)
+	clip = math.Min(math.Max(clip, 0.0), 0.499)
+
+	hist := Histogram(src)
+	var luts [3][]uint8
+	if perChannel {
+		for c := 0; c < 3; c++ {
+			luts[c] = stretchLUT(hist[c], clip)
+		}
+	} else {
+		lut := stretchLUT(hist[3], clip)
+		luts = [3][]uint8{lut, lut, lut}
+	}
+
+	fn := func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{luts[0][c.R], luts[1][c.G], luts[2][c.B], c.A}
+	}
+
+	return AdjustFunc(src, fn)
+}
+
+// stretchLUT returns the lookup table linearly mapping the histogram range between
+// the clip and 1-clip percentiles to the full (0, 255) range.
+func stretchLUT(hist [256]int, clip float64) []uint8 {
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	limit := int(clip * float64(total))
+
+	lo, cnt := 0, 0
+	for lo < 255 {
+		cnt += hist[lo]
+		if cnt > limit {
+			break
+		}
+		lo++
+	}
+	hi, cnt := 255, 0
+	for hi > 0 {
+		cnt += hist[hi]
+		if cnt > limit {
+			break
+		}
+		hi--
+	}
+
+	lut := make([]uint8, 256)
+	for i := 0; i < 256; i++ {
+		if hi <= lo {
+			lut[i] = uint8(i)
+			continue
+		}
+		lut[i] = clamp(float64(i-lo) * 255.0 / float64(hi-lo))
+	}
+	return lut
+}
+
+func threshold(img image.Image, cutoff float64) *image.NRGBA {
+	fn := func(c color.NRGBA) color.NRGBA {
+		if float64(luminance(c)) >= cutoff {
+			return color.NRGBA{255, 255, 255, c.A}
+		}
+		return color.NRGBA{0, 0, 0, c.A}
 	}
 	return AdjustFunc(img, fn)
 }
@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -42,6 +44,176 @@ func TestGrayscale(t *testing.T) {
 	}
 }
 
+func TestApply(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+		},
+	}
+
+	n := 0
+	got := Apply(src, func(x, y int, c color.NRGBA) color.NRGBA {
+		n++
+		return color.NRGBA{uint8(x), uint8(y), uint8(n), c.R}
+	})
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x01, 0x01, 0x01, 0x00, 0x02, 0x05,
+			0x00, 0x01, 0x03, 0x09, 0x01, 0x01, 0x04, 0x0d,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [Apply] failed: %#v", got)
+	}
+
+	got = ApplyColor(src, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{c.A, c.B, c.G, c.R}
+	})
+	want = &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x04, 0x03, 0x02, 0x01, 0x08, 0x07, 0x06, 0x05,
+			0x0c, 0x0b, 0x0a, 0x09, 0x10, 0x0f, 0x0e, 0x0d,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [ApplyColor] failed: %#v", got)
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	newSrc := func() *image.NRGBA {
+		return &image.NRGBA{
+			Rect:   image.Rect(-1, -1, 2, 1),
+			Stride: 3 * 4,
+			Pix: []uint8{
+				0xcc, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xcc, 0x03,
+				0x11, 0x22, 0x33, 0xff, 0x33, 0x22, 0x11, 0xff, 0xaa, 0x33, 0xbb, 0xff,
+			},
+		}
+	}
+	td := []struct {
+		desc    string
+		inPlace func(dst *image.NRGBA)
+		alloc   func(img image.Image) *image.NRGBA
+	}{
+		{
+			"AdjustBrightnessInPlace",
+			func(dst *image.NRGBA) { AdjustBrightnessInPlace(dst, 10) },
+			func(img image.Image) *image.NRGBA { return AdjustBrightness(img, 10) },
+		},
+		{
+			"AdjustContrastInPlace",
+			func(dst *image.NRGBA) { AdjustContrastInPlace(dst, -20) },
+			func(img image.Image) *image.NRGBA { return AdjustContrast(img, -20) },
+		},
+		{
+			"AdjustGammaInPlace",
+			func(dst *image.NRGBA) { AdjustGammaInPlace(dst, 1.5) },
+			func(img image.Image) *image.NRGBA { return AdjustGamma(img, 1.5) },
+		},
+		{
+			"InvertInPlace",
+			InvertInPlace,
+			Invert,
+		},
+		{
+			"GrayscaleInPlace",
+			GrayscaleInPlace,
+			Grayscale,
+		},
+	}
+	for _, d := range td {
+		want := d.alloc(newSrc())
+		got := newSrc()
+		d.inPlace(got)
+		got.Rect = got.Rect.Sub(got.Rect.Min)
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+
+		// only the sub-image pixels are modified
+		src := newSrc()
+		d.inPlace(src.SubImage(image.Rect(0, 0, 2, 1)).(*image.NRGBA))
+		full := d.alloc(newSrc())
+		want = newSrc()
+		want.Rect = want.Rect.Sub(want.Rect.Min)
+		copy(want.Pix[16:24], full.Pix[16:24])
+		src.Rect = src.Rect.Sub(src.Rect.Min)
+		if !compareNRGBA(src, want, 0) {
+			t.Errorf("test [%s sub-image] failed: %#v", d.desc, src)
+		}
+	}
+}
+
+func TestGrayscaleWithMode(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xcc, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xcc, 0x03,
+			0x11, 0x22, 0x33, 0xff, 0x33, 0x22, 0x11, 0xff, 0xaa, 0x33, 0xbb, 0xff,
+			0x00, 0x00, 0x00, 0xff, 0x33, 0x33, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	gray := func(vs ...uint8) []uint8 {
+		alphas := []uint8{0x01, 0x02, 0x03, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		var pix []uint8
+		for i, v := range vs {
+			pix = append(pix, v, v, v, alphas[i])
+		}
+		return pix
+	}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want []uint8
+	}{
+		{
+			"GrayscaleWithMode average",
+			GrayscaleWithMode(src, GrayscaleAverage),
+			gray(0x44, 0x44, 0x44, 0x22, 0x22, 0x88, 0x00, 0x33, 0xff),
+		},
+		{
+			"GrayscaleWithMode lightness",
+			GrayscaleWithMode(src, GrayscaleLightness),
+			gray(0x66, 0x66, 0x66, 0x22, 0x22, 0x77, 0x00, 0x33, 0xff),
+		},
+		{
+			"GrayscaleWithMode 601",
+			GrayscaleWithMode(src, GrayscaleLuminosity601),
+			gray(0x3d, 0x78, 0x17, 0x1f, 0x25, 0x66, 0x00, 0x33, 0xff),
+		},
+		{
+			"GrayscaleWithMode 709",
+			GrayscaleWithMode(src, GrayscaleLuminosity709),
+			gray(0x2b, 0x92, 0x0f, 0x20, 0x24, 0x56, 0x00, 0x33, 0xff),
+		},
+		{
+			"GrayscaleWithWeights 2 1 1",
+			GrayscaleWithWeights(src, 2, 1, 1),
+			gray(0x66, 0x33, 0x33, 0x1e, 0x26, 0x91, 0x00, 0x33, 0xff),
+		},
+		{
+			"GrayscaleWithWeights 0 0 0",
+			GrayscaleWithWeights(src, 0, 0, 0),
+			gray(0x3d, 0x78, 0x17, 0x1f, 0x25, 0x66, 0x00, 0x33, 0xff),
+		},
+	}
+	for _, d := range td {
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 3, 3), Stride: 3 * 4, Pix: d.want}
+		if !compareNRGBA(d.got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
 func TestInvert(t *testing.T) {
 	td := []struct {
 		desc string
@@ -79,6 +251,57 @@ func TestInvert(t *testing.T) {
 	}
 }
 
+func TestInvertChannels(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x10, 0x80, 0x01, 0xff, 0x33, 0xcc, 0xff},
+	}
+	td := []struct {
+		desc       string
+		r, g, b, a bool
+		want       []uint8
+	}{
+		{"InvertChannels none", false, false, false, false, []uint8{0x00, 0x10, 0x80, 0x01, 0xff, 0x33, 0xcc, 0xff}},
+		{"InvertChannels rgb", true, true, true, false, []uint8{0xff, 0xef, 0x7f, 0x01, 0x00, 0xcc, 0x33, 0xff}},
+		{"InvertChannels blue", false, false, true, false, []uint8{0x00, 0x10, 0x7f, 0x01, 0xff, 0x33, 0x33, 0xff}},
+		{"InvertChannels alpha", false, false, false, true, []uint8{0x00, 0x10, 0x80, 0xfe, 0xff, 0x33, 0xcc, 0x00}},
+		{"InvertChannels all", true, true, true, true, []uint8{0xff, 0xef, 0x7f, 0xfe, 0x00, 0xcc, 0x33, 0x00}},
+	}
+	for _, d := range td {
+		got := InvertChannels(src, d.r, d.g, d.b, d.a)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestSolarize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x7f, 0x80, 0x01, 0xff, 0x33, 0xcc, 0xff},
+	}
+	td := []struct {
+		desc      string
+		threshold float64
+		want      []uint8
+	}{
+		{"Solarize 0", 0, []uint8{0x00, 0x80, 0x7f, 0x01, 0x00, 0xcc, 0x33, 0xff}},
+		{"Solarize 0.5", 0.5, []uint8{0x00, 0x7f, 0x7f, 0x01, 0x00, 0x33, 0x33, 0xff}},
+		{"Solarize 0.8", 0.8, []uint8{0x00, 0x7f, 0x80, 0x01, 0x00, 0x33, 0xcc, 0xff}},
+		{"Solarize 1", 1, []uint8{0x00, 0x7f, 0x80, 0x01, 0xff, 0x33, 0xcc, 0xff}},
+	}
+	for _, d := range td {
+		got := Solarize(src, d.threshold)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
 func TestAdjustContrast(t *testing.T) {
 	td := []struct {
 		desc string
@@ -416,6 +639,35 @@ func TestAdjustGamma(t *testing.T) {
 	}
 }
 
+func TestAdjustGammaRGB(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xcc, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xcc, 0x03,
+			0x11, 0x22, 0x33, 0xff, 0x33, 0x22, 0x11, 0xff, 0xaa, 0x33, 0xbb, 0xff,
+			0x00, 0x00, 0x00, 0xff, 0x33, 0x33, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	got := AdjustGammaRGB(src, 0.75, 1.0, 1.5)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 3),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xbd, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xdc, 0x03,
+			0x07, 0x22, 0x57, 0xff, 0x1e, 0x22, 0x2a, 0xff, 0x95, 0x33, 0xcf, 0xff,
+			0x00, 0x00, 0x00, 0xff, 0x1e, 0x33, 0x57, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [AdjustGammaRGB 3x3 0.75 1.0 1.5] failed: %#v", got)
+	}
+
+	if got, want := AdjustGammaRGB(src, 0.75, 0.75, 0.75), AdjustGamma(src, 0.75); !compareNRGBA(got, want, 0) {
+		t.Errorf("test [AdjustGammaRGB equal] failed: %#v", got)
+	}
+}
+
 func TestAdjustSigmoid(t *testing.T) {
 	td := []struct {
 		desc string
@@ -502,3 +754,739 @@ func TestAdjustSigmoid(t *testing.T) {
 		}
 	}
 }
+
+func TestSepia(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x01, 0x80, 0x40, 0x20, 0x02, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		p    float64
+		want *image.NRGBA
+	}{
+		{
+			"Sepia 3x1 0",
+			0,
+			Clone(src),
+		},
+		{
+			"Sepia 3x1 100",
+			100,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x6a, 0x5e, 0x49, 0x02, 0xff, 0xff, 0xef, 0xff,
+				},
+			},
+		},
+		{
+			"Sepia 3x1 50",
+			50,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x75, 0x4f, 0x35, 0x02, 0xff, 0xff, 0xf7, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Sepia(src, d.p)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestAdjustSaturation(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xcc, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xcc, 0x03,
+			0x80, 0x40, 0x20, 0xff, 0x33, 0x33, 0x33, 0xff, 0xcc, 0x99, 0x66, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		p    float64
+		want *image.NRGBA
+	}{
+		{
+			"AdjustSaturation 3x2 0",
+			0,
+			Clone(src),
+		},
+		{
+			"AdjustSaturation 3x2 -100",
+			-100,
+			Grayscale(src),
+		},
+		{
+			"AdjustSaturation 3x2 -200",
+			-200,
+			Grayscale(src),
+		},
+		{
+			"AdjustSaturation 3x2 50",
+			50,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xff, 0x00, 0x00, 0x01, 0x00, 0xf6, 0x00, 0x02, 0x00, 0x00, 0xff, 0x03,
+					0x98, 0x38, 0x08, 0xff, 0x33, 0x33, 0x33, 0xff, 0xe1, 0x94, 0x48, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := AdjustSaturation(src, d.p)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestAdjustHue(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0x01, 0x00, 0xff, 0x00, 0x02, 0x00, 0x00, 0xff, 0x03,
+			0x80, 0x40, 0x20, 0xff, 0x33, 0x33, 0x33, 0xff, 0xcc, 0x99, 0x66, 0xff,
+		},
+	}
+	td := []struct {
+		desc    string
+		degrees float64
+		want    *image.NRGBA
+	}{
+		{
+			"AdjustHue 3x2 0",
+			0,
+			Clone(src),
+		},
+		{
+			"AdjustHue 3x2 360",
+			360,
+			Clone(src),
+		},
+		{
+			"AdjustHue 3x2 120",
+			120,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0xff, 0x00, 0x01, 0x00, 0x00, 0xff, 0x02, 0xff, 0x00, 0x00, 0x03,
+					0x20, 0x80, 0x40, 0xff, 0x33, 0x33, 0x33, 0xff, 0x66, 0xcc, 0x99, 0xff,
+				},
+			},
+		},
+		{
+			"AdjustHue 3x2 -120",
+			-120,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0xff, 0x01, 0xff, 0x00, 0x00, 0x02, 0x00, 0xff, 0x00, 0x03,
+					0x40, 0x20, 0x80, 0xff, 0x33, 0x33, 0x33, 0xff, 0x99, 0x66, 0xcc, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := AdjustHue(src, d.degrees)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x10, 0x10, 0x10, 0x01, 0x7f, 0x7f, 0x7f, 0x02, 0x80, 0x80, 0x80, 0x03, 0xff, 0x00, 0x00, 0xff,
+		},
+	}
+	td := []struct {
+		desc  string
+		level float64
+		want  *image.NRGBA
+	}{
+		{
+			"Threshold 4x1 0.5",
+			0.5,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0xff, 0xff, 0xff, 0x03, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Threshold 4x1 0.0",
+			0.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0xff, 0xff, 0xff, 0x01, 0xff, 0xff, 0xff, 0x02, 0xff, 0xff, 0xff, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Threshold(src, d.level)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestThresholdOtsu(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 6, 1),
+		Stride: 6 * 4,
+		Pix: []uint8{
+			0x10, 0x10, 0x10, 0xff, 0x20, 0x20, 0x20, 0xff, 0x30, 0x30, 0x30, 0x80,
+			0xa0, 0xa0, 0xa0, 0xff, 0xb0, 0xb0, 0xb0, 0xff, 0xc0, 0xc0, 0xc0, 0x80,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 6, 1),
+		Stride: 6 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x80,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x80,
+		},
+	}
+	got := ThresholdOtsu(src)
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [ThresholdOtsu 6x1] failed: %#v", got)
+	}
+}
+
+func TestAdjustRGB(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x80, 0xc0, 0x01, 0x64, 0x32, 0xff, 0xff},
+	}
+	td := []struct {
+		desc    string
+		r, g, b float64
+		want    []uint8
+	}{
+		{"AdjustRGB 1 1 1", 1, 1, 1, []uint8{0x10, 0x80, 0xc0, 0x01, 0x64, 0x32, 0xff, 0xff}},
+		{"AdjustRGB 2 0.5 1.5", 2, 0.5, 1.5, []uint8{0x20, 0x40, 0xff, 0x01, 0xc8, 0x19, 0xff, 0xff}},
+		{"AdjustRGB -1 0 0.1", -1, 0, 0.1, []uint8{0x00, 0x00, 0x13, 0x01, 0x00, 0x00, 0x1a, 0xff}},
+	}
+	for _, d := range td {
+		got := AdjustRGB(src, d.r, d.g, d.b)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestAdjustTemperature(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x64, 0x64, 0x64, 0x01, 0xc8, 0x64, 0xc8, 0xff},
+	}
+	td := []struct {
+		desc   string
+		kelvin float64
+		want   []uint8
+	}{
+		{"AdjustTemperature 6500", 6500, []uint8{0x64, 0x64, 0x64, 0x01, 0xc8, 0x64, 0xc8, 0xff}},
+		{"AdjustTemperature 3000", 3000, []uint8{0x8f, 0x64, 0x3f, 0x01, 0xff, 0x64, 0x7e, 0xff}},
+		{"AdjustTemperature 10000", 10000, []uint8{0x5c, 0x64, 0x77, 0x01, 0xb8, 0x64, 0xee, 0xff}},
+		{"AdjustTemperature 100", 100, []uint8{0xff, 0x64, 0x00, 0x01, 0xff, 0x64, 0x00, 0xff}},
+		{"AdjustTemperature 100000", 100000, []uint8{0x51, 0x64, 0x8c, 0x01, 0xa3, 0x64, 0xff, 0xff}},
+	}
+	for _, d := range td {
+		got := AdjustTemperature(src, d.kelvin)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestAutoWhiteBalance(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x78, 0x64, 0x50, 0xff, 0x1e, 0x19, 0x14, 0x80, 0xff, 0x00, 0xff, 0x00},
+	}
+	td := []struct {
+		desc string
+		mode WhiteBalanceMode
+		want []uint8
+	}{
+		{
+			"AutoWhiteBalance gray world",
+			WhiteBalanceGrayWorld,
+			[]uint8{0x64, 0x64, 0x64, 0xff, 0x19, 0x19, 0x19, 0x80, 0xd5, 0x00, 0xff, 0x00},
+		},
+		{
+			"AutoWhiteBalance white patch",
+			WhiteBalanceWhitePatch,
+			[]uint8{0xff, 0xff, 0xff, 0xff, 0x40, 0x40, 0x40, 0x80, 0xff, 0x00, 0xff, 0x00},
+		},
+	}
+	for _, d := range td {
+		got := AutoWhiteBalanceWithMode(src, d.mode)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	if got := AutoWhiteBalance(src); !compareNRGBA(got, AutoWhiteBalanceWithMode(src, WhiteBalanceGrayWorld), 0) {
+		t.Errorf("test [AutoWhiteBalance] failed: %#v", got)
+	}
+
+	// a synthetic image with a known color cast becomes near-neutral
+	cast := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			v := float64((x*16 + y) % 200)
+			cast.SetNRGBA(x, y, color.NRGBA{clamp(v * 1.2), clamp(v), clamp(v * 0.7), 0xff})
+		}
+	}
+	got := AutoWhiteBalance(cast)
+	for i := 0; i < len(got.Pix); i += 4 {
+		if absint(int(got.Pix[i])-int(got.Pix[i+1])) > 2 || absint(int(got.Pix[i+2])-int(got.Pix[i+1])) > 2 {
+			t.Errorf("test [AutoWhiteBalance cast] failed: %v", got.Pix[i:i+4])
+			break
+		}
+	}
+
+	// a fully transparent image is returned as a new copy
+	transparent := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0x00, 0x40, 0x50, 0x60, 0x00},
+	}
+	for _, mode := range []WhiteBalanceMode{WhiteBalanceGrayWorld, WhiteBalanceWhitePatch} {
+		got := AutoWhiteBalanceWithMode(transparent, mode)
+		if !compareNRGBA(got, Clone(transparent), 0) {
+			t.Errorf("test [AutoWhiteBalance transparent] failed: %#v", got)
+		}
+		got.Pix[0] = 0xff
+		if transparent.Pix[0] != 0x10 {
+			t.Errorf("test [AutoWhiteBalance transparent] failed: source image modified")
+		}
+	}
+}
+
+func TestChannelMixer(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x80, 0xc0, 0x01, 0x64, 0x32, 0xff, 0xff},
+	}
+	td := []struct {
+		desc   string
+		matrix [3][3]float64
+		want   []uint8
+	}{
+		{
+			"ChannelMixer identity",
+			[3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+			[]uint8{0x10, 0x80, 0xc0, 0x01, 0x64, 0x32, 0xff, 0xff},
+		},
+		{
+			"ChannelMixer swap red blue",
+			[3][3]float64{{0, 0, 1}, {0, 1, 0}, {1, 0, 0}},
+			[]uint8{0xc0, 0x80, 0x10, 0x01, 0xff, 0x32, 0x64, 0xff},
+		},
+		{
+			"ChannelMixer mix",
+			[3][3]float64{{0.5, 0.5, 0}, {1, 1, 1}, {0, -1, 0.5}},
+			[]uint8{0x48, 0xff, 0x00, 0x01, 0x4b, 0xff, 0x4e, 0xff},
+		},
+	}
+	for _, d := range td {
+		got := ChannelMixer(src, d.matrix)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff, 0x33, 0x99, 0x66, 0x80},
+	}
+	td := []struct {
+		desc              string
+		shadow, highlight color.Color
+		want              []uint8
+	}{
+		{
+			"Colorize black white",
+			color.Black, color.White,
+			[]uint8{0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff, 0x75, 0x75, 0x75, 0x80},
+		},
+		{
+			"Colorize duotone",
+			color.NRGBA{0x10, 0x20, 0x80, 0x00}, color.NRGBA{0xf0, 0xe0, 0x40, 0xff},
+			[]uint8{0x10, 0x20, 0x80, 0x01, 0xf0, 0xe0, 0x40, 0xff, 0x77, 0x78, 0x63, 0x80},
+		},
+	}
+	for _, d := range td {
+		got := Colorize(src, d.shadow, d.highlight)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestPosterize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x40, 0x7f, 0x01, 0x80, 0xc0, 0xff, 0x02, 0x10, 0x20, 0x30, 0x03, 0xd0, 0xe0, 0xf0, 0xff,
+		},
+	}
+	td := []struct {
+		desc   string
+		levels int
+		want   *image.NRGBA
+	}{
+		{
+			"Posterize 4x1 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0x02, 0x00, 0x00, 0x00, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 4x1 3",
+			3,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x80, 0x80, 0x01, 0x80, 0xff, 0xff, 0x02, 0x00, 0x00, 0x00, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 4x1 256",
+			256,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x40, 0x7f, 0x01, 0x80, 0xc0, 0xff, 0x02, 0x10, 0x20, 0x30, 0x03, 0xd0, 0xe0, 0xf0, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 4x1 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0x02, 0x00, 0x00, 0x00, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Posterize(src, d.levels)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestEqualizeHistogram(t *testing.T) {
+	// low-contrast gradient with luminance values in range (100, 155)
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 256; x++ {
+			v := uint8(100 + (x*55+127)/255)
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+
+	got := EqualizeHistogram(src)
+	hist := Histogram(got)[3]
+
+	total := 256 * 4
+	cdf := 0
+	for i, n := range hist {
+		cdf += n
+		want := float64(i) / 255
+		if n > 0 && math.Abs(float64(cdf)/float64(total)-want) > 0.05 {
+			t.Errorf("test [EqualizeHistogram gradient] failed: cdf(%d) = %v", i, float64(cdf)/float64(total))
+		}
+	}
+	if hist[0] == 0 || hist[255] == 0 {
+		t.Errorf("test [EqualizeHistogram gradient] failed: output range is not stretched")
+	}
+
+	uniform := New(3, 3, color.NRGBA{0x40, 0x80, 0xc0, 0xff})
+	if !compareNRGBA(EqualizeHistogram(uniform), uniform, 0) {
+		t.Errorf("test [EqualizeHistogram uniform] failed")
+	}
+}
+
+func TestAutoContrast(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x40, 0x40, 0x40, 0x01, 0x60, 0x50, 0x40, 0x02, 0x80, 0x80, 0x80, 0x03, 0xc0, 0x80, 0xc0, 0xff,
+		},
+	}
+	td := []struct {
+		desc       string
+		clip       float64
+		perChannel bool
+		want       *image.NRGBA
+	}{
+		{
+			"AutoContrast 4x1 0 luminance",
+			0,
+			false,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x5b, 0x2d, 0x00, 0x02, 0xb5, 0xb5, 0xb5, 0x03, 0xff, 0xb5, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"AutoContrast 4x1 0 per channel",
+			0,
+			true,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x40, 0x40, 0x00, 0x02, 0x80, 0xff, 0x80, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"AutoContrast 4x1 0.25 luminance",
+			0.25,
+			false,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x4a, 0x00, 0x00, 0x02, 0xff, 0xff, 0xff, 0x03, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := AutoContrast(src, d.clip, d.perChannel)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff,
+		},
+	}
+
+	hist := Histogram(src)
+	want := []struct {
+		channel, value, count int
+	}{
+		{0, 0xff, 2}, {0, 0x00, 2},
+		{1, 0xff, 1}, {1, 0x00, 3},
+		{2, 0x00, 4},
+		{3, 0x4c, 2}, {3, 0x96, 1}, {3, 0x00, 1},
+	}
+	for _, w := range want {
+		if hist[w.channel][w.value] != w.count {
+			t.Errorf("test [Histogram 4x1] failed: hist[%d][%d] = %d, want %d", w.channel, w.value, hist[w.channel][w.value], w.count)
+		}
+	}
+
+	norm := HistogramNormalized(src)
+	for _, w := range want {
+		if math.Abs(norm[w.channel][w.value]-float64(w.count)/4) > 1e-9 {
+			t.Errorf("test [HistogramNormalized 4x1] failed: norm[%d][%d] = %v", w.channel, w.value, norm[w.channel][w.value])
+		}
+	}
+
+	empty := HistogramNormalized(&image.NRGBA{})
+	if empty[3][0] != 0 {
+		t.Errorf("test [HistogramNormalized empty] failed: %v", empty[3][0])
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 5, 0),
+		Stride: 6 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xf0, 0x02, 0x00, 0xff, 0xfa, 0x04, 0x00, 0x80,
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xf1, 0xff, 0x00, 0xff, 0x00, 0x00,
+		},
+	}
+	td := []struct {
+		desc string
+		n    int
+		want []color.NRGBA
+	}{
+		{
+			"DominantColors 6x1 1",
+			1,
+			[]color.NRGBA{{0xf8, 0x02, 0x00, 0xff}},
+		},
+		{
+			"DominantColors 6x1 5",
+			5,
+			[]color.NRGBA{{0xf8, 0x02, 0x00, 0xff}, {0x00, 0x00, 0xf8, 0xff}},
+		},
+		{
+			"DominantColors 6x1 0",
+			0,
+			nil,
+		},
+	}
+	for _, d := range td {
+		got := DominantColors(src, d.n)
+		if len(got) != len(d.want) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != d.want[i] {
+				t.Errorf("test [%s] failed: %#v", d.desc, got)
+				break
+			}
+		}
+	}
+}
+
+func TestUniqueColors(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0xff, 0x01, 0x02, 0x03, 0xff, 0x01, 0x02, 0x03, 0x80,
+			0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	if n := UniqueColors(src); n != 4 {
+		t.Errorf("test [UniqueColors] failed: %d", n)
+	}
+	want := map[color.NRGBA]int{
+		{0x01, 0x02, 0x03, 0xff}: 3,
+		{0x01, 0x02, 0x03, 0x80}: 1,
+		{0x00, 0x00, 0x00, 0x00}: 1,
+		{0xff, 0xff, 0xff, 0xff}: 1,
+	}
+	got := ColorCount(src)
+	if len(got) != len(want) {
+		t.Errorf("test [ColorCount] failed: %v", got)
+	}
+	for c, n := range want {
+		if got[c] != n {
+			t.Errorf("test [ColorCount] failed: %v count is %d", c, got[c])
+		}
+	}
+
+	if n := UniqueColors(&image.NRGBA{}); n != 0 {
+		t.Errorf("test [UniqueColors empty] failed: %d", n)
+	}
+	if n := UniqueColors(Quantize(compareTestImage(), 8)); n > 8 {
+		t.Errorf("test [UniqueColors quantized] failed: %d", n)
+	}
+}
+
+func TestAverageColor(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		rect image.Rectangle
+		want color.NRGBA
+	}{
+		{
+			"AverageColorRect 2x2 whole",
+			image.Rect(-1, -1, 1, 1),
+			color.NRGBA{0x55, 0x00, 0xaa, 0xbf},
+		},
+		{
+			"AverageColorRect 2x2 top row",
+			image.Rect(-5, -5, 5, 0),
+			color.NRGBA{0xff, 0x00, 0x00, 0x80},
+		},
+		{
+			"AverageColorRect 2x2 transparent",
+			image.Rect(0, -1, 1, 0),
+			color.NRGBA{},
+		},
+		{
+			"AverageColorRect 2x2 outside",
+			image.Rect(5, 5, 10, 10),
+			color.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		got := AverageColorRect(src, d.rect)
+		if got != d.want {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	if got := AverageColor(src); got != td[0].want {
+		t.Errorf("test [AverageColor 2x2] failed: %#v", got)
+	}
+}
@@ -0,0 +1,249 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Hash returns the difference hash (dHash) of the image. The image is converted to grayscale
+// and resized to 9x8 pixels, then each bit of the hash is set if the pixel is brighter than
+// its right neighbor. Similar images have hashes with a small Hamming distance.
+//
+// Usage example:
+//
+//		d := imaging.HammingDistance(imaging.Hash(img1), imaging.Hash(img2))
+//		if d <= 10 {
+//			// the images are similar
+//		}
+//
+func Hash(img image.Image) uint64 {
+	small := Resize(Grayscale(img), 9, 8, Box)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		i := y * small.Stride
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.Pix[i+x*4] > small.Pix[i+(x+1)*4] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// AverageHash returns the average hash (aHash) of the image. The image is converted to grayscale
+// and resized to 8x8 pixels, then each bit of the hash is set if the pixel is brighter than the
+// average brightness. Similar images have hashes with a small Hamming distance.
+//
+// Usage example:
+//
+//		d := imaging.HammingDistance(imaging.AverageHash(img1), imaging.AverageHash(img2))
+//
+func AverageHash(img image.Image) uint64 {
+	small := Resize(Grayscale(img), 8, 8, Box)
+
+	sum := 0
+	for i := 0; i < len(small.Pix); i += 4 {
+		sum += int(small.Pix[i])
+	}
+
+	var hash uint64
+	for i := 0; i < len(small.Pix); i += 4 {
+		hash <<= 1
+		if int(small.Pix[i])*64 > sum {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of bits that differ in the two hashes.
+func HammingDistance(a, b uint64) int {
+	n := 0
+	for x := a ^ b; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// PSNR returns the peak signal-to-noise ratio (in decibels) between the two images computed
+// over the R, G and B channels. Higher values mean more similar images, +Inf is returned for
+// identical images. The images must have the same size, otherwise NaN is returned.
+//
+// Usage example:
+//
+//		psnr := imaging.PSNR(originalImage, compressedImage)
+//
+func PSNR(a, b image.Image) float64 {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return math.NaN()
+	}
+	src1 := toNRGBA(a)
+	src2 := toNRGBA(b)
+	width := src1.Bounds().Dx()
+	height := src1.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return math.NaN()
+	}
+
+	sum := 0.0
+	for y := 0; y < height; y++ {
+		i := y * src1.Stride
+		j := y * src2.Stride
+		for x := 0; x < width*4; x++ {
+			if x%4 == 3 {
+				continue
+			}
+			d := float64(src1.Pix[i+x]) - float64(src2.Pix[j+x])
+			sum += d * d
+		}
+	}
+
+	if sum == 0 {
+		return math.Inf(1)
+	}
+	mse := sum / float64(width*height*3)
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM returns the structural similarity index between the two images computed on the
+// luminance channel using the 11x11 Gaussian window with sigma 1.5. The result is from -1.0
+// to 1.0, where 1.0 means identical images. The images must have the same size, otherwise
+// NaN is returned.
+//
+// Usage example:
+//
+//		ssim := imaging.SSIM(originalImage, resizedImage)
+//
+func SSIM(a, b image.Image) float64 {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return math.NaN()
+	}
+	src1 := toNRGBA(a)
+	src2 := toNRGBA(b)
+	width := src1.Bounds().Dx()
+	height := src1.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return math.NaN()
+	}
+
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	lum1 := luminanceMap(src1)
+	lum2 := luminanceMap(src2)
+	sq1 := make([]float64, len(lum1))
+	sq2 := make([]float64, len(lum1))
+	prod := make([]float64, len(lum1))
+	for i := range lum1 {
+		sq1[i] = lum1[i] * lum1[i]
+		sq2[i] = lum2[i] * lum2[i]
+		prod[i] = lum1[i] * lum2[i]
+	}
+
+	mu1 := gaussianWindow(lum1, width, height)
+	mu2 := gaussianWindow(lum2, width, height)
+	sq1 = gaussianWindow(sq1, width, height)
+	sq2 = gaussianWindow(sq2, width, height)
+	prod = gaussianWindow(prod, width, height)
+
+	sum := 0.0
+	for i := range mu1 {
+		var1 := sq1[i] - mu1[i]*mu1[i]
+		var2 := sq2[i] - mu2[i]*mu2[i]
+		cov := prod[i] - mu1[i]*mu2[i]
+		sum += (2*mu1[i]*mu2[i] + c1) * (2*cov + c2) /
+			((mu1[i]*mu1[i] + mu2[i]*mu2[i] + c1) * (var1 + var2 + c2))
+	}
+
+	return sum / float64(len(mu1))
+}
+
+// gaussianWindow returns the weighted local means of the values using the 11x11 Gaussian
+// window with sigma 1.5. The window is truncated at the edges and its weights renormalized.
+func gaussianWindow(data []float64, width, height int) []float64 {
+	const radius = 5
+	var kernel [2*radius + 1]float64
+	for i := range kernel {
+		kernel[i] = gaussianBlurKernel(float64(i-radius), 1.5)
+	}
+
+	pass := func(src []float64, n, lines, step, lineStep int) []float64 {
+		dst := make([]float64, len(src))
+		for line := 0; line < lines; line++ {
+			for v := 0; v < n; v++ {
+				sum, wsum := 0.0, 0.0
+				for k := -radius; k <= radius; k++ {
+					if v+k < 0 || v+k >= n {
+						continue
+					}
+					w := kernel[k+radius]
+					sum += src[line*lineStep+(v+k)*step] * w
+					wsum += w
+				}
+				dst[line*lineStep+v*step] = sum / wsum
+			}
+		}
+		return dst
+	}
+
+	tmp := pass(data, width, height, 1, width)
+	return pass(tmp, height, width, width, 1)
+}
+
+// Diff compares the two images and returns the difference image and the number of differing
+// pixels. Two pixels differ if the difference of any of their channels is greater than
+// the tolerance as a fraction of the full channel range (from 0.0 to 1.0). In the difference
+// image, the differing pixels are red and other pixels are the faded grayscale version of the
+// first image. The images must have the same size, otherwise an empty image and -1 are returned.
+//
+// Usage example:
+//
+//		diffImage, n := imaging.Diff(expectedImage, actualImage, 0.01)
+//		if n > 0 {
+//			imaging.Save(diffImage, "diff.png")
+//		}
+//
+func Diff(a, b image.Image, tolerance float64) (*image.NRGBA, int) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return &image.NRGBA{}, -1
+	}
+	src1 := toNRGBA(a)
+	src2 := toNRGBA(b)
+	width := src1.Bounds().Dx()
+	height := src1.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	tolerance = math.Min(math.Max(tolerance, 0.0), 1.0)
+	maxDiff := int(tolerance*255.0 + 0.5)
+
+	counts := make([]int, height)
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				i := y*src1.Stride + x*4
+				j := y*src2.Stride + x*4
+				k := y*dst.Stride + x*4
+				if pixelDiff(src1.Pix[i:i+4], src2.Pix[j:j+4]) > maxDiff {
+					counts[y]++
+					dst.Pix[k+0], dst.Pix[k+1], dst.Pix[k+2], dst.Pix[k+3] = 0xff, 0x00, 0x00, 0xff
+					continue
+				}
+				c := color.NRGBA{src1.Pix[i+0], src1.Pix[i+1], src1.Pix[i+2], src1.Pix[i+3]}
+				// fade to white proportionally to the transparency and by 3/4 anyway
+				v := 255 - (255-int(luminance(c)))*int(c.A)/255/4
+				dst.Pix[k+0], dst.Pix[k+1], dst.Pix[k+2], dst.Pix[k+3] = uint8(v), uint8(v), uint8(v), 0xff
+			}
+		}
+	})
+
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return dst, n
+}
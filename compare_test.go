@@ -0,0 +1,210 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// compareTestImage returns an image with a few smooth shapes of different brightness.
+func compareTestImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 90, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 90; x++ {
+			v := uint8(x * 2)
+			if (x-30)*(x-30)+(y-40)*(y-40) < 400 {
+				v = 0xf0
+			}
+			if x > 60 && y > 20 && y < 50 {
+				v = 0x20
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, uint8(y * 3), v / 2, 0xff})
+		}
+	}
+	return img
+}
+
+func TestHash(t *testing.T) {
+	uniform := New(20, 20, color.NRGBA{100, 100, 100, 255})
+	if got := Hash(uniform); got != 0 {
+		t.Errorf("test [Hash uniform] failed: %016x", got)
+	}
+	if got := AverageHash(uniform); got != 0 {
+		t.Errorf("test [AverageHash uniform] failed: %016x", got)
+	}
+
+	// bright left half, dark right half
+	halves := New(16, 16, color.NRGBA{0, 0, 0, 255})
+	halves = Paste(halves, New(8, 16, color.NRGBA{255, 255, 255, 255}), image.Pt(0, 0))
+	if got, want := AverageHash(halves), uint64(0xf0f0f0f0f0f0f0f0); got != want {
+		t.Errorf("test [AverageHash halves] failed: %016x", got)
+	}
+	if got, want := Hash(halves), uint64(0x1818181818181818); got != want {
+		t.Errorf("test [Hash halves] failed: %016x", got)
+	}
+
+	// the same image encoded with different JPEG qualities
+	src := compareTestImage()
+	var imgs []image.Image
+	for _, q := range []int{95, 40} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, src, JPEG, JPEGQuality(q)); err != nil {
+			t.Fatalf("test [Hash jpeg] failed: %v", err)
+		}
+		img, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("test [Hash jpeg] failed: %v", err)
+		}
+		imgs = append(imgs, img)
+	}
+	if d := HammingDistance(Hash(imgs[0]), Hash(imgs[1])); d > 4 {
+		t.Errorf("test [Hash jpeg] failed: distance %d", d)
+	}
+	if d := HammingDistance(AverageHash(imgs[0]), AverageHash(imgs[1])); d > 4 {
+		t.Errorf("test [AverageHash jpeg] failed: distance %d", d)
+	}
+
+	// different images
+	other := FlipH(src)
+	if d := HammingDistance(Hash(src), Hash(other)); d < 16 {
+		t.Errorf("test [Hash flipped] failed: distance %d", d)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	td := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, 0xffffffffffffffff, 64},
+		{0x8000000000000001, 0x0000000000000001, 1},
+	}
+	for _, d := range td {
+		if got := HammingDistance(d.a, d.b); got != d.want {
+			t.Errorf("test [HammingDistance %x %x] failed: %d", d.a, d.b, got)
+		}
+	}
+}
+
+func TestPSNR(t *testing.T) {
+	src := compareTestImage()
+	td := []struct {
+		desc string
+		a, b image.Image
+		want float64
+	}{
+		{"PSNR identical", src, Clone(src), math.Inf(1)},
+		{"PSNR known", New(4, 3, color.NRGBA{0, 0, 0, 255}), New(4, 3, color.NRGBA{10, 10, 10, 0}), 28.130803608679},
+		{"PSNR size mismatch", src, New(4, 3, color.Black), math.NaN()},
+		{"PSNR empty", &image.NRGBA{}, &image.NRGBA{}, math.NaN()},
+	}
+	for _, d := range td {
+		got := PSNR(d.a, d.b)
+		if math.IsNaN(d.want) && math.IsNaN(got) || got == d.want || math.Abs(got-d.want) < 1e-9 {
+			continue
+		}
+		t.Errorf("test [%s] failed: %v", d.desc, got)
+	}
+
+	if q1, q2 := PSNR(src, Blur(src, 0.5)), PSNR(src, Blur(src, 2)); !(q1 > q2) {
+		t.Errorf("test [PSNR blur] failed: %v <= %v", q1, q2)
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	src := compareTestImage()
+	td := []struct {
+		desc string
+		a, b image.Image
+		want float64
+	}{
+		{"SSIM identical", src, Clone(src), 1.0},
+		{"SSIM uniform", New(12, 12, color.NRGBA{0, 0, 0, 255}), New(12, 12, color.NRGBA{10, 10, 10, 255}), 6.5025 / 106.5025},
+		{"SSIM size mismatch", src, New(4, 3, color.Black), math.NaN()},
+		{"SSIM empty", &image.NRGBA{}, &image.NRGBA{}, math.NaN()},
+	}
+	for _, d := range td {
+		got := SSIM(d.a, d.b)
+		if math.IsNaN(d.want) && math.IsNaN(got) || math.Abs(got-d.want) < 1e-9 {
+			continue
+		}
+		t.Errorf("test [%s] failed: %v", d.desc, got)
+	}
+
+	s1, s2 := SSIM(src, Blur(src, 0.5)), SSIM(src, Blur(src, 2))
+	if !(s1 < 1 && s1 > s2 && s2 > 0) {
+		t.Errorf("test [SSIM blur] failed: %v, %v", s1, s2)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x64, 0x00, 0x00, 0xff},
+	}
+	b := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0xfa, 0xfa, 0xfa, 0xff, 0x64, 0x00, 0x00, 0x00},
+	}
+	td := []struct {
+		desc      string
+		a, b      image.Image
+		tolerance float64
+		want      *image.NRGBA
+		wantN     int
+	}{
+		{
+			"Diff tolerance 0.05",
+			a, b,
+			0.05,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xc0, 0xc0, 0xc0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff},
+			},
+			1,
+		},
+		{
+			"Diff tolerance 0",
+			a, b,
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xc0, 0xc0, 0xc0, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff},
+			},
+			2,
+		},
+		{
+			"Diff transparent",
+			b, b,
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xc0, 0xc0, 0xc0, 0xff, 0xfe, 0xfe, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff},
+			},
+			0,
+		},
+		{
+			"Diff size mismatch",
+			a, New(2, 2, color.Black),
+			0,
+			&image.NRGBA{},
+			-1,
+		},
+	}
+	for _, d := range td {
+		got, n := Diff(d.a, d.b, d.tolerance)
+		if !compareNRGBA(got, d.want, 0) || n != d.wantN {
+			t.Errorf("test [%s] failed: %d %#v", d.desc, n, got)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// FillRect fills the specified rectangle of the dst image with the specified color.
+// The rectangle is clipped to the image bounds. If the color is not fully opaque,
+// it's blended over the existing pixels. The dst image is modified in place.
+//
+// Usage example:
+//
+//		imaging.FillRect(dstImage, image.Rect(10, 10, 50, 30), color.NRGBA{255, 0, 0, 128})
+//
+func FillRect(dst *image.NRGBA, rect image.Rectangle, col color.Color) {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	cs := []uint8{c.R, c.G, c.B, c.A}
+	r := rect.Canon().Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	parallel(r.Dy(), func(partStart, partEnd int) {
+		for y := r.Min.Y + partStart; y < r.Min.Y+partEnd; y++ {
+			i := dst.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				blendOver(dst.Pix[i:i+4], cs)
+				i += 4
+			}
+		}
+	})
+}
+
+// DrawRect draws the outline of the specified rectangle on the dst image with the specified
+// color. The outline of the specified thickness (at least 1 pixel) is drawn inside the rectangle.
+// The rectangle is clipped to the image bounds. If the color is not fully opaque, it's blended
+// over the existing pixels. The dst image is modified in place.
+//
+// Usage example:
+//
+//		imaging.DrawRect(dstImage, image.Rect(10, 10, 50, 30), color.NRGBA{0, 255, 0, 255}, 2)
+//
+func DrawRect(dst *image.NRGBA, rect image.Rectangle, col color.Color, thickness int) {
+	r := rect.Canon()
+	if thickness < 1 {
+		thickness = 1
+	}
+	if 2*thickness >= r.Dx() || 2*thickness >= r.Dy() {
+		FillRect(dst, r, col)
+		return
+	}
+
+	// the sides don't overlap, so each pixel is blended only once
+	FillRect(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness), col)
+	FillRect(dst, image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y), col)
+	FillRect(dst, image.Rect(r.Min.X, r.Min.Y+thickness, r.Min.X+thickness, r.Max.Y-thickness), col)
+	FillRect(dst, image.Rect(r.Max.X-thickness, r.Min.Y+thickness, r.Max.X, r.Max.Y-thickness), col)
+}
+
+// DrawLine draws the line segment from p0 to p1 (both inclusive) on the dst image with the
+// specified color and thickness. Lines with thickness 1 are drawn using the Bresenham algorithm,
+// thicker lines include all the pixels within thickness/2 from the segment. The line is clipped
+// to the image bounds. If the color is not fully opaque, it's blended over the existing pixels.
+// The dst image is modified in place.
+//
+// Usage example:
+//
+//		imaging.DrawLine(dstImage, image.Pt(0, 0), image.Pt(100, 50), color.Black, 3)
+//
+func DrawLine(dst *image.NRGBA, p0, p1 image.Point, col color.Color, thickness int) {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	cs := []uint8{c.R, c.G, c.B, c.A}
+	bounds := dst.Bounds()
+
+	if thickness <= 1 {
+		drawLineThin(dst, p0, p1, cs)
+		return
+	}
+
+	half := float64(thickness) / 2.0
+	pad := int(math.Ceil(half))
+	r := image.Rect(p0.X, p0.Y, p1.X, p1.Y).Canon()
+	r = image.Rect(r.Min.X-pad, r.Min.Y-pad, r.Max.X+pad+1, r.Max.Y+pad+1).Intersect(bounds)
+
+	vx, vy := float64(p1.X-p0.X), float64(p1.Y-p0.Y)
+	lenSq := vx*vx + vy*vy
+
+	parallel(r.Dy(), func(partStart, partEnd int) {
+		for y := r.Min.Y + partStart; y < r.Min.Y+partEnd; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				// distance from the pixel to the nearest point of the segment
+				wx, wy := float64(x-p0.X), float64(y-p0.Y)
+				t := 0.0
+				if lenSq > 0 {
+					t = math.Min(math.Max((wx*vx+wy*vy)/lenSq, 0.0), 1.0)
+				}
+				if math.Hypot(wx-t*vx, wy-t*vy) > half {
+					continue
+				}
+				i := dst.PixOffset(x, y)
+				blendOver(dst.Pix[i:i+4], cs)
+			}
+		}
+	})
+}
+
+// drawLineThin draws the 1 pixel wide line using the Bresenham algorithm. The pixel positions are
+// computed directly from the step number along the major axis, so only the steps inside the image
+// bounds are visited and the cost doesn't depend on how far the endpoints are outside of the image.
+func drawLineThin(dst *image.NRGBA, p0, p1 image.Point, cs []uint8) {
+	bounds := dst.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	dx, dy := absint(p1.X-p0.X), absint(p1.Y-p0.Y)
+	sx, sy := 1, 1
+	if p0.X > p1.X {
+		sx = -1
+	}
+	if p0.Y > p1.Y {
+		sy = -1
+	}
+
+	// the major axis advances by one pixel on each step, the minor one when the error
+	// of the ideal line exceeds half a pixel (ties are broken like the classic loop does)
+	n, m := dx, dy
+	if dy > dx {
+		n, m = dy, dx
+	}
+	point := func(i int) image.Point {
+		j := 0
+		if n > 0 {
+			j = (2*i*m + n) / (2 * n)
+		}
+		if dy > dx {
+			return image.Pt(p0.X+sx*j, p0.Y+sy*i)
+		}
+		return image.Pt(p0.X+sx*i, p0.Y+sy*j)
+	}
+
+	// the steps are limited by the bounds along the major axis first
+	lo, hi := 0, n
+	major, smajor, bmin, bmax := p0.X, sx, bounds.Min.X, bounds.Max.X-1
+	if dy > dx {
+		major, smajor, bmin, bmax = p0.Y, sy, bounds.Min.Y, bounds.Max.Y-1
+	}
+	if smajor > 0 {
+		lo, hi = maxint(lo, bmin-major), minint(hi, bmax-major)
+	} else {
+		lo, hi = maxint(lo, major-bmax), minint(hi, major-bmin)
+	}
+	if lo > hi {
+		return
+	}
+
+	// and then along the minor axis, the minor coordinate changes monotonically
+	inside := func(i int) int {
+		p := point(i)
+		v, s, vmin, vmax := p.Y, sy, bounds.Min.Y, bounds.Max.Y-1
+		if dy > dx {
+			v, s, vmin, vmax = p.X, sx, bounds.Min.X, bounds.Max.X-1
+		}
+		switch {
+		case (s > 0 && v < vmin) || (s < 0 && v > vmax):
+			return -1 // not reached the bounds yet
+		case (s > 0 && v > vmax) || (s < 0 && v < vmin):
+			return 1 // passed the bounds
+		}
+		return 0
+	}
+	start := lo + sort.Search(hi-lo+1, func(k int) bool { return inside(lo+k) >= 0 })
+	end := lo + sort.Search(hi-lo+1, func(k int) bool { return inside(lo+k) > 0 })
+
+	for i := start; i < end; i++ {
+		p := point(i)
+		j := dst.PixOffset(p.X, p.Y)
+		blendOver(dst.Pix[j:j+4], cs)
+	}
+}
+
+// FloodFill replaces the color of the connected region of similarly colored pixels containing
+// the start point with the specified fill color. The pixels are connected horizontally and
+// vertically. The tolerance parameter is the maximum allowed difference between a pixel channel
+// and the start pixel channel as a fraction of the full channel range, it must be from 0.0 to 1.0.
+// The dst image is modified in place.
+//
+// Usage example:
+//
+//		imaging.FloodFill(dstImage, image.Pt(10, 10), color.White, 0.1)
+//
+func FloodFill(dst *image.NRGBA, start image.Point, fill color.Color, tolerance float64) {
+	bounds := dst.Bounds()
+	if !start.In(bounds) {
+		return
+	}
+
+	c := color.NRGBAModel.Convert(fill).(color.NRGBA)
+	cs := []uint8{c.R, c.G, c.B, c.A}
+	tolerance = math.Min(math.Max(tolerance, 0.0), 1.0)
+	maxDiff := int(tolerance*255.0 + 0.5)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	si := dst.PixOffset(start.X, start.Y)
+	target := make([]uint8, 4)
+	copy(target, dst.Pix[si:si+4])
+
+	visited := make([]bool, w*h)
+	matches := func(x, y int) bool {
+		if visited[y*w+x] {
+			return false
+		}
+		i := dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+		return pixelDiff(dst.Pix[i:i+4], target) <= maxDiff
+	}
+
+	// scanline fill: each stack item is a seed point, the whole horizontal span
+	// containing it is filled and the matching spans of the adjacent rows are pushed
+	stack := []image.Point{start.Sub(bounds.Min)}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !matches(p.X, p.Y) {
+			continue
+		}
+
+		x0, x1 := p.X, p.X
+		for x0 > 0 && matches(x0-1, p.Y) {
+			x0--
+		}
+		for x1 < w-1 && matches(x1+1, p.Y) {
+			x1++
+		}
+
+		i := dst.PixOffset(bounds.Min.X+x0, bounds.Min.Y+p.Y)
+		for x := x0; x <= x1; x++ {
+			visited[p.Y*w+x] = true
+			copy(dst.Pix[i:i+4], cs)
+			i += 4
+		}
+
+		for _, y := range []int{p.Y - 1, p.Y + 1} {
+			if y < 0 || y >= h {
+				continue
+			}
+			inSpan := false
+			for x := x0; x <= x1; x++ {
+				m := matches(x, y)
+				if m && !inSpan {
+					stack = append(stack, image.Point{x, y})
+				}
+				inSpan = m
+			}
+		}
+	}
+}
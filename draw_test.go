@@ -0,0 +1,333 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// drawTestImage creates an image with the specified bounds where the pixels marked
+// with 'x' in the rows strings have the c color and other pixels have the bg color.
+func drawTestImage(r image.Rectangle, rows []string, c, bg color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y, row := range rows {
+		for x := range row {
+			px := bg
+			if row[x] == 'x' {
+				px = c
+			}
+			img.SetNRGBA(r.Min.X+x, r.Min.Y+y, px)
+		}
+	}
+	return img
+}
+
+func TestDraw(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+	none := color.NRGBA{}
+
+	td := []struct {
+		desc string
+		r    image.Rectangle
+		bg   color.NRGBA
+		draw func(dst *image.NRGBA)
+		c    color.NRGBA
+		want []string
+	}{
+		{
+			"FillRect clipped",
+			image.Rect(-1, -1, 2, 2),
+			white,
+			func(dst *image.NRGBA) { FillRect(dst, image.Rect(5, 5, 0, 0), red) },
+			red,
+			[]string{
+				"...",
+				".xx",
+				".xx",
+			},
+		},
+		{
+			"FillRect semi-transparent",
+			image.Rect(0, 0, 3, 2),
+			white,
+			func(dst *image.NRGBA) { FillRect(dst, image.Rect(1, 0, 3, 1), color.NRGBA{255, 0, 0, 128}) },
+			color.NRGBA{255, 127, 127, 255},
+			[]string{
+				".xx",
+				"...",
+			},
+		},
+		{
+			"DrawRect 1",
+			image.Rect(0, 0, 6, 5),
+			none,
+			func(dst *image.NRGBA) { DrawRect(dst, image.Rect(1, 0, 6, 4), red, 1) },
+			red,
+			[]string{
+				".xxxxx",
+				".x...x",
+				".x...x",
+				".xxxxx",
+				"......",
+			},
+		},
+		{
+			"DrawRect 2 semi-transparent",
+			image.Rect(0, 0, 6, 6),
+			white,
+			func(dst *image.NRGBA) { DrawRect(dst, image.Rect(0, 0, 6, 6), color.NRGBA{255, 0, 0, 128}, 2) },
+			color.NRGBA{255, 127, 127, 255},
+			[]string{
+				"xxxxxx",
+				"xxxxxx",
+				"xx..xx",
+				"xx..xx",
+				"xxxxxx",
+				"xxxxxx",
+			},
+		},
+		{
+			"DrawRect thick",
+			image.Rect(0, 0, 4, 3),
+			none,
+			func(dst *image.NRGBA) { DrawRect(dst, image.Rect(0, 0, 3, 3), red, 2) },
+			red,
+			[]string{
+				"xxx.",
+				"xxx.",
+				"xxx.",
+			},
+		},
+		{
+			"DrawLine 1",
+			image.Rect(0, 0, 4, 2),
+			none,
+			func(dst *image.NRGBA) { DrawLine(dst, image.Pt(3, 1), image.Pt(0, 0), red, 1) },
+			red,
+			[]string{
+				"xx..",
+				"..xx",
+			},
+		},
+		{
+			"DrawLine 1 clipped",
+			image.Rect(0, 0, 4, 4),
+			none,
+			func(dst *image.NRGBA) { DrawLine(dst, image.Pt(-2, -2), image.Pt(10, 10), red, 1) },
+			red,
+			[]string{
+				"x...",
+				".x..",
+				"..x.",
+				"...x",
+			},
+		},
+		{
+			"DrawLine 3",
+			image.Rect(0, 0, 5, 5),
+			none,
+			func(dst *image.NRGBA) { DrawLine(dst, image.Pt(1, 2), image.Pt(3, 2), red, 3) },
+			red,
+			[]string{
+				".....",
+				"xxxxx",
+				"xxxxx",
+				"xxxxx",
+				".....",
+			},
+		},
+		{
+			"DrawLine 2 point",
+			image.Rect(0, 0, 3, 3),
+			none,
+			func(dst *image.NRGBA) { DrawLine(dst, image.Pt(1, 1), image.Pt(1, 1), red, 2) },
+			red,
+			[]string{
+				".x.",
+				"xxx",
+				".x.",
+			},
+		},
+		{
+			"DrawLine outside",
+			image.Rect(0, 0, 2, 2),
+			none,
+			func(dst *image.NRGBA) { DrawLine(dst, image.Pt(-5, 5), image.Pt(5, 5), red, 3) },
+			red,
+			[]string{
+				"..",
+				"..",
+			},
+		},
+	}
+	for _, d := range td {
+		got := New(d.r.Dx(), d.r.Dy(), d.bg)
+		got.Rect = d.r
+		d.draw(got)
+		want := drawTestImage(d.r, d.want, d.c, d.bg)
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestDrawLineBresenham(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	bounds := image.Rect(-3, -2, 17, 13)
+
+	// the classic Bresenham loop visiting every point of the segment
+	reference := func(dst *image.NRGBA, p0, p1 image.Point) {
+		dx, dy := absint(p1.X-p0.X), -absint(p1.Y-p0.Y)
+		sx, sy := 1, 1
+		if p0.X > p1.X {
+			sx = -1
+		}
+		if p0.Y > p1.Y {
+			sy = -1
+		}
+		e := dx + dy
+		x, y := p0.X, p0.Y
+		for {
+			if (image.Point{x, y}).In(dst.Bounds()) {
+				dst.SetNRGBA(x, y, red)
+			}
+			if x == p1.X && y == p1.Y {
+				break
+			}
+			e2 := 2 * e
+			if e2 >= dy {
+				e += dy
+				x += sx
+			}
+			if e2 <= dx {
+				e += dx
+				y += sy
+			}
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for k := 0; k < 2000; k++ {
+		p0 := image.Pt(rnd.Intn(81)-40, rnd.Intn(81)-40)
+		p1 := image.Pt(rnd.Intn(81)-40, rnd.Intn(81)-40)
+		got := image.NewNRGBA(bounds)
+		DrawLine(got, p0, p1, red, 1)
+		want := image.NewNRGBA(bounds)
+		reference(want, p0, p1)
+		if !compareNRGBA(got, want, 0) {
+			t.Fatalf("test [DrawLine %v %v] failed", p0, p1)
+		}
+	}
+
+	// far endpoints don't make the line slower
+	got := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	DrawLine(got, image.Pt(0, 0), image.Pt(1e9, 1e9), red, 1)
+	DrawLine(got, image.Pt(-1e9, 9), image.Pt(1e9, 9), red, 1)
+	want := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for i := 0; i < 10; i++ {
+		want.SetNRGBA(i, i, red)
+		want.SetNRGBA(i, 9, red)
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [DrawLine far endpoints] failed: %#v", got)
+	}
+}
+
+func TestFloodFill(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+
+	src := drawTestImage(image.Rect(-1, -1, 6, 3), []string{
+		"..x....",
+		"..x.xx.",
+		"xxx.x..",
+		"....x.x",
+	}, color.NRGBA{0, 0, 0, 255}, white)
+	// almost white pixel
+	src.SetNRGBA(5, 0, color.NRGBA{250, 250, 250, 255})
+
+	td := []struct {
+		desc      string
+		start     image.Point
+		fill      color.NRGBA
+		tolerance float64
+		want      []string
+	}{
+		{
+			"FloodFill top left",
+			image.Pt(-1, -1),
+			red,
+			0.0,
+			[]string{
+				"xx.....",
+				"xx.....",
+				".......",
+				".......",
+			},
+		},
+		{
+			"FloodFill right exact",
+			image.Pt(2, 1),
+			red,
+			0.0,
+			[]string{
+				"...xxxx",
+				"...x...",
+				"...x...",
+				"xxxx...",
+			},
+		},
+		{
+			"FloodFill right tolerance",
+			image.Pt(2, 1),
+			red,
+			0.05,
+			[]string{
+				"...xxxx",
+				"...x..x",
+				"...x.xx",
+				"xxxx.x.",
+			},
+		},
+		{
+			"FloodFill same color",
+			image.Pt(0, 0),
+			white,
+			0.0,
+			[]string{},
+		},
+		{
+			"FloodFill outside",
+			image.Pt(10, 0),
+			red,
+			1.0,
+			[]string{},
+		},
+	}
+	for _, d := range td {
+		got := Clone(src)
+		got.Rect = src.Rect
+		FloodFill(got, d.start, d.fill, d.tolerance)
+		want := Clone(src)
+		want.Rect = src.Rect
+		for y, row := range d.want {
+			for x := range row {
+				if row[x] == 'x' {
+					want.SetNRGBA(want.Rect.Min.X+x, want.Rect.Min.Y+y, d.fill)
+				}
+			}
+		}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	// the whole image with the maximum tolerance
+	got := Clone(src)
+	FloodFill(got, image.Pt(3, 3), red, 1.0)
+	if !compareNRGBA(got, New(7, 4, red), 0) {
+		t.Errorf("test [FloodFill tolerance 1] failed: %#v", got)
+	}
+}
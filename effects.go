@@ -2,7 +2,9 @@ package imaging
 
 import (
 	"image"
+	"image/color"
 	"math"
+	"sort"
 )
 
 func gaussianBlurKernel(x, sigma float64) float64 {
@@ -37,6 +39,91 @@ func Blur(img image.Image, sigma float64) *image.NRGBA {
 	return dst
 }
 
+// BlurRect blurs the rectangular region of the image like Blur does and returns the result.
+// The rectangle is clipped to the image bounds, the pixels outside of it are unchanged,
+// but they are used when blurring the pixels near the region edges.
+//
+// Usage example:
+//
+//		// blur the detected face
+//		dstImage := imaging.BlurRect(srcImage, faceRect, 8.0)
+//
+func BlurRect(img image.Image, rect image.Rectangle, sigma float64) *image.NRGBA {
+	b := img.Bounds()
+	r := rect.Intersect(b)
+	if sigma <= 0 || r.Empty() {
+		return Clone(img)
+	}
+
+	// blur the region with the margin of the kernel radius so the edges blend with the surroundings
+	radius := int(math.Ceil(sigma * 3.0))
+	outer := r.Inset(-radius).Intersect(b)
+	blurred := Blur(Crop(img, outer), sigma)
+	return Paste(img, blurred.SubImage(r.Sub(outer.Min)), r.Min)
+}
+
+// BlurEdge produces a blurred version of the image using a Gaussian function like Blur does,
+// but the pixels outside of the image bounds are sampled according to the edge mode instead of
+// truncating the kernel. EdgeReflect gives the results similar to the image editing software,
+// EdgeWrap is useful for the tileable textures.
+//
+// Usage example:
+//
+//		dstImage := imaging.BlurEdge(srcImage, 3.5, imaging.EdgeWrap)
+//
+func BlurEdge(img image.Image, sigma float64, edge EdgeMode) *image.NRGBA {
+	if sigma <= 0 {
+		// sigma parameter must be positive!
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	radius := int(math.Ceil(sigma * 3.0))
+	kernel := make([]float64, radius+1)
+
+	for i := 0; i <= radius; i++ {
+		kernel[i] = gaussianBlurKernel(float64(i), sigma)
+	}
+
+	// the kernel never reaches the borders of the padded image from the original pixels
+	dst := padEdges(src, radius, edge)
+	dst = blurHorizontal(dst, kernel)
+	dst = blurVertical(dst, kernel)
+
+	return Crop(dst, src.Bounds().Add(image.Pt(radius, radius)))
+}
+
+// padEdges returns a copy of the image extended by n pixels on each side,
+// the new pixels are sampled according to the edge mode.
+func padEdges(src *image.NRGBA, n int, edge EdgeMode) *image.NRGBA {
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewNRGBA(image.Rect(0, 0, width+2*n, height+2*n))
+	if width == 0 || height == 0 {
+		return dst
+	}
+
+	parallel(dst.Bounds().Max.Y, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			sy, ok := edgeCoord(y-n, height, edge)
+			if !ok {
+				continue
+			}
+			for x := 0; x < dst.Bounds().Max.X; x++ {
+				sx, ok := edgeCoord(x-n, width, edge)
+				if !ok {
+					continue
+				}
+				i := sy*src.Stride + sx*4
+				j := y*dst.Stride + x*4
+				copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+			}
+		}
+	})
+
+	return dst
+}
+
 func blurHorizontal(src *image.NRGBA, kernel []float64) *image.NRGBA {
 	radius := len(kernel) - 1
 	width := src.Bounds().Max.X
@@ -44,27 +131,36 @@ func blurHorizontal(src *image.NRGBA, kernel []float64) *image.NRGBA {
 
 	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
 
-	parallel(width, func(partStart, partEnd int) {
-		for x := partStart; x < partEnd; x++ {
-			start := x - radius
-			if start < 0 {
-				start = 0
-			}
+	// the sampling window and the weights sum depend on the column only,
+	// so they are computed once and then the rows are processed in parallel
+	starts := make([]int, width)
+	ends := make([]int, width)
+	weightSums := make([]float64, width)
+	for x := 0; x < width; x++ {
+		start := x - radius
+		if start < 0 {
+			start = 0
+		}
 
-			end := x + radius
-			if end > width-1 {
-				end = width - 1
-			}
+		end := x + radius
+		if end > width-1 {
+			end = width - 1
+		}
 
-			weightSum := 0.0
-			for ix := start; ix <= end; ix++ {
-				weightSum += kernel[absint(x-ix)]
-			}
+		weightSum := 0.0
+		for ix := start; ix <= end; ix++ {
+			weightSum += kernel[absint(x-ix)]
+		}
 
-			for y := 0; y < height; y++ {
+		starts[x], ends[x], weightSums[x] = start, end, weightSum
+	}
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
 
 				r, g, b, a := 0.0, 0.0, 0.0, 0.0
-				for ix := start; ix <= end; ix++ {
+				for ix := starts[x]; ix <= ends[x]; ix++ {
 					weight := kernel[absint(x-ix)]
 					i := y*src.Stride + ix*4
 					r += float64(src.Pix[i+0]) * weight
@@ -73,6 +169,7 @@ func blurHorizontal(src *image.NRGBA, kernel []float64) *image.NRGBA {
 					a += float64(src.Pix[i+3]) * weight
 				}
 
+				weightSum := weightSums[x]
 				r = math.Min(math.Max(r/weightSum, 0.0), 255.0)
 				g = math.Min(math.Max(g/weightSum, 0.0), 255.0)
 				b = math.Min(math.Max(b/weightSum, 0.0), 255.0)
@@ -145,6 +242,140 @@ func blurVertical(src *image.NRGBA, kernel []float64) *image.NRGBA {
 	return dst
 }
 
+// BoxBlur produces a blurred version of the image using a box filter (the average of the neighbour pixels).
+// Radius parameter must be positive and indicates the size of the averaging window. The running sum
+// is used, so the processing time doesn't depend on the radius. The window is clamped to the image bounds.
+// Applying the box blur three times gives a good approximation of the Gaussian blur.
+//
+// Usage examples:
+//
+//		dstImage := imaging.BoxBlur(srcImage, 5)
+//
+//		// approximate gaussian blur
+//		dstImage := imaging.BoxBlur(imaging.BoxBlur(imaging.BoxBlur(srcImage, 3), 3), 3)
+//
+func BoxBlur(img image.Image, radius float64) *image.NRGBA {
+	r := int(radius + 0.5)
+	if r <= 0 {
+		// radius parameter must be positive!
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	tmp := image.NewNRGBA(image.Rect(0, 0, width, height))
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			boxBlurLine(src.Pix[y*src.Stride:], tmp.Pix[y*tmp.Stride:], width, 4, r)
+		}
+	})
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	parallel(width, func(partStart, partEnd int) {
+		for x := partStart; x < partEnd; x++ {
+			boxBlurLine(tmp.Pix[x*4:], dst.Pix[x*4:], height, tmp.Stride, r)
+		}
+	})
+
+	return dst
+}
+
+// boxBlurLine averages n pixels of the src line with the step between pixels
+// using the running sum and writes the result to the dst line.
+func boxBlurLine(src, dst []uint8, n, step, radius int) {
+	var sum [4]int
+	count := 0
+
+	// initial window for the first pixel
+	for i := 0; i <= radius && i < n; i++ {
+		for c := 0; c < 4; c++ {
+			sum[c] += int(src[i*step+c])
+		}
+		count++
+	}
+
+	for i := 0; i < n; i++ {
+		for c := 0; c < 4; c++ {
+			dst[i*step+c] = uint8((sum[c] + count/2) / count)
+		}
+
+		// slide the window: add the incoming pixel and remove the outgoing one
+		if in := i + radius + 1; in < n {
+			for c := 0; c < 4; c++ {
+				sum[c] += int(src[in*step+c])
+			}
+			count++
+		}
+		if out := i - radius; out >= 0 {
+			for c := 0; c < 4; c++ {
+				sum[c] -= int(src[out*step+c])
+			}
+			count--
+		}
+	}
+}
+
+// MotionBlur produces a directional blur of the image by averaging the pixels along the line
+// of the given length (in pixels) and angle (in degrees, counter-clockwise, 0 is horizontal).
+// The line is sampled using bilinear interpolation. Length = 0 gives the original image.
+//
+// Usage example:
+//
+//		dstImage := imaging.MotionBlur(srcImage, 15, 45)
+//
+func MotionBlur(img image.Image, length, angle float64) *image.NRGBA {
+	if length <= 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	n := int(math.Ceil(length)) + 1
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	offsets := make([][2]float64, n)
+	for k := 0; k < n; k++ {
+		t := -length/2 + length*float64(k)/float64(n-1)
+		offsets[k] = [2]float64{t * cos, -t * sin}
+	}
+
+	maxX := float64(width - 1)
+	maxY := float64(height - 1)
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b, a float64
+				for _, o := range offsets {
+					sx := math.Min(math.Max(float64(x)+o[0], 0), maxX)
+					sy := math.Min(math.Max(float64(y)+o[1], 0), maxY)
+					c := interpolateBilinear(src, sx, sy, color.NRGBA{})
+					ca := float64(c.A)
+					r += float64(c.R) * ca
+					g += float64(c.G) * ca
+					b += float64(c.B) * ca
+					a += ca
+				}
+
+				j := y*dst.Stride + x*4
+				if a == 0 {
+					continue
+				}
+				dst.Pix[j+0] = clamp(r / a)
+				dst.Pix[j+1] = clamp(g / a)
+				dst.Pix[j+2] = clamp(b / a)
+				dst.Pix[j+3] = clamp(a / float64(n))
+			}
+		}
+	})
+
+	return dst
+}
+
 // Sharpen produces a sharpened version of the image.
 // Sigma parameter must be positive and indicates how much the image will be sharpened.
 //
@@ -185,3 +416,770 @@ func Sharpen(img image.Image, sigma float64) *image.NRGBA {
 
 	return dst
 }
+
+// UnsharpMask produces a sharpened version of the image using the unsharp masking technique.
+// The image is blurred with the Gaussian function of the given radius (sigma), the difference
+// between the original and the blurred image is multiplied by the amount and added to the original
+// image. Pixels where the difference is not greater than the threshold (0..255) are left unchanged.
+// The alpha channel is preserved.
+//
+// Usage example:
+//
+//		dstImage := imaging.UnsharpMask(srcImage, 2.0, 0.8, 3)
+//
+func UnsharpMask(img image.Image, radius, amount, threshold float64) *image.NRGBA {
+	if radius <= 0 || amount == 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	blurred := Blur(src, radius)
+
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				i := y*src.Stride + x*4
+				for j := 0; j < 3; j++ {
+					k := i + j
+					diff := float64(src.Pix[k]) - float64(blurred.Pix[k])
+					if math.Abs(diff) <= threshold {
+						dst.Pix[k] = src.Pix[k]
+						continue
+					}
+					dst.Pix[k] = clamp(float64(src.Pix[k]) + amount*diff)
+				}
+				dst.Pix[i+3] = src.Pix[i+3]
+			}
+		}
+	})
+
+	return dst
+}
+
+// Median produces a denoised version of the image by replacing each pixel with the median
+// of the (2*radius+1)x(2*radius+1) neighbourhood, computed for each channel separately.
+// The image edges are extended by repeating the edge pixels. Sliding histograms are used,
+// so the processing time per pixel is proportional to the radius rather than its square.
+//
+// Usage example:
+//
+//		dstImage := imaging.Median(srcImage, 1)
+//
+func Median(img image.Image, radius int) *image.NRGBA {
+	if radius <= 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	clampCoord := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > max-1 {
+			return max - 1
+		}
+		return v
+	}
+
+	size := 2*radius + 1
+	half := size * size / 2
+
+	parallel(height, func(partStart, partEnd int) {
+		var hist [4][256]int
+
+		// addColumn adds (or removes if delta is negative) the column of the neighbourhood
+		addColumn := func(x, y, delta int) {
+			x = clampCoord(x, width)
+			for iy := y - radius; iy <= y+radius; iy++ {
+				i := clampCoord(iy, height)*src.Stride + x*4
+				for c := 0; c < 4; c++ {
+					hist[c][src.Pix[i+c]] += delta
+				}
+			}
+		}
+
+		for y := partStart; y < partEnd; y++ {
+			hist = [4][256]int{}
+			for ix := -radius; ix <= radius; ix++ {
+				addColumn(ix, y, 1)
+			}
+
+			for x := 0; x < width; x++ {
+				j := y*dst.Stride + x*4
+				for c := 0; c < 4; c++ {
+					cnt := 0
+					for v := 0; v < 256; v++ {
+						cnt += hist[c][v]
+						if cnt > half {
+							dst.Pix[j+c] = uint8(v)
+							break
+						}
+					}
+				}
+
+				addColumn(x-radius, y, -1)
+				addColumn(x+radius+1, y, 1)
+			}
+		}
+	})
+
+	return dst
+}
+
+// Emboss produces an embossed version of the image using the 3x3 emboss kernel.
+//
+// Usage example:
+//
+//		dstImage := imaging.Emboss(srcImage)
+//
+func Emboss(img image.Image) *image.NRGBA {
+	kernel := []float64{
+		-2, -1, 0,
+		-1, 1, 1,
+		0, 1, 2,
+	}
+	return convolve(toNRGBA(img), kernel, 3, 1, EdgeClamp)
+}
+
+// EdgeDetect produces an image with the edges of the source image highlighted
+// using the 3x3 Laplacian kernel. Flat areas of the image become black.
+//
+// Usage example:
+//
+//		dstImage := imaging.EdgeDetect(srcImage)
+//
+func EdgeDetect(img image.Image) *image.NRGBA {
+	kernel := []float64{
+		-1, -1, -1,
+		-1, 8, -1,
+		-1, -1, -1,
+	}
+	return convolve(toNRGBA(img), kernel, 3, 1, EdgeClamp)
+}
+
+// Sharpen3x3 produces a sharpened version of the image using the 3x3 sharpening kernel.
+// It's faster than Sharpen but the strength of the effect is fixed.
+//
+// Usage example:
+//
+//		dstImage := imaging.Sharpen3x3(srcImage)
+//
+func Sharpen3x3(img image.Image) *image.NRGBA {
+	kernel := []float64{
+		0, -1, 0,
+		-1, 5, -1,
+		0, -1, 0,
+	}
+	return convolve(toNRGBA(img), kernel, 3, 1, EdgeClamp)
+}
+
+// EdgeMode specifies how the pixels outside of the image bounds are sampled by Convolve.
+type EdgeMode int
+
+const (
+	// EdgeClamp extends the image by repeating the edge pixels.
+	EdgeClamp EdgeMode = iota
+	// EdgeWrap wraps the image around, as if it was tiled.
+	EdgeWrap
+	// EdgeZero treats the pixels outside of the image as black.
+	EdgeZero
+	// EdgeReflect mirrors the image across its edges, the edge pixels are repeated once.
+	EdgeReflect
+)
+
+// Convolve applies the custom convolution kernel to the color channels of the image and returns
+// the filtered image. The kernel is a flat slice of size*size weights in row-major order, size must be
+// a positive odd number. If normalize is true, the weighted sum is divided by the sum of the kernel weights
+// (unless it's zero). The edge parameter specifies how the pixels outside of the image are sampled.
+// The alpha channel is preserved. If the kernel is invalid, a copy of the original image is returned.
+//
+// Usage example:
+//
+//		// 3x3 box blur
+//		kernel := []float64{
+//			1, 1, 1,
+//			1, 1, 1,
+//			1, 1, 1,
+//		}
+//		dstImage := imaging.Convolve(srcImage, kernel, 3, true, imaging.EdgeClamp)
+//
+func Convolve(img image.Image, kernel []float64, size int, normalize bool, edge EdgeMode) *image.NRGBA {
+	if size <= 0 || size%2 == 0 || len(kernel) != size*size {
+		return Clone(img)
+	}
+
+	divisor := 1.0
+	if normalize {
+		sum := 0.0
+		for _, w := range kernel {
+			sum += w
+		}
+		if math.Abs(sum) > 1e-9 {
+			divisor = sum
+		}
+	}
+
+	return convolve(toNRGBA(img), kernel, size, divisor, edge)
+}
+
+// edgeCoord maps the coordinate to the range (0, max-1) according to the edge mode.
+// It returns false if the pixel should be treated as black.
+func edgeCoord(v, max int, edge EdgeMode) (int, bool) {
+	if v >= 0 && v < max {
+		return v, true
+	}
+	switch edge {
+	case EdgeWrap:
+		v %= max
+		if v < 0 {
+			v += max
+		}
+		return v, true
+	case EdgeZero:
+		return 0, false
+	case EdgeReflect:
+		v %= 2 * max
+		if v < 0 {
+			v += 2 * max
+		}
+		if v >= max {
+			v = 2*max - 1 - v
+		}
+		return v, true
+	}
+	if v < 0 {
+		return 0, true
+	}
+	return max - 1, true
+}
+
+// convolve applies the size x size kernel to the color channels of the image and divides
+// the result by the divisor. The alpha channel is preserved.
+func convolve(src *image.NRGBA, kernel []float64, size int, divisor float64, edge EdgeMode) *image.NRGBA {
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	half := size / 2
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b float64
+				for ky := 0; ky < size; ky++ {
+					iy, ok := edgeCoord(y+ky-half, height, edge)
+					if !ok {
+						continue
+					}
+					for kx := 0; kx < size; kx++ {
+						w := kernel[ky*size+kx]
+						if w == 0 {
+							continue
+						}
+						ix, ok := edgeCoord(x+kx-half, width, edge)
+						if !ok {
+							continue
+						}
+						i := iy*src.Stride + ix*4
+						r += float64(src.Pix[i+0]) * w
+						g += float64(src.Pix[i+1]) * w
+						b += float64(src.Pix[i+2]) * w
+					}
+				}
+
+				i := y*src.Stride + x*4
+				j := y*dst.Stride + x*4
+				dst.Pix[j+0] = clamp(r / divisor)
+				dst.Pix[j+1] = clamp(g / divisor)
+				dst.Pix[j+2] = clamp(b / divisor)
+				dst.Pix[j+3] = src.Pix[i+3]
+			}
+		}
+	})
+
+	return dst
+}
+
+// Sobel computes the Sobel gradient magnitude of the image luminance and returns it as a grayscale image.
+// The magnitude is scaled so that the strongest gradient of the image is 255. If the image is uniform
+// the result is black.
+//
+// Usage example:
+//
+//		edges := imaging.Sobel(srcImage)
+//
+func Sobel(img image.Image) *image.Gray {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	mag := sobelMagnitude(src)
+	max := 0.0
+	for _, m := range mag {
+		max = math.Max(max, m)
+	}
+	if max == 0 {
+		return dst
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Pix[y*dst.Stride+x] = clamp(mag[y*width+x] / max * 255.0)
+		}
+	}
+
+	return dst
+}
+
+// SobelXY computes the horizontal and vertical Sobel gradients of the image luminance
+// and returns them as grayscale images. The signed gradient values are scaled down by 8
+// and offset by 128, so 128 means no gradient, values above 128 mean the luminance increases
+// to the right (gx) or to the bottom (gy) and values below 128 mean it decreases.
+//
+// Usage example:
+//
+//		gx, gy := imaging.SobelXY(srcImage)
+//
+func SobelXY(img image.Image) (gx, gy *image.Gray) {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	gx = image.NewGray(image.Rect(0, 0, width, height))
+	gy = image.NewGray(image.Rect(0, 0, width, height))
+
+	dx, dy := sobelGradients(src)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gx.Pix[y*gx.Stride+x] = clamp(128.0 + dx[y*width+x]/8.0)
+			gy.Pix[y*gy.Stride+x] = clamp(128.0 + dy[y*width+x]/8.0)
+		}
+	}
+
+	return gx, gy
+}
+
+// Dither converts the image to a paletted image using the colors of the given palette
+// and the Floyd-Steinberg error diffusion. The image is processed row by row from left to right.
+// The palette must not be empty.
+//
+// Usage example:
+//
+//		dstImage := imaging.Dither(srcImage, palette.Plan9)
+//
+func Dither(img image.Image, palette color.Palette) *image.Paletted {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	if len(palette) == 0 {
+		return dst
+	}
+
+	colors := make([]color.NRGBA, len(palette))
+	for i, c := range palette {
+		colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+
+	// quantization errors of the current and the next row, with a margin of one pixel on each side
+	cur := make([][4]float64, width+2)
+	next := make([][4]float64, width+2)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			var v [4]float64
+			for c := 0; c < 4; c++ {
+				v[c] = math.Min(math.Max(float64(src.Pix[i+c])+cur[x+1][c], 0.0), 255.0)
+			}
+
+			k := palette.Index(color.NRGBA{uint8(v[0] + 0.5), uint8(v[1] + 0.5), uint8(v[2] + 0.5), uint8(v[3] + 0.5)})
+			dst.Pix[y*dst.Stride+x] = uint8(k)
+
+			p := colors[k]
+			e := [4]float64{v[0] - float64(p.R), v[1] - float64(p.G), v[2] - float64(p.B), v[3] - float64(p.A)}
+			for c := 0; c < 4; c++ {
+				cur[x+2][c] += e[c] * 7 / 16
+				next[x][c] += e[c] * 3 / 16
+				next[x+1][c] += e[c] * 5 / 16
+				next[x+2][c] += e[c] * 1 / 16
+			}
+		}
+		cur, next = next, cur
+		for x := range next {
+			next[x] = [4]float64{}
+		}
+	}
+
+	return dst
+}
+
+// DitherBW converts the image to a black and white paletted image using the Floyd-Steinberg error diffusion.
+//
+// Usage example:
+//
+//		dstImage := imaging.DitherBW(srcImage)
+//
+func DitherBW(img image.Image) *image.Paletted {
+	return Dither(img, color.Palette{color.Black, color.White})
+}
+
+// Quantize converts the image to a paletted image using the adaptive palette of at most n colors
+// (from 1 to 256) computed by QuantizePalette. Each pixel is replaced by the closest palette color
+// without dithering. Use Dither with the QuantizePalette result to get the dithered image instead.
+//
+// Usage example:
+//
+//		dstImage := imaging.Quantize(srcImage, 64)
+//		err := gif.Encode(w, dstImage, nil)
+//
+func Quantize(img image.Image, n int) *image.Paletted {
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+	palette := QuantizePalette(src, n)
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	if len(palette) == 0 {
+		return dst
+	}
+
+	indices := make(map[color.NRGBA]uint8)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			c := color.NRGBA{src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			k, ok := indices[c]
+			if !ok {
+				k = uint8(palette.Index(c))
+				indices[c] = k
+			}
+			dst.Pix[y*dst.Stride+x] = k
+		}
+	}
+
+	return dst
+}
+
+// QuantizePalette returns the adaptive palette of at most n colors (from 1 to 256) for the image
+// computed using the median cut algorithm: the box of the image colors is split recursively
+// at the median of its longest side, and each palette color is the average of the colors in a box.
+// If the image has fully transparent pixels, the first palette entry is reserved for the transparent color.
+//
+// Usage example:
+//
+//		dstImage := imaging.Dither(srcImage, imaging.QuantizePalette(srcImage, 16))
+//
+func QuantizePalette(img image.Image, n int) color.Palette {
+	if n < 1 {
+		n = 1
+	} else if n > 256 {
+		n = 256
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Max.X
+	height := src.Bounds().Max.Y
+
+	hist := make(map[color.NRGBA]int)
+	transparent := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*src.Stride + x*4
+			if src.Pix[i+3] == 0 {
+				transparent = true
+				continue
+			}
+			hist[color.NRGBA{src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}]++
+		}
+	}
+
+	var palette color.Palette
+	if transparent {
+		palette = append(palette, color.NRGBA{})
+		n--
+	}
+	if n == 0 || len(hist) == 0 {
+		return palette
+	}
+
+	colors := make([]quantColor, 0, len(hist))
+	for c, count := range hist {
+		colors = append(colors, quantColor{[4]uint8{c.R, c.G, c.B, c.A}, count})
+	}
+	// sort for the deterministic result
+	sort.Slice(colors, func(i, j int) bool { return colors[i].less(colors[j], 0) })
+
+	boxes := []quantBox{newQuantBox(colors)}
+	for len(boxes) < n {
+		// split the box with the longest side
+		best := -1
+		for i, b := range boxes {
+			if len(b.colors) > 1 && (best < 0 || b.size() > boxes[best].size()) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b1, b2 := boxes[best].split()
+		boxes[best] = b1
+		boxes = append(boxes, b2)
+	}
+
+	for _, b := range boxes {
+		palette = append(palette, b.average())
+	}
+	return palette
+}
+
+type quantColor struct {
+	c     [4]uint8
+	count int
+}
+
+// less compares the colors by the channel ch first and by the other channels then.
+func (a quantColor) less(b quantColor, ch int) bool {
+	if a.c[ch] != b.c[ch] {
+		return a.c[ch] < b.c[ch]
+	}
+	for k := 0; k < 4; k++ {
+		if a.c[k] != b.c[k] {
+			return a.c[k] < b.c[k]
+		}
+	}
+	return false
+}
+
+type quantBox struct {
+	colors   []quantColor
+	min, max [4]uint8
+}
+
+func newQuantBox(colors []quantColor) quantBox {
+	b := quantBox{colors: colors, min: colors[0].c, max: colors[0].c}
+	for _, c := range colors[1:] {
+		for k := 0; k < 4; k++ {
+			if c.c[k] < b.min[k] {
+				b.min[k] = c.c[k]
+			}
+			if c.c[k] > b.max[k] {
+				b.max[k] = c.c[k]
+			}
+		}
+	}
+	return b
+}
+
+// longest returns the channel of the longest side of the box and its length.
+func (b quantBox) longest() (int, int) {
+	ch, size := 0, -1
+	for k := 0; k < 4; k++ {
+		if s := int(b.max[k]) - int(b.min[k]); s > size {
+			ch, size = k, s
+		}
+	}
+	return ch, size
+}
+
+func (b quantBox) size() int {
+	_, size := b.longest()
+	return size
+}
+
+// split splits the box at the median pixel along its longest side.
+func (b quantBox) split() (quantBox, quantBox) {
+	ch, _ := b.longest()
+	sort.Slice(b.colors, func(i, j int) bool { return b.colors[i].less(b.colors[j], ch) })
+
+	total := 0
+	for _, c := range b.colors {
+		total += c.count
+	}
+	m, sum := 1, b.colors[0].count
+	for m < len(b.colors)-1 && sum*2 < total {
+		sum += b.colors[m].count
+		m++
+	}
+	return newQuantBox(b.colors[:m]), newQuantBox(b.colors[m:])
+}
+
+// average returns the average color of the box weighted by the pixel counts.
+func (b quantBox) average() color.NRGBA {
+	var sum [4]float64
+	total := 0.0
+	for _, c := range b.colors {
+		w := float64(c.count)
+		for k := 0; k < 4; k++ {
+			sum[k] += float64(c.c[k]) * w
+		}
+		total += w
+	}
+	return color.NRGBA{clamp(sum[0] / total), clamp(sum[1] / total), clamp(sum[2] / total), clamp(sum[3] / total)}
+}
+
+// Vignette darkens the image towards its borders and returns the result. The pixels are darkened
+// depending on their distance from the image center using a smooth cosine falloff, the strength
+// parameter (from 0.0 to 1.0) is the amount of darkening at the corners. The R, G and B channels
+// of a pixel are scaled by the same factor, so the hue is preserved. The alpha channel is preserved.
+//
+// Usage example:
+//
+//		dstImage := imaging.Vignette(srcImage, 0.5)
+//
+func Vignette(img image.Image, strength float64) *image.NRGBA {
+	strength = math.Min(math.Max(strength, 0.0), 1.0)
+	src := toNRGBA(img)
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	halfW, halfH := float64(width)/2.0, float64(height)/2.0
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			ny := (float64(y) + 0.5 - halfH) / halfH
+			for x := 0; x < width; x++ {
+				nx := (float64(x) + 0.5 - halfW) / halfW
+				// normalized distance from the center, 1.0 at the corners
+				d := math.Sqrt((nx*nx + ny*ny) / 2.0)
+				f := 1.0 - strength*(1.0-math.Cos(math.Pi/2.0*d))
+
+				i := y*src.Stride + x*4
+				j := y*dst.Stride + x*4
+				dst.Pix[j+0] = clamp(float64(src.Pix[i+0]) * f)
+				dst.Pix[j+1] = clamp(float64(src.Pix[i+1]) * f)
+				dst.Pix[j+2] = clamp(float64(src.Pix[i+2]) * f)
+				dst.Pix[j+3] = src.Pix[i+3]
+			}
+		}
+	})
+
+	return dst
+}
+
+// Pixelate produces the mosaic version of the image by filling each blockSize x blockSize block
+// of pixels with the average color of the block (computed like AverageColor does). The partial
+// blocks at the right and bottom edges are averaged over their own pixels only.
+// If blockSize is less than 2, a copy of the image is returned.
+//
+// Usage example:
+//
+//		dstImage := imaging.Pixelate(srcImage, 16)
+//
+func Pixelate(img image.Image, blockSize int) *image.NRGBA {
+	src := toNRGBA(img)
+	if blockSize < 2 {
+		return Clone(src)
+	}
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	blocksY := (height + blockSize - 1) / blockSize
+	parallel(blocksY, func(partStart, partEnd int) {
+		for by := partStart; by < partEnd; by++ {
+			for x0 := 0; x0 < width; x0 += blockSize {
+				r := image.Rect(x0, by*blockSize, x0+blockSize, (by+1)*blockSize).Intersect(dst.Bounds())
+				c := AverageColorRect(src, r)
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					for x := r.Min.X; x < r.Max.X; x++ {
+						i := y*dst.Stride + x*4
+						dst.Pix[i+0], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+					}
+				}
+			}
+		}
+	})
+
+	return dst
+}
+
+// PixelateRect pixelates the rectangular region of the image like Pixelate does and returns
+// the result. The blocks are aligned to the top-left corner of the region. The rectangle is
+// clipped to the image bounds, the pixels outside of it are unchanged.
+//
+// Usage example:
+//
+//		dstImage := imaging.PixelateRect(srcImage, image.Rect(100, 50, 200, 150), 12)
+//
+func PixelateRect(img image.Image, rect image.Rectangle, blockSize int) *image.NRGBA {
+	return ApplyRect(img, rect, func(img image.Image) *image.NRGBA {
+		return Pixelate(img, blockSize)
+	})
+}
+
+// luminanceMap returns the luminance values (0..255) of the image pixels in row-major order.
+func luminanceMap(src *image.NRGBA) []float64 {
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	lum := make([]float64, width*height)
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				i := y*src.Stride + x*4
+				lum[y*width+x] = luma(src.Pix[i+0], src.Pix[i+1], src.Pix[i+2])
+			}
+		}
+	})
+
+	return lum
+}
+
+// sobelGradients computes the horizontal and vertical Sobel gradients of the image luminance.
+// The image edges are extended by repeating the edge pixels.
+func sobelGradients(src *image.NRGBA) (gx, gy []float64) {
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	lum := luminanceMap(src)
+	gx = make([]float64, width*height)
+	gy = make([]float64, width*height)
+
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x > width-1 {
+			x = width - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y > height-1 {
+			y = height - 1
+		}
+		return lum[y*width+x]
+	}
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				tl, t, tr := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+				l, r := at(x-1, y), at(x+1, y)
+				bl, b, br := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+
+				gx[y*width+x] = (tr + 2*r + br) - (tl + 2*l + bl)
+				gy[y*width+x] = (bl + 2*b + br) - (tl + 2*t + tr)
+			}
+		}
+	})
+
+	return gx, gy
+}
+
+// sobelMagnitude computes the Sobel gradient magnitude of the image luminance.
+func sobelMagnitude(src *image.NRGBA) []float64 {
+	gx, gy := sobelGradients(src)
+	for i := range gx {
+		gx[i] = math.Sqrt(gx[i]*gx[i] + gy[i]*gy[i])
+	}
+	return gx
+}
@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -67,6 +69,255 @@ func TestBlur(t *testing.T) {
 	}
 }
 
+func TestBlurEdge(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8((i * 37) % 256)
+	}
+	sigma := 1.2 // the kernel radius 4 doesn't exceed the image size
+
+	// the tiled images contain the pixels sampled by the edge mode around the central copy
+	wrapRow := AppendH(src, src, src)
+	reflectRow := AppendH(FlipH(src), src, FlipH(src))
+	center := image.Rect(5, 4, 10, 8)
+
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{"BlurEdge wrap", BlurEdge(src, sigma, EdgeWrap), Crop(Blur(AppendV(wrapRow, wrapRow, wrapRow), sigma), center)},
+		{"BlurEdge reflect", BlurEdge(src, sigma, EdgeReflect), Crop(Blur(AppendV(FlipV(reflectRow), reflectRow, FlipV(reflectRow)), sigma), center)},
+		{"BlurEdge zero", BlurEdge(src, sigma, EdgeZero), Crop(Blur(Pad(src, 15, 12, color.NRGBA{}, Center), sigma), center)},
+		{"BlurEdge clamp uniform", BlurEdge(New(6, 3, color.NRGBA{0x20, 0x40, 0x80, 0xff}), 2, EdgeClamp), New(6, 3, color.NRGBA{0x20, 0x40, 0x80, 0xff})},
+		{"BlurEdge sigma 0", BlurEdge(src, 0, EdgeReflect), Clone(src)},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+
+	// the edge pixels are repeated outside of the image
+	got := BlurEdge(AppendH(New(1, 1, color.Black), New(4, 1, color.White)), 1, EdgeClamp)
+	want := Blur(AppendH(New(10, 1, color.Black), New(8, 1, color.White)), 1)
+	if !compareNRGBA(got, Crop(want, image.Rect(9, 0, 14, 1)), 0) {
+		t.Errorf("test [BlurEdge clamp] failed: %#v", got)
+	}
+}
+
+func TestBlurSeparable(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 9, 7))
+	for i := range src.Pix {
+		src.Pix[i] = uint8((i * 37) % 256)
+	}
+	sigma := 1.3
+
+	// reference 2D gaussian convolution with the kernel clamped to the image bounds
+	radius := int(math.Ceil(sigma * 3.0))
+	want := image.NewNRGBA(src.Rect)
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 9; x++ {
+			var sum [4]float64
+			weightSum := 0.0
+			for iy := y - radius; iy <= y+radius; iy++ {
+				for ix := x - radius; ix <= x+radius; ix++ {
+					if ix < 0 || ix > 8 || iy < 0 || iy > 6 {
+						continue
+					}
+					w := gaussianBlurKernel(float64(x-ix), sigma) * gaussianBlurKernel(float64(y-iy), sigma)
+					i := iy*src.Stride + ix*4
+					for c := 0; c < 4; c++ {
+						sum[c] += float64(src.Pix[i+c]) * w
+					}
+					weightSum += w
+				}
+			}
+			j := y*want.Stride + x*4
+			for c := 0; c < 4; c++ {
+				want.Pix[j+c] = uint8(sum[c]/weightSum + 0.5)
+			}
+		}
+	}
+
+	got := Blur(src, sigma)
+	if !compareNRGBA(got, want, 1) {
+		t.Errorf("test [Blur 9x7 separable] failed: %#v", got)
+	}
+}
+
+func BenchmarkBlur(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Blur(src, 5)
+	}
+}
+
+func TestBoxBlur(t *testing.T) {
+	td := []struct {
+		desc   string
+		src    image.Image
+		radius float64
+		want   *image.NRGBA
+	}{
+		{
+			"BoxBlur 3x3 1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x90, 0x48, 0x24, 0xff, 0x00, 0x00, 0x00, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x24, 0x12, 0x09, 0x40, 0x18, 0x0c, 0x06, 0x2b, 0x24, 0x12, 0x09, 0x40,
+					0x18, 0x0c, 0x06, 0x2b, 0x10, 0x08, 0x04, 0x1c, 0x18, 0x0c, 0x06, 0x2b,
+					0x24, 0x12, 0x09, 0x40, 0x18, 0x0c, 0x06, 0x2b, 0x24, 0x12, 0x09, 0x40,
+				},
+			},
+		},
+		{
+			"BoxBlur 3x1 10",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0xff, 0xff,
+				},
+			},
+			10,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x30, 0x60, 0x85, 0xff, 0x30, 0x60, 0x85, 0xff, 0x30, 0x60, 0x85, 0xff,
+				},
+			},
+		},
+		{
+			"BoxBlur 1x1 0",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x01, 0x02, 0x03, 0x04},
+			},
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x01, 0x02, 0x03, 0x04},
+			},
+		},
+	}
+	for _, d := range td {
+		got := BoxBlur(d.src, d.radius)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestMotionBlur(t *testing.T) {
+	td := []struct {
+		desc          string
+		src           image.Image
+		length, angle float64
+		want          *image.NRGBA
+	}{
+		{
+			"MotionBlur 3x1 2 0",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 0),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+			2, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x40, 0x90, 0xff, 0x30, 0x70, 0x60, 0xff, 0x50, 0xa0, 0x30, 0xff,
+				},
+			},
+		},
+		{
+			"MotionBlur 1x3 2 90",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 3),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff,
+					0x30, 0x60, 0x90, 0xff,
+					0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+			2, 90,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 3),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x10, 0x40, 0x90, 0xff,
+					0x30, 0x70, 0x60, 0xff,
+					0x50, 0xa0, 0x30, 0xff,
+				},
+			},
+		},
+		{
+			"MotionBlur 3x1 2 90",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+			2, 90,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"MotionBlur 3x1 0 0",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+			0, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x30, 0x90, 0xff, 0x30, 0x60, 0x90, 0xff, 0x60, 0xc0, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := MotionBlur(d.src, d.length, d.angle)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
 func TestSharpen(t *testing.T) {
 	td := []struct {
 		desc  string
@@ -126,3 +377,616 @@ func TestSharpen(t *testing.T) {
 		}
 	}
 }
+
+func TestUnsharpMask(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+			0x66, 0x66, 0x66, 0x66, 0x77, 0x77, 0x77, 0x77, 0x66, 0x66, 0x66, 0x66,
+			0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+		},
+	}
+	td := []struct {
+		desc                      string
+		radius, amount, threshold float64
+		want                      *image.NRGBA
+	}{
+		{
+			"UnsharpMask 3x3 0.5 1 0",
+			0.5, 1, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x66, 0x66, 0x66, 0x66, 0x64, 0x64, 0x64, 0x66, 0x66, 0x66, 0x66, 0x66,
+					0x64, 0x64, 0x64, 0x66, 0x7e, 0x7e, 0x7e, 0x77, 0x64, 0x64, 0x64, 0x66,
+					0x66, 0x66, 0x66, 0x66, 0x64, 0x64, 0x64, 0x66, 0x66, 0x66, 0x66, 0x66,
+				},
+			},
+		},
+		{
+			"UnsharpMask 3x3 0.5 2 5",
+			0.5, 2, 5,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+					0x66, 0x66, 0x66, 0x66, 0x85, 0x85, 0x85, 0x77, 0x66, 0x66, 0x66, 0x66,
+					0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+				},
+			},
+		},
+		{
+			"UnsharpMask 3x3 0 1 0",
+			0, 1, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+					0x66, 0x66, 0x66, 0x66, 0x77, 0x77, 0x77, 0x77, 0x66, 0x66, 0x66, 0x66,
+					0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := UnsharpMask(src, d.radius, d.amount, d.threshold)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	td := []struct {
+		desc   string
+		src    image.Image
+		radius int
+		want   *image.NRGBA
+	}{
+		{
+			"Median 3x3 1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff,
+					0x10, 0x10, 0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0x10, 0x10, 0x10, 0xff,
+					0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff,
+					0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff,
+					0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff, 0x10, 0x10, 0x10, 0xff,
+				},
+			},
+		},
+		{
+			"Median 5x1 1",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x01, 0x50, 0x00, 0xff, 0x02, 0x40, 0x00, 0xff, 0x03, 0x30, 0x00, 0xff, 0x04, 0x20, 0x00, 0xff, 0x05, 0x10, 0x00, 0xff,
+				},
+			},
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x01, 0x50, 0x00, 0xff, 0x02, 0x40, 0x00, 0xff, 0x03, 0x30, 0x00, 0xff, 0x04, 0x20, 0x00, 0xff, 0x05, 0x10, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Median 5x1 2 salt",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x20, 0x20, 0x20, 0xff, 0xff, 0xff, 0xff, 0xff, 0x20, 0x20, 0x20, 0xff, 0x00, 0x00, 0x00, 0xff, 0x20, 0x20, 0x20, 0xff,
+				},
+			},
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x20, 0x20, 0x20, 0xff, 0x20, 0x20, 0x20, 0xff, 0x20, 0x20, 0x20, 0xff, 0x20, 0x20, 0x20, 0xff, 0x20, 0x20, 0x20, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Median(d.src, d.radius)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestConvolvePresets(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x66, 0x66, 0x66, 0xff, 0x66, 0x66, 0x66, 0xff, 0x66, 0x66, 0x66, 0xff,
+			0x66, 0x66, 0x66, 0xff, 0x77, 0x77, 0x77, 0xff, 0x66, 0x66, 0x66, 0xff,
+			0x66, 0x66, 0x66, 0xff, 0x66, 0x66, 0x66, 0xff, 0x66, 0x66, 0x66, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		fn   func(image.Image) *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"Emboss 3x3",
+			Emboss,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x88, 0x88, 0x88, 0xff, 0x77, 0x77, 0x77, 0xff, 0x66, 0x66, 0x66, 0xff,
+					0x77, 0x77, 0x77, 0xff, 0x77, 0x77, 0x77, 0xff, 0x55, 0x55, 0x55, 0xff,
+					0x66, 0x66, 0x66, 0xff, 0x55, 0x55, 0x55, 0xff, 0x44, 0x44, 0x44, 0xff,
+				},
+			},
+		},
+		{
+			"EdgeDetect 3x3",
+			EdgeDetect,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff,
+					0x00, 0x00, 0x00, 0xff, 0x88, 0x88, 0x88, 0xff, 0x00, 0x00, 0x00, 0xff,
+					0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Sharpen3x3 3x3",
+			Sharpen3x3,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x66, 0x66, 0x66, 0xff, 0x55, 0x55, 0x55, 0xff, 0x66, 0x66, 0x66, 0xff,
+					0x55, 0x55, 0x55, 0xff, 0xbb, 0xbb, 0xbb, 0xff, 0x55, 0x55, 0x55, 0xff,
+					0x66, 0x66, 0x66, 0xff, 0x55, 0x55, 0x55, 0xff, 0x66, 0x66, 0x66, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := d.fn(src)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestSobel(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff,
+			0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff,
+		},
+	}
+
+	got := Sobel(src)
+	want := &image.Gray{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3,
+		Pix: []uint8{
+			0x00, 0xff, 0xff,
+			0x00, 0xff, 0xff,
+		},
+	}
+	if got.Rect != want.Rect || string(got.Pix) != string(want.Pix) {
+		t.Errorf("test [Sobel 3x2] failed: %#v", got)
+	}
+
+	gx, gy := SobelXY(src)
+	wantX := []uint8{0x80, 0xc0, 0xc0, 0x80, 0xc0, 0xc0}
+	wantY := []uint8{0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	if string(gx.Pix) != string(wantX) || string(gy.Pix) != string(wantY) {
+		t.Errorf("test [SobelXY 3x2] failed: %#v %#v", gx, gy)
+	}
+
+	uniform := Sobel(New(2, 2, color.NRGBA{0x40, 0x40, 0x40, 0xff}))
+	if string(uniform.Pix) != string([]uint8{0, 0, 0, 0}) {
+		t.Errorf("test [Sobel uniform] failed: %#v", uniform)
+	}
+}
+
+func TestConvolve(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x30, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x90, 0x00, 0x00, 0xff,
+		},
+	}
+	box := []float64{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	}
+	box5 := make([]float64, 25)
+	for i := range box5 {
+		box5[i] = 1
+	}
+	td := []struct {
+		desc      string
+		kernel    []float64
+		size      int
+		normalize bool
+		edge      EdgeMode
+		want      *image.NRGBA
+	}{
+		{
+			"Convolve 3x1 box clamp",
+			box, 3, true, EdgeClamp,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x40, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x80, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 box wrap",
+			box, 3, true, EdgeWrap,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x60, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x60, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 box zero",
+			box, 3, true, EdgeZero,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x00, 0x00, 0xff, 0x20, 0x00, 0x00, 0x80, 0x1b, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 5x5 box reflect",
+			box5, 5, true, EdgeReflect,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x56, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x6a, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 1x1 identity",
+			[]float64{0.5}, 1, true, EdgeZero,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x30, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x90, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 zero sum",
+			[]float64{0, 0, 0, -1, 0, 1, 0, 0, 0}, 3, true, EdgeClamp,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x30, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x30, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Convolve 3x1 invalid size",
+			box, 2, true, EdgeClamp,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x30, 0x00, 0x00, 0xff, 0x60, 0x00, 0x00, 0x80, 0x90, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Convolve(src, d.kernel, d.size, d.normalize, d.edge)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestDither(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff,
+		},
+	}
+	got := DitherBW(src)
+	want := []uint8{1, 0, 1, 0}
+	if got.Rect != image.Rect(0, 0, 4, 1) || string(got.Pix) != string(want) {
+		t.Errorf("test [DitherBW 4x1] failed: %#v", got)
+	}
+
+	red := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	palette := color.Palette{color.NRGBA{0x00, 0x00, 0x00, 0xff}, red}
+	got = Dither(New(3, 2, red), palette)
+	want = []uint8{1, 1, 1, 1, 1, 1}
+	if string(got.Pix) != string(want) {
+		t.Errorf("test [Dither 3x2 exact] failed: %#v", got)
+	}
+
+	got = Dither(src, nil)
+	if got.Rect != image.Rect(0, 0, 4, 1) {
+		t.Errorf("test [Dither empty palette] failed: %#v", got)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	c1 := color.NRGBA{0x10, 0x10, 0x10, 0xff}
+	c2 := color.NRGBA{0x12, 0x12, 0x12, 0xff}
+	c3 := color.NRGBA{0xc8, 0xc8, 0xc8, 0xff}
+	c4 := color.NRGBA{0xca, 0xca, 0xca, 0x80}
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i, c := range []color.NRGBA{c1, c2, c3, c4, {0xff, 0xff, 0xff, 0x00}, {0x01, 0x02, 0x03, 0x00}} {
+		src.SetNRGBA(i%3, i/3, c)
+	}
+	transparent := color.NRGBA{}
+
+	td := []struct {
+		desc        string
+		n           int
+		wantPalette color.Palette
+		wantPix     []uint8
+	}{
+		{
+			"Quantize 8",
+			8,
+			color.Palette{transparent, c1, c2, c3, c4},
+			[]uint8{1, 2, 3, 4, 0, 0},
+		},
+		{
+			"Quantize 3",
+			3,
+			color.Palette{transparent, color.NRGBA{0x11, 0x11, 0x11, 0xff}, color.NRGBA{0xc9, 0xc9, 0xc9, 0xc0}},
+			[]uint8{1, 1, 2, 2, 0, 0},
+		},
+		{
+			"Quantize 1",
+			1,
+			color.Palette{transparent},
+			[]uint8{0, 0, 0, 0, 0, 0},
+		},
+	}
+	for _, d := range td {
+		got := Quantize(src, d.n)
+		if len(got.Palette) != len(d.wantPalette) {
+			t.Errorf("test [%s] failed: palette %v", d.desc, got.Palette)
+			continue
+		}
+		// the palette colors are in the order of the box splits, compare via the pixels
+		for i, k := range got.Pix {
+			if got.Palette[k] != d.wantPalette[d.wantPix[i]] {
+				t.Errorf("test [%s] failed: pixel %d is %v", d.desc, i, got.Palette[k])
+			}
+		}
+	}
+
+	// without transparency all the palette entries are used for the colors
+	opaque := Crop(src, image.Rect(0, 0, 3, 1))
+	p := QuantizePalette(opaque, 2)
+	if len(p) != 2 || p[0] != (color.NRGBA{0x11, 0x11, 0x11, 0xff}) || p[1] != c3 {
+		t.Errorf("test [QuantizePalette opaque] failed: %v", p)
+	}
+	if p := QuantizePalette(opaque, 1000); len(p) != 3 {
+		t.Errorf("test [QuantizePalette 1000] failed: %v", p)
+	}
+	if p := QuantizePalette(opaque, 0); len(p) != 1 || p[0] != (color.NRGBA{0x4e, 0x4e, 0x4e, 0xff}) {
+		t.Errorf("test [QuantizePalette 0] failed: %v", p)
+	}
+
+	got := Quantize(&image.NRGBA{}, 16)
+	if len(got.Palette) != 0 || len(got.Pix) != 0 {
+		t.Errorf("test [Quantize empty] failed: %#v", got)
+	}
+
+	// dithering with the adaptive palette
+	img := compareTestImage()
+	dithered := Dither(img, QuantizePalette(img, 16))
+	if len(dithered.Palette) != 16 {
+		t.Errorf("test [Quantize dither] failed: palette size %d", len(dithered.Palette))
+	}
+}
+
+func TestPixelate(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0x40, 0x40, 0x40, 0xff, 0x10, 0x20, 0x30, 0xff,
+			0x80, 0x80, 0x80, 0xff, 0xff, 0x00, 0x00, 0x00, 0x30, 0x20, 0x10, 0x80,
+			0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00,
+		},
+	}
+	td := []struct {
+		desc      string
+		blockSize int
+		want      *image.NRGBA
+	}{
+		{
+			"Pixelate 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x40, 0x40, 0x40, 0xbf, 0x40, 0x40, 0x40, 0xbf, 0x1b, 0x20, 0x25, 0xc0,
+					0x40, 0x40, 0x40, 0xbf, 0x40, 0x40, 0x40, 0xbf, 0x1b, 0x20, 0x25, 0xc0,
+					0x00, 0x80, 0x80, 0xff, 0x00, 0x80, 0x80, 0xff, 0x00, 0x00, 0x00, 0x00,
+				},
+			},
+		},
+		{
+			"Pixelate 1",
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix:    src.Pix,
+			},
+		},
+	}
+	for _, d := range td {
+		got := Pixelate(src, d.blockSize)
+		if !compareNRGBA(got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	got := Pixelate(src, 10)
+	want := New(3, 3, AverageColor(src))
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [Pixelate 10] failed: %#v", got)
+	}
+}
+
+func TestRectFilters(t *testing.T) {
+	src := compareTestImage()
+	src.Rect = src.Rect.Add(image.Pt(-5, -5))
+	r := image.Rect(10, 10, 40, 30)
+	local := r.Sub(src.Rect.Min)
+
+	td := []struct {
+		desc   string
+		got    *image.NRGBA
+		region image.Rectangle
+		inside *image.NRGBA
+	}{
+		{"ApplyRect Invert", ApplyRect(src, r, Invert), local, Invert(Crop(src, r))},
+		{"BlurRect", BlurRect(src, r, 2), local, Crop(Blur(src, 2), local)},
+		{"PixelateRect", PixelateRect(src, r, 4), local, Pixelate(Crop(src, r), 4)},
+		{"BlurRect clipped", BlurRect(src, image.Rect(-10, -10, 20, 20), 1.5), image.Rect(0, 0, 25, 25), Crop(Blur(src, 1.5), image.Rect(0, 0, 25, 25))},
+	}
+	for _, d := range td {
+		if d.got.Bounds() != image.Rect(0, 0, 90, 80) {
+			t.Errorf("test [%s] failed: bounds %v", d.desc, d.got.Bounds())
+			continue
+		}
+		if !compareNRGBA(Crop(d.got, d.region), d.inside, 0) {
+			t.Errorf("test [%s] failed: region differs", d.desc)
+		}
+		for y := 0; y < 80; y++ {
+			for x := 0; x < 90; x++ {
+				if !image.Pt(x, y).In(d.region) && d.got.NRGBAAt(x, y) != src.NRGBAAt(x-5, y-5) {
+					t.Fatalf("test [%s] failed: pixel (%d, %d) changed", d.desc, x, y)
+				}
+			}
+		}
+	}
+
+	if got := BlurRect(src, image.Rect(200, 200, 300, 300), 2); !compareNRGBA(got, Clone(src), 0) {
+		t.Errorf("test [BlurRect outside] failed")
+	}
+	if got := ApplyRect(src, image.Rectangle{}, Invert); !compareNRGBA(got, Clone(src), 0) {
+		t.Errorf("test [ApplyRect empty] failed")
+	}
+}
+
+func TestVignette(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x64, 0x00, 0x01, 0xff, 0x64, 0x00, 0x02, 0xff, 0x64, 0x00, 0x03,
+			0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff,
+			0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff,
+		},
+	}
+	td := []struct {
+		desc     string
+		strength float64
+		want     *image.NRGBA
+	}{
+		{
+			"Vignette 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xff, 0x64, 0x00, 0x01, 0xff, 0x64, 0x00, 0x02, 0xff, 0x64, 0x00, 0x03,
+					0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff,
+					0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Vignette 0.5",
+			0.5,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xbf, 0x4b, 0x00, 0x01, 0xde, 0x57, 0x00, 0x02, 0xbf, 0x4b, 0x00, 0x03,
+					0xde, 0x57, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xde, 0x57, 0x00, 0xff,
+					0xbf, 0x4b, 0x00, 0xff, 0xde, 0x57, 0x00, 0xff, 0xbf, 0x4b, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Vignette 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x80, 0x32, 0x00, 0x01, 0xbc, 0x4a, 0x00, 0x02, 0x80, 0x32, 0x00, 0x03,
+					0xbc, 0x4a, 0x00, 0xff, 0xff, 0x64, 0x00, 0xff, 0xbc, 0x4a, 0x00, 0xff,
+					0x80, 0x32, 0x00, 0xff, 0xbc, 0x4a, 0x00, 0xff, 0x80, 0x32, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Vignette(src, d.strength)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
@@ -14,19 +14,29 @@ To achieve maximum performance, make sure to allow Go to utilize all CPU cores:
 package imaging
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"image/color/palette"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp" // register the WebP decoder
 )
 
 type Format int
@@ -37,6 +47,7 @@ const (
 	GIF
 	TIFF
 	BMP
+	WEBP
 )
 
 func (f Format) String() string {
@@ -51,40 +62,573 @@ func (f Format) String() string {
 		return "TIFF"
 	case BMP:
 		return "BMP"
+	case WEBP:
+		return "WEBP"
+	case UnknownFormat:
+		return "Unknown"
 	default:
 		return "Unsupported"
 	}
 }
 
+// UnknownFormat is returned by DetectFormat for data of unrecognized formats.
+const UnknownFormat Format = -1
+
+// formatSignatures are the magic bytes at the beginning of the encoded images, '?' matches any byte.
+var formatSignatures = []struct {
+	format Format
+	sig    string
+}{
+	{JPEG, "\xff\xd8\xff"},
+	{PNG, pngSignature},
+	{GIF, "GIF87a"},
+	{GIF, "GIF89a"},
+	{TIFF, "II*\x00"},
+	{TIFF, "MM\x00*"},
+	{BMP, "BM"},
+	{WEBP, "RIFF????WEBP"},
+}
+
+// DetectFormat detects the format of the image data read from r by its magic bytes without
+// decoding it. UnknownFormat is returned if the format is not recognized. At most 12 bytes
+// are consumed from r. If r is a *bufio.Reader (or has the Peek method), the bytes are peeked
+// instead, and if r is an io.Seeker, it's rewound to its initial position, so the image can be
+// decoded from r afterwards.
+//
+// Usage example:
+//
+//		br := bufio.NewReader(r)
+//		format, err := imaging.DetectFormat(br)
+//		if err != nil || format == imaging.UnknownFormat {
+//			// reject the upload
+//		}
+//		img, err := imaging.Decode(br)
+//
+func DetectFormat(r io.Reader) (Format, error) {
+	const n = 12
+	var head []byte
+	var err error
+	if p, ok := r.(interface {
+		Peek(int) ([]byte, error)
+	}); ok {
+		head, err = p.Peek(n)
+	} else {
+		if s, ok := r.(io.Seeker); ok {
+			if pos, serr := s.Seek(0, io.SeekCurrent); serr == nil {
+				defer s.Seek(pos, io.SeekStart)
+			}
+		}
+		head = make([]byte, n)
+		var k int
+		k, err = io.ReadFull(r, head)
+		head = head[:k]
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF && err != bufio.ErrBufferFull {
+		return UnknownFormat, err
+	}
+
+	for _, f := range formatSignatures {
+		if matchSignature(head, f.sig) {
+			return f.format, nil
+		}
+	}
+	return UnknownFormat, nil
+}
+
+func matchSignature(data []byte, sig string) bool {
+	if len(data) < len(sig) {
+		return false
+	}
+	for i := 0; i < len(sig); i++ {
+		if sig[i] != '?' && sig[i] != data[i] {
+			return false
+		}
+	}
+	return true
+}
+
 var (
 	ErrUnsupportedFormat = errors.New("imaging: unsupported image format")
+	ErrImageTooLarge     = errors.New("imaging: image is too large")
+	ErrMetadataTooLarge  = errors.New("imaging: metadata is too large")
 )
 
-// Decode reads an image from r.
-func Decode(r io.Reader) (image.Image, error) {
-	img, _, err := image.Decode(r)
+// webpEncoder is used to encode images in the WebP format. It's nil unless the package
+// is built with the webpenc build tag, as there is no WebP encoder in golang.org/x/image.
+var webpEncoder func(w io.Writer, img image.Image) error
+
+type decodeConfig struct {
+	autoOrientation bool
+	maxWidth        int
+	maxHeight       int
+	maxPixels       int
+}
+
+var defaultDecodeConfig = decodeConfig{
+	autoOrientation: false,
+}
+
+// DecodeOption sets an optional parameter for the Decode and Open functions.
+type DecodeOption func(*decodeConfig)
+
+// AutoOrientation returns a DecodeOption that sets the auto-orientation mode.
+// If auto-orientation is enabled, the image will be transformed after decoding
+// according to the EXIF orientation tag (if present). By default it's disabled.
+func AutoOrientation(enabled bool) DecodeOption {
+	return func(c *decodeConfig) {
+		c.autoOrientation = enabled
+	}
+}
+
+// MaxDimensions returns a DecodeOption that limits the width and height of the decoded image.
+// The image size is read from the image header before decoding, and ErrImageTooLarge is returned
+// if it exceeds the limit, so no memory is allocated for the oversized image.
+// Zero means no limit. By default the size is not limited.
+func MaxDimensions(width, height int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxWidth = width
+		c.maxHeight = height
+	}
+}
+
+// MaxPixels returns a DecodeOption that limits the total number of pixels (width * height)
+// of the decoded image like MaxDimensions does. Zero means no limit.
+func MaxPixels(pixels int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxPixels = pixels
+	}
+}
+
+// Decode reads an image from r. The format is detected automatically,
+// JPEG, PNG, GIF, TIFF, BMP and WebP (lossy and lossless) are supported.
+//
+// Usage example:
+//
+//		// decode the image and rotate it according to the EXIF orientation tag
+//		img, err := imaging.Decode(r, imaging.AutoOrientation(true))
+//
+func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	if cfg.maxWidth > 0 || cfg.maxHeight > 0 || cfg.maxPixels > 0 {
+		// read the image header and keep the consumed data for decoding
+		buf := &bytes.Buffer{}
+		imgCfg, _, err := image.DecodeConfig(io.TeeReader(r, buf))
+		if err != nil {
+			return nil, err
+		}
+		if (cfg.maxWidth > 0 && imgCfg.Width > cfg.maxWidth) ||
+			(cfg.maxHeight > 0 && imgCfg.Height > cfg.maxHeight) ||
+			(cfg.maxPixels > 0 && int64(imgCfg.Width)*int64(imgCfg.Height) > int64(cfg.maxPixels)) {
+			return nil, ErrImageTooLarge
+		}
+		r = io.MultiReader(buf, r)
+	}
+
+	if !cfg.autoOrientation {
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		return toNRGBA(img), nil
+	}
+
+	// read the orientation tag in parallel with decoding
+	orientation := 1
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		orientation = readOrientation(pr)
+		io.Copy(ioutil.Discard, pr)
+	}()
+
+	img, _, err := image.Decode(io.TeeReader(r, pw))
+	pw.Close()
+	<-done
 	if err != nil {
 		return nil, err
 	}
-	return toNRGBA(img), nil
+
+	if orientation == 1 {
+		return toNRGBA(img), nil
+	}
+	return AutoOrient(img, orientation), nil
+}
+
+// DecodeBytes decodes the image from the data like Decode does.
+//
+// Usage example:
+//
+//		img, err := imaging.DecodeBytes(body, imaging.AutoOrientation(true))
+//
+func DecodeBytes(data []byte, opts ...DecodeOption) (image.Image, error) {
+	return Decode(bytes.NewReader(data), opts...)
+}
+
+// Dimensions reads the image header from r and returns the image width, height and format name
+// ("jpeg", "png", "gif", "tiff", "bmp" or "webp") without decoding the pixels. Only the beginning
+// of the data is consumed. If r is also an io.Seeker (e.g. *os.File or *bytes.Reader),
+// it's rewound to its initial position, so the image can be decoded from it afterwards.
+//
+// Usage example:
+//
+//		w, h, format, err := imaging.Dimensions(file)
+//		if err == nil && w*h > 50e6 {
+//			// reject the upload
+//		}
+//
+func Dimensions(r io.Reader) (width, height int, format string, err error) {
+	if s, ok := r.(io.Seeker); ok {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err == nil {
+			defer func() {
+				if _, serr := s.Seek(pos, io.SeekStart); serr != nil && err == nil {
+					err = serr
+				}
+			}()
+		}
+	}
+
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return cfg.Width, cfg.Height, format, nil
 }
 
 // Open loads an image from file
-func Open(filename string) (image.Image, error) {
+func Open(filename string, opts ...DecodeOption) (image.Image, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	img, err := Decode(file)
+	img, err := Decode(file, opts...)
 	return img, err
 }
 
-// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF or BMP).
-func Encode(w io.Writer, img image.Image, format Format) error {
+// Metadata holds the raw metadata blocks of the image.
+type Metadata struct {
+	// EXIF contains the EXIF data (TIFF structure, without the "Exif\x00\x00" header).
+	EXIF []byte
+	// ICC contains the ICC color profile.
+	ICC []byte
+}
+
+const (
+	jpegExifHeader = "Exif\x00\x00"
+	jpegICCHeader  = "ICC_PROFILE\x00"
+	jpegMaxSegment = 65533 // the maximum segment payload size
+)
+
+// DecodeMeta reads an image from r like Decode does and returns it along with
+// the EXIF and ICC profile data. Metadata is only read from JPEG images,
+// for other formats empty Metadata is returned.
+func DecodeMeta(r io.Reader, opts ...DecodeOption) (image.Image, Metadata, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	img, err := Decode(bytes.NewReader(data), opts...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return img, readJPEGMetadata(data), nil
+}
+
+// readJPEGMetadata extracts the EXIF (APP1) and ICC profile (APP2) segments from the JPEG data.
+func readJPEGMetadata(data []byte) Metadata {
+	var meta Metadata
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return meta
+	}
+
+	iccChunks := make(map[int][]byte)
+	iccCount := 0
+	for pos := 2; pos+4 <= len(data); {
+		marker := data[pos+1]
+		if data[pos] != 0xff || marker == 0xda || marker == 0xd9 {
+			break
+		}
+		size := int(data[pos+2])<<8 | int(data[pos+3])
+		if size < 2 || pos+2+size > len(data) {
+			break
+		}
+		seg := data[pos+4 : pos+2+size]
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(seg, []byte(jpegExifHeader)):
+			meta.EXIF = append([]byte{}, seg[len(jpegExifHeader):]...)
+		case marker == 0xe2 && bytes.HasPrefix(seg, []byte(jpegICCHeader)) && len(seg) >= len(jpegICCHeader)+2:
+			// ICC profile may be split into several chunks: sequence number (from 1), number of chunks, data
+			iccChunks[int(seg[len(jpegICCHeader)])] = seg[len(jpegICCHeader)+2:]
+			iccCount = int(seg[len(jpegICCHeader)+1])
+		}
+		pos += 2 + size
+	}
+
+	for i := 1; i <= iccCount; i++ {
+		chunk, ok := iccChunks[i]
+		if !ok {
+			meta.ICC = nil
+			break
+		}
+		meta.ICC = append(meta.ICC, chunk...)
+	}
+
+	return meta
+}
+
+// writeJPEGMetadata writes the JPEG data to w inserting the metadata segments after the SOI marker.
+func writeJPEGMetadata(w io.Writer, data []byte, meta Metadata) error {
+	if len(data) < 2 {
+		return errors.New("imaging: invalid JPEG data")
+	}
+
+	maxICCChunk := jpegMaxSegment - len(jpegICCHeader) - 2
+	iccCount := (len(meta.ICC) + maxICCChunk - 1) / maxICCChunk
+	if len(meta.EXIF) > jpegMaxSegment-len(jpegExifHeader) || iccCount > 255 {
+		return ErrMetadataTooLarge
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(data[:2])
+	writeSegment := func(marker byte, header string, extra []byte, payload []byte) {
+		size := 2 + len(header) + len(extra) + len(payload)
+		buf.Write([]byte{0xff, marker, byte(size >> 8), byte(size)})
+		buf.WriteString(header)
+		buf.Write(extra)
+		buf.Write(payload)
+	}
+
+	if len(meta.EXIF) > 0 {
+		writeSegment(0xe1, jpegExifHeader, nil, meta.EXIF)
+	}
+	for i := 0; i < iccCount; i++ {
+		end := (i + 1) * maxICCChunk
+		if end > len(meta.ICC) {
+			end = len(meta.ICC)
+		}
+		writeSegment(0xe2, jpegICCHeader, []byte{byte(i + 1), byte(iccCount)}, meta.ICC[i*maxICCChunk:end])
+	}
+	buf.Write(data[2:])
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readOrientation reads the EXIF orientation tag from the JPEG data in r.
+// It returns 1 (normal orientation) if the tag can't be found.
+func readOrientation(r io.Reader) int {
+	const (
+		markerSOI      = 0xffd8
+		markerAPP1     = 0xffe1
+		markerSOS      = 0xffda
+		exifHeader     = 0x45786966 // "Exif"
+		byteOrderLE    = 0x4949     // "II"
+		byteOrderBE    = 0x4d4d     // "MM"
+		orientationTag = 0x0112
+	)
+
+	var soi uint16
+	if binary.Read(r, binary.BigEndian, &soi) != nil || soi != markerSOI {
+		return 1
+	}
+
+	for {
+		var marker, size uint16
+		if binary.Read(r, binary.BigEndian, &marker) != nil || marker>>8 != 0xff || marker == markerSOS {
+			return 1
+		}
+		if binary.Read(r, binary.BigEndian, &size) != nil || size < 2 {
+			return 1
+		}
+		if marker != markerAPP1 {
+			if _, err := io.CopyN(ioutil.Discard, r, int64(size-2)); err != nil {
+				return 1
+			}
+			continue
+		}
+
+		// APP1 segment: "Exif\0\0" header followed by TIFF data
+		var header uint32
+		var pad uint16
+		if binary.Read(r, binary.BigEndian, &header) != nil || header != exifHeader {
+			return 1
+		}
+		if binary.Read(r, binary.BigEndian, &pad) != nil {
+			return 1
+		}
+
+		var byteOrder binary.ByteOrder
+		var order uint16
+		if binary.Read(r, binary.BigEndian, &order) != nil {
+			return 1
+		}
+		switch order {
+		case byteOrderLE:
+			byteOrder = binary.LittleEndian
+		case byteOrderBE:
+			byteOrder = binary.BigEndian
+		default:
+			return 1
+		}
+
+		var magic uint16
+		var offset uint32
+		if binary.Read(r, byteOrder, &magic) != nil || magic != 0x002a {
+			return 1
+		}
+		if binary.Read(r, byteOrder, &offset) != nil || offset < 8 {
+			return 1
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(offset-8)); err != nil {
+			return 1
+		}
+
+		var count uint16
+		if binary.Read(r, byteOrder, &count) != nil {
+			return 1
+		}
+		for i := 0; i < int(count); i++ {
+			var tag, typ uint16
+			var n uint32
+			var value [4]byte
+			if binary.Read(r, byteOrder, &tag) != nil ||
+				binary.Read(r, byteOrder, &typ) != nil ||
+				binary.Read(r, byteOrder, &n) != nil ||
+				binary.Read(r, byteOrder, &value) != nil {
+				return 1
+			}
+			if tag == orientationTag {
+				v := int(byteOrder.Uint16(value[0:2]))
+				if v < 1 || v > 8 {
+					return 1
+				}
+				return v
+			}
+		}
+		return 1
+	}
+}
+
+type encodeConfig struct {
+	jpegQuality         int
+	pngCompressionLevel png.CompressionLevel
+	metadata            Metadata
+	gifLoopCount        int
+	tiffCompression     tiff.CompressionType
+	createDirs          bool
+	dirPerm             os.FileMode
+}
+
+var defaultEncodeConfig = encodeConfig{
+	jpegQuality:         95,
+	pngCompressionLevel: png.DefaultCompression,
+	tiffCompression:     tiff.Deflate,
+}
+
+// EncodeOption sets an optional parameter for the Encode and Save functions.
+type EncodeOption func(*encodeConfig)
+
+// JPEGQuality returns an EncodeOption that sets the output JPEG quality.
+// Quality ranges from 1 to 100 inclusive, higher is better. Default is 95.
+// Progressive JPEG encoding is not supported by the standard image/jpeg package.
+func JPEGQuality(quality int) EncodeOption {
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
+	}
+	return func(c *encodeConfig) {
+		c.jpegQuality = quality
+	}
+}
+
+// PNGCompressionLevel returns an EncodeOption that sets the compression level
+// of the PNG-encoded image. Default is png.DefaultCompression.
+func PNGCompressionLevel(level png.CompressionLevel) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngCompressionLevel = level
+	}
+}
+
+// TIFFCompression returns an EncodeOption that sets the compression of the TIFF-encoded image.
+// Only tiff.Uncompressed and tiff.Deflate are supported for encoding by the golang.org/x/image/tiff
+// package, other compression types result in an error. Default is tiff.Deflate.
+func TIFFCompression(compression tiff.CompressionType) EncodeOption {
+	return func(c *encodeConfig) {
+		c.tiffCompression = compression
+	}
+}
+
+// GIFLoopCount returns an EncodeOption that sets the number of times the animation
+// written by EncodeGIFFrames is repeated. 0 means infinite looping (default), -1 means
+// the animation is shown once.
+func GIFLoopCount(count int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.gifLoopCount = count
+	}
+}
+
+// CreateDirs returns an EncodeOption that makes Save create the missing parent directories
+// of the file with the specified permissions. By default Save fails if the directory doesn't exist.
+func CreateDirs(perm os.FileMode) EncodeOption {
+	return func(c *encodeConfig) {
+		c.createDirs = true
+		c.dirPerm = perm
+	}
+}
+
+// EmbedMetadata returns an EncodeOption that writes the EXIF and ICC profile data
+// to the encoded image. Metadata is only written to JPEG images, other formats ignore it.
+//
+// Usage example:
+//
+//		// adjust the image keeping its metadata
+//		img, meta, err := imaging.DecodeMeta(r)
+//		...
+//		err = imaging.Encode(w, imaging.Sharpen(img, 1.0), imaging.JPEG, imaging.EmbedMetadata(meta))
+//
+func EmbedMetadata(meta Metadata) EncodeOption {
+	return func(c *encodeConfig) {
+		c.metadata = meta
+	}
+}
+
+// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF, BMP or WEBP).
+// WebP encoding is only available if the package is built with the webpenc build tag,
+// otherwise ErrUnsupportedFormat is returned for WEBP.
+//
+// Usage example:
+//
+//		// encode the image as PNG with the best compression
+//		err := imaging.Encode(w, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
+//
+//		// encode the image as JPEG with the quality of 80
+//		err := imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(80))
+//
+func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) error {
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
 	var err error
 	switch format {
 	case JPEG:
+		jw := w
+		var buf *bytes.Buffer
+		if len(cfg.metadata.EXIF) > 0 || len(cfg.metadata.ICC) > 0 {
+			// encode to the buffer first to insert the metadata segments
+			buf = &bytes.Buffer{}
+			jw = buf
+		}
+
 		var rgba *image.RGBA
 		if nrgba, ok := img.(*image.NRGBA); ok {
 			if nrgba.Opaque() {
@@ -96,43 +640,89 @@ func Encode(w io.Writer, img image.Image, format Format) error {
 			}
 		}
 		if rgba != nil {
-			err = jpeg.Encode(w, rgba, &jpeg.Options{Quality: 95})
+			err = jpeg.Encode(jw, rgba, &jpeg.Options{Quality: cfg.jpegQuality})
 		} else {
-			err = jpeg.Encode(w, img, &jpeg.Options{Quality: 95})
+			err = jpeg.Encode(jw, img, &jpeg.Options{Quality: cfg.jpegQuality})
+		}
+		if err == nil && buf != nil {
+			err = writeJPEGMetadata(w, buf.Bytes(), cfg.metadata)
 		}
 
 	case PNG:
-		err = png.Encode(w, img)
+		enc := png.Encoder{CompressionLevel: cfg.pngCompressionLevel}
+		err = enc.Encode(w, img)
 	case GIF:
 		err = gif.Encode(w, img, &gif.Options{NumColors: 256})
 	case TIFF:
-		err = tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+		err = encodeTIFF(w, img, cfg.tiffCompression)
 	case BMP:
 		err = bmp.Encode(w, img)
+	case WEBP:
+		if webpEncoder == nil {
+			return ErrUnsupportedFormat
+		}
+		err = webpEncoder(w, img)
 	default:
 		err = ErrUnsupportedFormat
 	}
 	return err
 }
 
-// Save saves the image to file with the specified filename.
-// The format is determined from the filename extension: "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
-func Save(img image.Image, filename string) (err error) {
-	formats := map[string]Format{
-		".jpg":  JPEG,
-		".jpeg": JPEG,
-		".png":  PNG,
-		".tif":  TIFF,
-		".tiff": TIFF,
-		".bmp":  BMP,
-		".gif":  GIF,
+// EncodeBytes encodes the image in the specified format like Encode does and returns the encoded data.
+//
+// Usage example:
+//
+//		data, err := imaging.EncodeBytes(img, imaging.JPEG, imaging.JPEGQuality(80))
+//
+func EncodeBytes(img image.Image, format Format, opts ...EncodeOption) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format, opts...); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
+// saveFormats maps the filename extensions supported by Save to the image formats.
+var saveFormats = map[string]Format{
+	".jpg":  JPEG,
+	".jpeg": JPEG,
+	".png":  PNG,
+	".tif":  TIFF,
+	".tiff": TIFF,
+	".bmp":  BMP,
+	".gif":  GIF,
+	".webp": WEBP,
+}
+
+// Save saves the image to file with the specified filename.
+// The format is determined from the filename extension: "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff"), "bmp" and "webp" are supported.
+// Encode options (e.g. JPEGQuality, PNGCompressionLevel, CreateDirs) may be specified.
+//
+// Usage example:
+//
+//		// save the image creating the output directory if needed
+//		err := imaging.Save(img, "out/thumbs/01.jpg", imaging.CreateDirs(0755))
+//
+func Save(img image.Image, filename string, opts ...EncodeOption) (err error) {
 	ext := strings.ToLower(filepath.Ext(filename))
-	f, ok := formats[ext]
+	f, ok := saveFormats[ext]
 	if !ok {
 		return ErrUnsupportedFormat
 	}
+	if f == WEBP && webpEncoder == nil {
+		// don't leave the empty file behind
+		return ErrUnsupportedFormat
+	}
+
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	if cfg.createDirs {
+		if err := os.MkdirAll(filepath.Dir(filename), cfg.dirPerm); err != nil {
+			return err
+		}
+	}
 
 	file, err := os.Create(filename)
 	if err != nil {
@@ -140,7 +730,443 @@ func Save(img image.Image, filename string) (err error) {
 	}
 	defer file.Close()
 
-	return Encode(file, img, f)
+	return Encode(file, img, f, opts...)
+}
+
+// FileError records the error that occurred while processing a single file.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// BatchError is returned by ProcessDir if some of the files could not be processed.
+// The errors are sorted by the file path.
+type BatchError []*FileError
+
+func (e BatchError) Error() string {
+	if len(e) == 1 {
+		return "imaging: 1 file failed: " + e[0].Error()
+	}
+	return fmt.Sprintf("imaging: %d files failed, first: %v", len(e), e[0])
+}
+
+type processConfig struct {
+	workers    int
+	decodeOpts []DecodeOption
+	encodeOpts []EncodeOption
+}
+
+// ProcessOption sets an optional parameter for the ProcessDir function.
+type ProcessOption func(*processConfig)
+
+// ProcessWorkers returns a ProcessOption that sets the number of files processed
+// concurrently. By default it's the number of CPUs.
+func ProcessWorkers(n int) ProcessOption {
+	return func(c *processConfig) {
+		c.workers = n
+	}
+}
+
+// ProcessDecodeOptions returns a ProcessOption that sets the options used to open the files.
+func ProcessDecodeOptions(opts ...DecodeOption) ProcessOption {
+	return func(c *processConfig) {
+		c.decodeOpts = opts
+	}
+}
+
+// ProcessEncodeOptions returns a ProcessOption that sets the options used to save the files.
+func ProcessEncodeOptions(opts ...EncodeOption) ProcessOption {
+	return func(c *processConfig) {
+		c.encodeOpts = opts
+	}
+}
+
+// ProcessDir walks the inDir directory recursively, opens every image file with the extension
+// supported by Save, applies the fn function to it and saves the result to the same relative
+// path in the outDir directory, creating the missing directories. Files with other extensions
+// are ignored, and if fn returns nil the file is skipped. The files are processed concurrently.
+// Processing continues after a file fails, and the errors of the failed files are returned
+// as BatchError.
+//
+// Usage example:
+//
+//		err := imaging.ProcessDir("photos", "thumbs", func(img image.Image) image.Image {
+//			return imaging.Thumbnail(img, 100, 100, imaging.Lanczos)
+//		}, imaging.ProcessWorkers(4))
+//		if errs, ok := err.(imaging.BatchError); ok {
+//			for _, e := range errs {
+//				log.Printf("failed to process %s: %v", e.Path, e.Err)
+//			}
+//		}
+//
+func ProcessDir(inDir, outDir string, fn func(img image.Image) image.Image, opts ...ProcessOption) error {
+	cfg := processConfig{workers: runtime.NumCPU()}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	if cfg.workers < 1 {
+		cfg.workers = 1
+	}
+	encodeOpts := append([]EncodeOption{CreateDirs(0755)}, cfg.encodeOpts...)
+
+	var paths []string
+	err := filepath.Walk(inDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := saveFormats[strings.ToLower(filepath.Ext(path))]; ok && info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	process := func(path string) error {
+		rel, err := filepath.Rel(inDir, path)
+		if err != nil {
+			return err
+		}
+		img, err := Open(path, cfg.decodeOpts...)
+		if err != nil {
+			return err
+		}
+		dst := fn(img)
+		if dst == nil {
+			return nil
+		}
+		return Save(dst, filepath.Join(outDir, rel), encodeOpts...)
+	}
+
+	errs := make([]error, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = process(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var batchErr BatchError
+	for i, err := range errs {
+		if err != nil {
+			batchErr = append(batchErr, &FileError{Path: paths[i], Err: err})
+		}
+	}
+	if len(batchErr) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// DecodeGIF reads all the frames of the animated GIF image from r. Each returned frame
+// is the full animation canvas composited according to the frame disposal methods.
+// The delays are specified in 100ths of a second, one for each frame.
+//
+// Usage example:
+//
+//		// blur every frame of the animation
+//		frames, delays, err := imaging.DecodeGIF(r)
+//		for i := range frames {
+//			frames[i] = imaging.Blur(frames[i], 0.5)
+//		}
+//		err = imaging.EncodeGIFFrames(w, frames, delays)
+//
+func DecodeGIF(r io.Reader) ([]*image.NRGBA, []int, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	frames := make([]*image.NRGBA, len(g.Image))
+	delays := make([]int, len(g.Image))
+
+	for i, frame := range g.Image {
+		frames[i] = PasteOver(canvas, frame, frame.Bounds().Min)
+		delays[i] = g.Delay[i]
+
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			// clear the frame area to transparent
+			canvas = Clone(frames[i])
+			r := frame.Bounds().Intersect(canvas.Bounds())
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				start := y*canvas.Stride + r.Min.X*4
+				end := y*canvas.Stride + r.Max.X*4
+				for k := start; k < end; k++ {
+					canvas.Pix[k] = 0
+				}
+			}
+		case gif.DisposalPrevious:
+			// keep the canvas as it was before the frame
+		default:
+			canvas = frames[i]
+		}
+	}
+
+	return frames, delays, nil
+}
+
+// EncodeGIFFrames writes the frames to w as an animated GIF image. The delays are specified
+// in 100ths of a second, one for each frame. The frames are quantized to the Plan 9 palette
+// using the Floyd-Steinberg dithering. If the frames have transparent pixels, the last color
+// of the palette is replaced with the transparent color. The size of the animation is the size
+// of the first frame. Encode options (e.g. GIFLoopCount) may be specified.
+func EncodeGIFFrames(w io.Writer, frames []*image.NRGBA, delays []int, opts ...EncodeOption) error {
+	if len(frames) == 0 {
+		return errors.New("imaging: no frames to encode")
+	}
+	if len(delays) != len(frames) {
+		return errors.New("imaging: the number of delays doesn't match the number of frames")
+	}
+
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	p := make(color.Palette, len(palette.Plan9))
+	copy(p, palette.Plan9)
+	for _, frame := range frames {
+		if !frame.Opaque() {
+			p[len(p)-1] = color.Transparent
+			break
+		}
+	}
+
+	// every frame is a full canvas, so it replaces the previous one entirely
+	disposal := make([]byte, len(frames))
+	for i := range disposal {
+		disposal[i] = gif.DisposalBackground
+	}
+
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(frames)),
+		Delay:     delays,
+		Disposal:  disposal,
+		LoopCount: cfg.gifLoopCount,
+		Config: image.Config{
+			ColorModel: p,
+			Width:      frames[0].Bounds().Dx(),
+			Height:     frames[0].Bounds().Dy(),
+		},
+	}
+	for i, frame := range frames {
+		g.Image[i] = Dither(frame, p)
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+func encodeTIFF(w io.Writer, img image.Image, compression tiff.CompressionType) error {
+	if compression != tiff.Uncompressed && compression != tiff.Deflate {
+		return errors.New("imaging: unsupported TIFF compression")
+	}
+	return tiff.Encode(w, img, &tiff.Options{Compression: compression, Predictor: compression == tiff.Deflate})
+}
+
+// EncodeTIFFPages writes the images to w as a multi-page TIFF image.
+// Encode options (e.g. TIFFCompression) may be specified.
+//
+// Usage example:
+//
+//		err := imaging.EncodeTIFFPages(w, []image.Image{page1, page2}, imaging.TIFFCompression(tiff.Uncompressed))
+//
+func EncodeTIFFPages(w io.Writer, pages []image.Image, opts ...EncodeOption) error {
+	if len(pages) == 0 {
+		return errors.New("imaging: no pages to encode")
+	}
+
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+
+	// each page is encoded as a separate TIFF image, then the pages are concatenated
+	// with their offsets relocated and the image file directories chained together
+	var out []byte
+	var order binary.ByteOrder
+	lastIFD := 0
+	for _, page := range pages {
+		buf := &bytes.Buffer{}
+		if err := encodeTIFF(buf, page, cfg.tiffCompression); err != nil {
+			return err
+		}
+		data := buf.Bytes()
+
+		offsets, pageOrder, err := tiffIFDOffsets(data)
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			out, order = data, pageOrder
+			lastIFD = offsets[len(offsets)-1]
+			continue
+		}
+		if pageOrder != order {
+			return errors.New("imaging: inconsistent TIFF byte order")
+		}
+
+		if len(out)%2 == 1 {
+			out = append(out, 0) // keep the offsets word-aligned
+		}
+		delta := len(out) - 8
+		out = append(out, data[8:]...)
+		for _, off := range offsets {
+			if err := relocateTIFFIFD(out, off+delta, delta, order); err != nil {
+				return err
+			}
+		}
+
+		// link the previous last directory to the first directory of the page
+		n := int(order.Uint16(out[lastIFD:]))
+		order.PutUint32(out[lastIFD+2+n*12:], uint32(offsets[0]+delta))
+		lastIFD = offsets[len(offsets)-1] + delta
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// TIFFPageCount returns the number of pages of the TIFF image read from r.
+func TIFFPageCount(r io.Reader) (int, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	offsets, _, err := tiffIFDOffsets(data)
+	if err != nil {
+		return 0, err
+	}
+	return len(offsets), nil
+}
+
+// DecodeTIFFPage reads the page with the specified index (starting from 0) of the multi-page
+// TIFF image from r.
+func DecodeTIFFPage(r io.Reader, page int) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	offsets, order, err := tiffIFDOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	if page < 0 || page >= len(offsets) {
+		return nil, errors.New("imaging: TIFF page index out of range")
+	}
+
+	// point the header to the directory of the page, the TIFF offsets are absolute
+	patched := make([]byte, len(data))
+	copy(patched, data)
+	order.PutUint32(patched[4:], uint32(offsets[page]))
+
+	img, err := tiff.Decode(bytes.NewReader(patched))
+	if err != nil {
+		return nil, err
+	}
+	return toNRGBA(img), nil
+}
+
+// tiffIFDOffsets returns the byte order of the TIFF data and the offsets of its image file directories.
+func tiffIFDOffsets(data []byte) ([]int, binary.ByteOrder, error) {
+	errInvalid := errors.New("imaging: invalid TIFF data")
+	if len(data) < 8 {
+		return nil, nil, errInvalid
+	}
+
+	var order binary.ByteOrder
+	switch string(data[0:4]) {
+	case "II\x2a\x00":
+		order = binary.LittleEndian
+	case "MM\x00\x2a":
+		order = binary.BigEndian
+	default:
+		return nil, nil, errInvalid
+	}
+
+	var offsets []int
+	visited := make(map[int]bool)
+	for off := int(order.Uint32(data[4:])); off != 0; {
+		if visited[off] || off+2 > len(data) {
+			return nil, nil, errInvalid
+		}
+		visited[off] = true
+		n := int(order.Uint16(data[off:]))
+		next := off + 2 + n*12
+		if next+4 > len(data) {
+			return nil, nil, errInvalid
+		}
+		offsets = append(offsets, off)
+		off = int(order.Uint32(data[next:]))
+	}
+	if len(offsets) == 0 {
+		return nil, nil, errInvalid
+	}
+	return offsets, order, nil
+}
+
+// relocateTIFFIFD adds delta to all the offsets stored in the image file directory at off.
+func relocateTIFFIFD(data []byte, off, delta int, order binary.ByteOrder) error {
+	const (
+		tagStripOffsets = 273
+		tagTileOffsets  = 324
+	)
+	typeSizes := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+	n := int(order.Uint16(data[off:]))
+	for k := 0; k < n; k++ {
+		e := off + 2 + k*12
+		tag := order.Uint16(data[e:])
+		typ := order.Uint16(data[e+2:])
+		count := int(order.Uint32(data[e+4:]))
+
+		values := e + 8
+		if typeSizes[typ]*count > 4 {
+			p := int(order.Uint32(data[values:])) + delta
+			order.PutUint32(data[values:], uint32(p))
+			values = p
+		}
+		if tag != tagStripOffsets && tag != tagTileOffsets {
+			continue
+		}
+		if values+typeSizes[typ]*count > len(data) {
+			return errors.New("imaging: invalid TIFF data")
+		}
+		for i := 0; i < count; i++ {
+			switch typ {
+			case 3:
+				v := int(order.Uint16(data[values+i*2:])) + delta
+				if v > 0xffff {
+					return errors.New("imaging: TIFF is too large")
+				}
+				order.PutUint16(data[values+i*2:], uint16(v))
+			case 4:
+				v := int(order.Uint32(data[values+i*4:])) + delta
+				order.PutUint32(data[values+i*4:], uint32(v))
+			}
+		}
+	}
+	return nil
 }
 
 // New creates a new image with the specified width and height, and fills it with the specified color.
@@ -170,6 +1196,189 @@ func New(width, height int, fillColor color.Color) *image.NRGBA {
 	return dst
 }
 
+// LinearGradient creates a new image with the specified width and height, and fills it with
+// the linear gradient from c0 to c1. The angle parameter is the gradient direction in degrees
+// counter-clockwise: 0 means from left (c0) to right (c1), 90 means from bottom to top.
+// The colors are interpolated in linear light.
+//
+// Usage example:
+//
+//		dstImage := imaging.LinearGradient(800, 600, color.Black, color.White, 90)
+//
+func LinearGradient(width, height int, c0, c1 color.Color, angle float64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	g := newGradient(c0, c1)
+
+	rad := angle * math.Pi / 180.0
+	dx, dy := math.Cos(rad), -math.Sin(rad)
+	cx, cy := float64(width-1)/2.0, float64(height-1)/2.0
+	extent := math.Abs(dx)*cx + math.Abs(dy)*cy
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				t := 0.0
+				if extent > 1e-9 {
+					t = ((float64(x)-cx)*dx + (float64(y)-cy)*dy + extent) / (2 * extent)
+				}
+				i := y*dst.Stride + x*4
+				g.at(t, dst.Pix[i:i+4])
+			}
+		}
+	})
+
+	return dst
+}
+
+// RadialGradient creates a new image with the specified width and height, and fills it with
+// the radial gradient from c0 at the center point to c1 at the specified radius. The pixels
+// farther than the radius from the center are filled with c1. The colors are interpolated
+// in linear light.
+//
+// Usage example:
+//
+//		dstImage := imaging.RadialGradient(800, 600, image.Pt(400, 300), 400, color.White, color.Black)
+//
+func RadialGradient(width, height int, center image.Point, radius float64, c0, c1 color.Color) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	g := newGradient(c0, c1)
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				t := 1.0
+				if radius > 0 {
+					t = math.Hypot(float64(x-center.X), float64(y-center.Y)) / radius
+				}
+				i := y*dst.Stride + x*4
+				g.at(t, dst.Pix[i:i+4])
+			}
+		}
+	})
+
+	return dst
+}
+
+// gradient interpolates between two colors in linear light using premultiplied alpha.
+type gradient struct {
+	c0, c1 [4]float64 // premultiplied linear R, G, B and alpha (0..1)
+}
+
+func newGradient(c0, c1 color.Color) *gradient {
+	g := &gradient{}
+	for n, c := range []color.Color{c0, c1} {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		a := float64(nc.A) / 255.0
+		v := [4]float64{srgbToLinear(nc.R) * a, srgbToLinear(nc.G) * a, srgbToLinear(nc.B) * a, a}
+		if n == 0 {
+			g.c0 = v
+		} else {
+			g.c1 = v
+		}
+	}
+	return g
+}
+
+// at writes the gradient color at t (clamped to 0..1) to the NRGBA pixel px.
+func (g *gradient) at(t float64, px []uint8) {
+	t = math.Min(math.Max(t, 0.0), 1.0)
+	a := g.c0[3] + (g.c1[3]-g.c0[3])*t
+	if a <= 0 {
+		px[0], px[1], px[2], px[3] = 0, 0, 0, 0
+		return
+	}
+	for k := 0; k < 3; k++ {
+		v := g.c0[k] + (g.c1[k]-g.c0[k])*t
+		px[k] = linearToSRGB8(v / a)
+	}
+	px[3] = clamp(a * 255.0)
+}
+
+// Premultiply returns a copy of the image with the R, G and B values multiplied by the alpha value
+// (premultiplied alpha), stored in the *image.NRGBA buffer. The values are rounded to the nearest
+// integer, so the precision of the colors of semi-transparent pixels is lost: Unpremultiply restores
+// the original values exactly only for the opaque and the fully transparent (black) pixels.
+// Note that the other functions of the package expect non-premultiplied images.
+//
+// Usage example:
+//
+//		buf := imaging.Premultiply(srcImage).Pix // upload to GPU
+//
+func Premultiply(img image.Image) *image.NRGBA {
+	dst := Clone(img)
+	parallel(dst.Bounds().Dy(), func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			i := y * dst.Stride
+			for x := 0; x < dst.Bounds().Dx(); x++ {
+				a := uint32(dst.Pix[i+3])
+				for k := 0; k < 3; k++ {
+					dst.Pix[i+k] = uint8((uint32(dst.Pix[i+k])*a + 127) / 255)
+				}
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// Unpremultiply returns a copy of the image with premultiplied alpha (as returned by Premultiply)
+// with the R, G and B values divided by the alpha value. The values are rounded to the nearest
+// integer and clamped to the alpha value range. The colors of fully transparent pixels become black.
+//
+// Usage example:
+//
+//		dstImage := imaging.Unpremultiply(premultipliedImage)
+//
+func Unpremultiply(img *image.NRGBA) *image.NRGBA {
+	dst := Clone(img)
+	parallel(dst.Bounds().Dy(), func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			i := y * dst.Stride
+			for x := 0; x < dst.Bounds().Dx(); x++ {
+				a := uint32(dst.Pix[i+3])
+				for k := 0; k < 3; k++ {
+					if a == 0 {
+						dst.Pix[i+k] = 0
+						continue
+					}
+					v := (uint32(dst.Pix[i+k])*255 + a/2) / a
+					if v > 255 {
+						v = 255
+					}
+					dst.Pix[i+k] = uint8(v)
+				}
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// ToRGBA converts the image to the *image.RGBA type (premultiplied alpha). The bounds of the
+// resulting image start at (0, 0). The premultiplied values are rounded to the nearest integer.
+// Images of the *image.RGBA type can be passed directly to the functions of the package.
+//
+// Usage example:
+//
+//		rgba := imaging.ToRGBA(srcImage)
+//
+func ToRGBA(img image.Image) *image.RGBA {
+	src := Premultiply(img)
+	return &image.RGBA{
+		Pix:    src.Pix,
+		Stride: src.Stride,
+		Rect:   src.Rect,
+	}
+}
+
 // Clone returns a copy of the given image.
 func Clone(img image.Image) *image.NRGBA {
 	srcBounds := img.Bounds()
@@ -424,6 +1633,19 @@ func Clone(img image.Image) *image.NRGBA {
 	return dst
 }
 
+// AsNRGBA returns the image as *image.NRGBA with the bounds starting at (0, 0), like the other
+// functions of the package do. Unlike Clone, if the image is already an *image.NRGBA with
+// the bounds starting at (0, 0), it's returned as is without copying, so modifying the result
+// modifies the original image. Other images are converted (copied).
+//
+// Usage example:
+//
+//		nrgba := imaging.AsNRGBA(srcImage)
+//
+func AsNRGBA(img image.Image) *image.NRGBA {
+	return toNRGBA(img)
+}
+
 // This function used internally to convert any image type to NRGBA if needed.
 func toNRGBA(img image.Image) *image.NRGBA {
 	srcBounds := img.Bounds()
@@ -1,10 +1,22 @@
 package imaging
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"image"
 	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+
+	"golang.org/x/image/tiff"
 )
 
 func compareNRGBA(img1, img2 *image.NRGBA, delta int) bool {
@@ -80,6 +92,566 @@ func TestEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestEncodePNGCompressionLevel(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8((i*7 + i*i/13) % 256)
+	}
+
+	sizes := make(map[png.CompressionLevel]int)
+	for _, level := range []png.CompressionLevel{png.NoCompression, png.BestSpeed, png.BestCompression} {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, img, PNG, PNGCompressionLevel(level)); err != nil {
+			t.Errorf("fail encoding PNG with compression level %d: %v", level, err)
+			continue
+		}
+		sizes[level] = buf.Len()
+
+		img2, err := Decode(buf)
+		if err != nil || !compareNRGBA(img, Clone(img2), 0) {
+			t.Errorf("test [PNGCompressionLevel %d] failed: %v", level, err)
+		}
+	}
+
+	if sizes[png.BestSpeed] == sizes[png.BestCompression] || sizes[png.NoCompression] <= sizes[png.BestCompression] {
+		t.Errorf("test [PNGCompressionLevel sizes] failed: %v", sizes)
+	}
+}
+
+func TestEncodeJPEGQuality(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for i := range img.Pix {
+		img.Pix[i] = uint8((i*7 + i*i/13) % 256)
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	encode := func(opts ...EncodeOption) []byte {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, img, JPEG, opts...); err != nil {
+			t.Fatalf("fail encoding JPEG: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	low := encode(JPEGQuality(10))
+	high := encode(JPEGQuality(100))
+	if len(low) >= len(high) {
+		t.Errorf("test [JPEGQuality 10 vs 100] failed: %d >= %d", len(low), len(high))
+	}
+	if !bytes.Equal(encode(), encode(JPEGQuality(95))) {
+		t.Errorf("test [JPEGQuality default] failed")
+	}
+	if !bytes.Equal(encode(JPEGQuality(-5)), encode(JPEGQuality(1))) {
+		t.Errorf("test [JPEGQuality clamp] failed")
+	}
+}
+
+func TestDecodeMaxDimensions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, New(40, 20, color.NRGBA{0x10, 0x20, 0x30, 0xff}), PNG); err != nil {
+		t.Fatalf("fail encoding PNG: %v", err)
+	}
+	data := buf.Bytes()
+
+	td := []struct {
+		desc string
+		opts []DecodeOption
+		err  error
+	}{
+		{"MaxDimensions 40x20", []DecodeOption{MaxDimensions(40, 20)}, nil},
+		{"MaxDimensions 39x0", []DecodeOption{MaxDimensions(39, 0)}, ErrImageTooLarge},
+		{"MaxDimensions 0x19", []DecodeOption{MaxDimensions(0, 19)}, ErrImageTooLarge},
+		{"MaxPixels 800", []DecodeOption{MaxPixels(800)}, nil},
+		{"MaxPixels 799", []DecodeOption{MaxPixels(799)}, ErrImageTooLarge},
+		{"MaxDimensions auto-orientation", []DecodeOption{MaxDimensions(100, 100), AutoOrientation(true)}, nil},
+	}
+	for _, d := range td {
+		img, err := Decode(bytes.NewReader(data), d.opts...)
+		if err != d.err {
+			t.Errorf("test [%s] failed: %v", d.desc, err)
+			continue
+		}
+		if err == nil && img.Bounds() != image.Rect(0, 0, 40, 20) {
+			t.Errorf("test [%s] failed: bounds %v", d.desc, img.Bounds())
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	src := New(8, 4, color.NRGBA{0x40, 0x80, 0xc0, 0xff})
+
+	exif := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00")
+	icc := make([]byte, 70000) // doesn't fit into a single segment
+	for i := range icc {
+		icc[i] = uint8(i % 251)
+	}
+	meta := Metadata{EXIF: exif, ICC: icc}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, src, JPEG, EmbedMetadata(meta)); err != nil {
+		t.Fatalf("fail encoding JPEG with metadata: %v", err)
+	}
+
+	img, got, err := DecodeMeta(buf)
+	if err != nil {
+		t.Fatalf("fail decoding JPEG with metadata: %v", err)
+	}
+	if img.Bounds() != src.Bounds() {
+		t.Errorf("test [DecodeMeta bounds] failed: %v", img.Bounds())
+	}
+	if !bytes.Equal(got.EXIF, exif) || !bytes.Equal(got.ICC, icc) {
+		t.Errorf("test [DecodeMeta JPEG] failed: exif %d bytes, icc %d bytes", len(got.EXIF), len(got.ICC))
+	}
+
+	buf.Reset()
+	Encode(buf, src, PNG, EmbedMetadata(meta))
+	_, got, err = DecodeMeta(buf)
+	if err != nil || got.EXIF != nil || got.ICC != nil {
+		t.Errorf("test [DecodeMeta PNG] failed: %v %#v", err, got)
+	}
+
+	err = Encode(&bytes.Buffer{}, src, JPEG, EmbedMetadata(Metadata{EXIF: make([]byte, 70000)}))
+	if err != ErrMetadataTooLarge {
+		t.Errorf("expected ErrMetadataTooLarge")
+	}
+}
+
+func TestGIFFrames(t *testing.T) {
+	black := color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	red := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	p := color.Palette{black, red, color.NRGBA{}}
+
+	// 3x1 animation: black background, red pixel at x=1 disposed to background, red pixel at x=2
+	frame0 := image.NewPaletted(image.Rect(0, 0, 3, 1), p)
+	frame1 := image.NewPaletted(image.Rect(1, 0, 2, 1), p)
+	frame1.Pix[0] = 1
+	frame2 := image.NewPaletted(image.Rect(2, 0, 3, 1), p)
+	frame2.Pix[0] = 1
+
+	buf := &bytes.Buffer{}
+	err := gif.EncodeAll(buf, &gif.GIF{
+		Image:    []*image.Paletted{frame0, frame1, frame2},
+		Delay:    []int{10, 20, 30},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+	})
+	if err != nil {
+		t.Fatalf("fail encoding GIF: %v", err)
+	}
+
+	frames, delays, err := DecodeGIF(buf)
+	if err != nil {
+		t.Fatalf("fail decoding GIF: %v", err)
+	}
+	want := [][]color.NRGBA{
+		{black, black, black},
+		{black, red, black},
+		{black, {}, red},
+	}
+	if len(frames) != 3 || len(delays) != 3 || delays[2] != 30 {
+		t.Fatalf("test [DecodeGIF 3 frames] failed: %d frames, delays %v", len(frames), delays)
+	}
+	for i, frame := range frames {
+		for x, c := range want[i] {
+			if frame.NRGBAAt(x, 0) != c {
+				t.Errorf("test [DecodeGIF frame %d] failed: %#v", i, frame)
+				break
+			}
+		}
+	}
+
+	buf.Reset()
+	if err := EncodeGIFFrames(buf, frames, delays, GIFLoopCount(2)); err != nil {
+		t.Fatalf("fail encoding GIF frames: %v", err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil || len(g.Image) != 3 || g.LoopCount != 2 {
+		t.Fatalf("test [EncodeGIFFrames] failed: %v", err)
+	}
+	frames2, _, _ := DecodeGIF(bytes.NewReader(buf.Bytes()))
+	for i := range frames {
+		if !compareNRGBA(frames[i], frames2[i], 0) {
+			t.Errorf("test [EncodeGIFFrames frame %d] failed: %#v", i, frames2[i])
+		}
+	}
+
+	if EncodeGIFFrames(buf, nil, nil) == nil || EncodeGIFFrames(buf, frames, delays[:1]) == nil {
+		t.Errorf("expected EncodeGIFFrames errors")
+	}
+}
+
+func TestTIFFPages(t *testing.T) {
+	pages := []image.Image{
+		New(4, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff}),
+		New(2, 5, color.NRGBA{0x40, 0x50, 0x60, 0x80}),
+		New(1, 1, color.NRGBA{0xff, 0x00, 0x00, 0xff}),
+	}
+
+	for _, compression := range []tiff.CompressionType{tiff.Uncompressed, tiff.Deflate} {
+		buf := &bytes.Buffer{}
+		if err := EncodeTIFFPages(buf, pages, TIFFCompression(compression)); err != nil {
+			t.Fatalf("fail encoding TIFF pages: %v", err)
+		}
+		data := buf.Bytes()
+
+		n, err := TIFFPageCount(bytes.NewReader(data))
+		if err != nil || n != len(pages) {
+			t.Errorf("test [TIFFPageCount %d] failed: %d %v", compression, n, err)
+		}
+		for i, page := range pages {
+			img, err := DecodeTIFFPage(bytes.NewReader(data), i)
+			if err != nil || !compareNRGBA(page.(*image.NRGBA), Clone(img), 0) {
+				t.Errorf("test [DecodeTIFFPage %d %d] failed: %v", compression, i, err)
+			}
+		}
+		if _, err := DecodeTIFFPage(bytes.NewReader(data), len(pages)); err == nil {
+			t.Errorf("expected DecodeTIFFPage out of range error")
+		}
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil || !compareNRGBA(pages[0].(*image.NRGBA), Clone(img), 0) {
+			t.Errorf("test [Decode multi-page TIFF %d] failed: %v", compression, err)
+		}
+	}
+
+	if err := Encode(&bytes.Buffer{}, pages[0], TIFF, TIFFCompression(tiff.CCITTGroup4)); err == nil {
+		t.Errorf("expected unsupported TIFF compression error")
+	}
+	if err := EncodeTIFFPages(&bytes.Buffer{}, nil); err == nil {
+		t.Errorf("expected EncodeTIFFPages error")
+	}
+}
+
+func TestSaveCreateDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("fail creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := New(2, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	filename := filepath.Join(dir, "a", "b", "out.png")
+
+	if err := Save(img, filename); err == nil {
+		t.Errorf("expected Save error for missing directory")
+	}
+	if err := Save(img, filename, CreateDirs(0755)); err != nil {
+		t.Fatalf("test [Save CreateDirs] failed: %v", err)
+	}
+
+	img2, err := Open(filename)
+	if err != nil || !compareNRGBA(img, Clone(img2), 0) {
+		t.Errorf("test [Save CreateDirs open] failed: %v", err)
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	img := New(3, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	for _, format := range []Format{PNG, TIFF, JPEG} {
+		data, err := EncodeBytes(img, format)
+		if err != nil {
+			t.Fatalf("test [EncodeBytes %v] failed: %v", format, err)
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format); err != nil || !bytes.Equal(data, buf.Bytes()) {
+			t.Errorf("test [EncodeBytes %v] failed: data differs from Encode", format)
+		}
+
+		got, err := DecodeBytes(data)
+		if err != nil || !compareNRGBA(Clone(got), img, 2) {
+			t.Errorf("test [DecodeBytes %v] failed: %v", format, err)
+		}
+	}
+
+	if _, err := EncodeBytes(img, Format(-1)); err != ErrUnsupportedFormat {
+		t.Errorf("expected EncodeBytes error ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := DecodeBytes([]byte("not an image")); err == nil {
+		t.Errorf("expected DecodeBytes error")
+	}
+	if _, err := DecodeBytes(nil); err == nil {
+		t.Errorf("expected DecodeBytes error for empty data")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	img := New(3, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF} {
+		data, err := EncodeBytes(img, format)
+		if err != nil {
+			t.Fatalf("fail encoding %v: %v", format, err)
+		}
+
+		r := bytes.NewReader(data)
+		if got, err := DetectFormat(r); got != format || err != nil {
+			t.Errorf("test [DetectFormat %v] failed: %v %v", format, got, err)
+		}
+		if r.Len() != len(data) {
+			t.Errorf("test [DetectFormat %v seeker] failed: %d bytes consumed", format, len(data)-r.Len())
+		}
+
+		br := bufio.NewReader(bytes.NewBuffer(data))
+		if got, err := DetectFormat(br); got != format || err != nil {
+			t.Errorf("test [DetectFormat %v bufio] failed: %v %v", format, got, err)
+		}
+		if _, err := Decode(br); err != nil {
+			t.Errorf("test [DetectFormat %v bufio decode] failed: %v", format, err)
+		}
+	}
+
+	td := []struct {
+		desc string
+		data string
+		want Format
+	}{
+		{"BMP", "BM\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", BMP},
+		{"TIFF big-endian", "MM\x00*\x00\x00\x00\x08", TIFF},
+		{"WebP", "RIFF\x1a\x00\x00\x00WEBPVP8L", WEBP},
+		{"RIFF not WebP", "RIFF\x1a\x00\x00\x00WAVEfmt ", UnknownFormat},
+		{"short", "\xff\xd8", UnknownFormat},
+		{"empty", "", UnknownFormat},
+		{"text", "hello, world!", UnknownFormat},
+	}
+	for _, d := range td {
+		buf := bytes.NewBufferString(d.data)
+		if got, err := DetectFormat(buf); got != d.want || err != nil {
+			t.Errorf("test [DetectFormat %s] failed: %v %v", d.desc, got, err)
+		}
+	}
+
+	if s := UnknownFormat.String(); s != "Unknown" {
+		t.Errorf("test [UnknownFormat String] failed: %q", s)
+	}
+}
+
+func TestDimensionsFormats(t *testing.T) {
+	img := New(5, 3, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	td := []struct {
+		format Format
+		want   string
+	}{
+		{JPEG, "jpeg"},
+		{PNG, "png"},
+		{GIF, "gif"},
+		{TIFF, "tiff"},
+	}
+	for _, d := range td {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, d.format); err != nil {
+			t.Fatalf("fail encoding %v: %v", d.format, err)
+		}
+
+		r := bytes.NewReader(buf.Bytes())
+		w, h, format, err := Dimensions(r)
+		if err != nil || w != 5 || h != 3 || format != d.want {
+			t.Errorf("test [Dimensions %v] failed: %d %d %q %v", d.format, w, h, format, err)
+		}
+		// the reader is rewound
+		if _, err := Decode(r); err != nil {
+			t.Errorf("test [Dimensions %v decode] failed: %v", d.format, err)
+		}
+
+		// a plain reader is consumed partially
+		w, h, format, err = Dimensions(bytes.NewBufferString(buf.String()))
+		if err != nil || w != 5 || h != 3 || format != d.want {
+			t.Errorf("test [Dimensions %v buffer] failed: %d %d %q %v", d.format, w, h, format, err)
+		}
+	}
+
+	r := bytes.NewReader([]byte("not an image"))
+	if _, _, _, err := Dimensions(r); err == nil {
+		t.Errorf("expected Dimensions error")
+	}
+	if r.Len() != len("not an image") {
+		t.Errorf("test [Dimensions error rewind] failed: %d bytes left", r.Len())
+	}
+}
+
+func TestProcessDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imaging")
+	if err != nil {
+		t.Fatalf("fail creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inDir := filepath.Join(dir, "in")
+	outDir := filepath.Join(dir, "out")
+	img := New(4, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	for _, name := range []string{"a.png", "sub/b.png", "sub/deep/c.bmp"} {
+		if err := Save(img, filepath.Join(inDir, name), CreateDirs(0755)); err != nil {
+			t.Fatalf("fail saving test image: %v", err)
+		}
+	}
+	if err := Save(New(3, 3, color.Black), filepath.Join(inDir, "skip.png")); err != nil {
+		t.Fatalf("fail saving test image: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(inDir, "sub", "bad.png"), []byte("not an image"), 0644); err != nil {
+		t.Fatalf("fail writing test file: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(inDir, "notes.txt"), []byte("text"), 0644); err != nil {
+		t.Fatalf("fail writing test file: %v", err)
+	}
+
+	var mu sync.Mutex
+	calls := 0
+	err = ProcessDir(inDir, outDir, func(img image.Image) image.Image {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		if img.Bounds().Dx() != 4 {
+			return nil
+		}
+		return Rotate90(img)
+	}, ProcessWorkers(3))
+
+	errs, ok := err.(BatchError)
+	if !ok || len(errs) != 1 || errs[0].Path != filepath.Join(inDir, "sub", "bad.png") {
+		t.Fatalf("test [ProcessDir errors] failed: %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("test [ProcessDir calls] failed: %d", calls)
+	}
+
+	want := Rotate90(img)
+	for _, name := range []string{"a.png", "sub/b.png", "sub/deep/c.bmp"} {
+		got, err := Open(filepath.Join(outDir, name))
+		if err != nil || !compareNRGBA(Clone(got), want, 0) {
+			t.Errorf("test [ProcessDir %s] failed: %v", name, err)
+		}
+	}
+	for _, name := range []string{"sub/bad.png", "notes.txt", "skip.png"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); !os.IsNotExist(err) {
+			t.Errorf("test [ProcessDir %s] failed: unexpected output file", name)
+		}
+	}
+
+	// skipped files
+	err = ProcessDir(outDir, filepath.Join(dir, "out2"), func(img image.Image) image.Image { return nil })
+	if err != nil {
+		t.Errorf("test [ProcessDir skip] failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out2")); !os.IsNotExist(err) {
+		t.Errorf("test [ProcessDir skip] failed: unexpected output directory")
+	}
+
+	identity := func(img image.Image) image.Image { return img }
+	if err := ProcessDir(filepath.Join(dir, "missing"), outDir, identity); err == nil {
+		t.Errorf("expected ProcessDir error for missing directory")
+	}
+}
+
+func TestDecodeWebP(t *testing.T) {
+	td := []struct {
+		desc string
+		data string
+	}{
+		{"WebP lossy 1x1", "UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA"},
+		{"WebP lossless 1x1", "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="},
+	}
+	for _, d := range td {
+		data, _ := base64.StdEncoding.DecodeString(d.data)
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("test [%s] failed: %v", d.desc, err)
+			continue
+		}
+		if img.Bounds() != image.Rect(0, 0, 1, 1) {
+			t.Errorf("test [%s] failed: bounds %v", d.desc, img.Bounds())
+		}
+	}
+
+	if webpEncoder == nil {
+		err := Encode(&bytes.Buffer{}, New(1, 1, color.White), WEBP)
+		if err != ErrUnsupportedFormat {
+			t.Errorf("expected ErrUnsupportedFormat")
+		}
+
+		dir, err := ioutil.TempDir("", "imaging")
+		if err != nil {
+			t.Fatalf("fail creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "sub", "out.webp")
+		if err := Save(New(1, 1, color.White), filename, CreateDirs(0755)); err != ErrUnsupportedFormat {
+			t.Errorf("expected Save ErrUnsupportedFormat, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+			t.Errorf("test [Save WebP unsupported] failed: file or directory created")
+		}
+	}
+}
+
+// jpegWithOrientation encodes the image to JPEG and inserts an EXIF segment
+// containing the specified orientation tag.
+func jpegWithOrientation(img image.Image, orientation uint16, order binary.ByteOrder) []byte {
+	buf := &bytes.Buffer{}
+	jpeg.Encode(buf, img, nil)
+	data := buf.Bytes()
+
+	tiff := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(tiff, order, uint16(0x002a))
+	binary.Write(tiff, order, uint32(8))
+	binary.Write(tiff, order, uint16(1))
+	binary.Write(tiff, order, uint16(0x0112))
+	binary.Write(tiff, order, uint16(3))
+	binary.Write(tiff, order, uint32(1))
+	binary.Write(tiff, order, orientation)
+	binary.Write(tiff, order, uint16(0))
+	binary.Write(tiff, order, uint32(0))
+
+	app1 := &bytes.Buffer{}
+	app1.Write([]byte{0xff, 0xe1})
+	binary.Write(app1, binary.BigEndian, uint16(2+6+tiff.Len()))
+	app1.WriteString("Exif\x00\x00")
+	app1.Write(tiff.Bytes())
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1.Bytes()...)
+	return append(out, data[2:]...)
+}
+
+func TestDecodeAutoOrientation(t *testing.T) {
+	src := New(4, 2, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	td := []struct {
+		desc        string
+		orientation uint16
+		order       binary.ByteOrder
+		auto        bool
+		want        image.Rectangle
+	}{
+		{"AutoOrientation 6 LE", 6, binary.LittleEndian, true, image.Rect(0, 0, 2, 4)},
+		{"AutoOrientation 8 BE", 8, binary.BigEndian, true, image.Rect(0, 0, 2, 4)},
+		{"AutoOrientation 3 LE", 3, binary.LittleEndian, true, image.Rect(0, 0, 4, 2)},
+		{"AutoOrientation 6 disabled", 6, binary.LittleEndian, false, image.Rect(0, 0, 4, 2)},
+	}
+	for _, d := range td {
+		data := jpegWithOrientation(src, d.orientation, d.order)
+		if got := readOrientation(bytes.NewReader(data)); got != int(d.orientation) {
+			t.Errorf("test [%s] failed: orientation %d", d.desc, got)
+		}
+		img, err := Decode(bytes.NewReader(data), AutoOrientation(d.auto))
+		if err != nil {
+			t.Errorf("test [%s] failed: %v", d.desc, err)
+			continue
+		}
+		if img.Bounds() != d.want {
+			t.Errorf("test [%s] failed: %v", d.desc, img.Bounds())
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	Encode(buf, src, PNG)
+	if got := readOrientation(bytes.NewReader(buf.Bytes())); got != 1 {
+		t.Errorf("test [readOrientation PNG] failed: %d", got)
+	}
+}
+
 func TestNew(t *testing.T) {
 	td := []struct {
 		desc      string
@@ -121,6 +693,173 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestGradients(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+	gray := func(vs ...uint8) []uint8 {
+		var pix []uint8
+		for _, v := range vs {
+			pix = append(pix, v, v, v, 0xff)
+		}
+		return pix
+	}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"LinearGradient 3x1 0",
+			LinearGradient(3, 1, black, white, 0),
+			&image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: gray(0x00, 0xbc, 0xff)},
+		},
+		{
+			"LinearGradient 3x1 180",
+			LinearGradient(3, 1, black, white, 180),
+			&image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: gray(0xff, 0xbc, 0x00)},
+		},
+		{
+			"LinearGradient 1x3 90",
+			LinearGradient(1, 3, black, white, 90),
+			&image.NRGBA{Rect: image.Rect(0, 0, 1, 3), Stride: 1 * 4, Pix: gray(0xff, 0xbc, 0x00)},
+		},
+		{
+			"LinearGradient 5x1 0",
+			LinearGradient(5, 1, black, white, 0),
+			&image.NRGBA{Rect: image.Rect(0, 0, 5, 1), Stride: 5 * 4, Pix: gray(0x00, 0x89, 0xbc, 0xe1, 0xff)},
+		},
+		{
+			"LinearGradient 3x1 0 transparent",
+			LinearGradient(3, 1, color.NRGBA{255, 0, 0, 255}, color.NRGBA{}, 0),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00},
+			},
+		},
+		{
+			"LinearGradient 1x1",
+			LinearGradient(1, 1, black, white, 45),
+			&image.NRGBA{Rect: image.Rect(0, 0, 1, 1), Stride: 1 * 4, Pix: gray(0x00)},
+		},
+		{
+			"RadialGradient 4x3 (1,1) 2",
+			RadialGradient(4, 3, image.Pt(1, 1), 2, black, white),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 3),
+				Stride: 4 * 4,
+				Pix: gray(
+					0xdb, 0xbc, 0xdb, 0xff,
+					0xbc, 0x00, 0xbc, 0xff,
+					0xdb, 0xbc, 0xdb, 0xff,
+				),
+			},
+		},
+		{
+			"RadialGradient 2x1 (0,0) 0",
+			RadialGradient(2, 1, image.Pt(0, 0), 0, black, white),
+			&image.NRGBA{Rect: image.Rect(0, 0, 2, 1), Stride: 2 * 4, Pix: gray(0xff, 0xff)},
+		},
+		{
+			"LinearGradient 0x1",
+			LinearGradient(0, 1, black, white, 0),
+			&image.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestPremultiply(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0x80, 0x01, 0xff,
+			0xff, 0x80, 0x01, 0x80,
+			0xff, 0x80, 0x40, 0x01,
+			0xff, 0x80, 0x01, 0x00,
+		},
+	}
+	premul := []uint8{
+		0xff, 0x80, 0x01, 0xff,
+		0x80, 0x40, 0x01, 0x80,
+		0x01, 0x01, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	unpremul := []uint8{
+		0xff, 0x80, 0x01, 0xff,
+		0xff, 0x80, 0x02, 0x80,
+		0xff, 0xff, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	got := Premultiply(src)
+	want := &image.NRGBA{Rect: image.Rect(0, 0, 4, 1), Stride: 4 * 4, Pix: premul}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [Premultiply] failed: %#v", got)
+	}
+
+	got = Unpremultiply(got)
+	want = &image.NRGBA{Rect: image.Rect(0, 0, 4, 1), Stride: 4 * 4, Pix: unpremul}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [Unpremultiply] failed: %#v", got)
+	}
+
+	rgba := ToRGBA(src)
+	if rgba.Rect != image.Rect(0, 0, 4, 1) || !bytes.Equal(rgba.Pix, premul) {
+		t.Errorf("test [ToRGBA] failed: %#v", rgba)
+	}
+	if c := rgba.RGBAAt(1, 0); c != (color.RGBA{0x80, 0x40, 0x01, 0x80}) {
+		t.Errorf("test [ToRGBA] failed: %#v", c)
+	}
+
+	// opaque pixels survive the round trip through image.RGBA
+	opaque := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x12, 0x34, 0x56, 0xff, 0xfe, 0x01, 0x80, 0xff},
+	}
+	if got := Clone(ToRGBA(opaque)); !compareNRGBA(got, opaque, 0) {
+		t.Errorf("test [ToRGBA round trip] failed: %#v", got)
+	}
+}
+
+func TestAsNRGBA(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	if got := AsNRGBA(src); got != src {
+		t.Errorf("test [AsNRGBA nrgba] failed: %p != %p", got, src)
+	}
+
+	shifted := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	got := AsNRGBA(shifted)
+	if got == shifted || !compareNRGBA(got, want, 0) {
+		t.Errorf("test [AsNRGBA shifted] failed: %#v", got)
+	}
+
+	gray := &image.Gray{Rect: image.Rect(0, 0, 2, 1), Stride: 2, Pix: []uint8{0x00, 0x80}}
+	want = &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0x80, 0x80, 0x80, 0xff},
+	}
+	if got := AsNRGBA(gray); !compareNRGBA(got, want, 0) {
+		t.Errorf("test [AsNRGBA gray] failed: %#v", got)
+	}
+}
+
 func TestClone(t *testing.T) {
 	td := []struct {
 		desc string
@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"image"
+)
+
+// Pipeline applies a sequence of operations to an image. Each method applies the operation
+// immediately and returns the pipeline, so the calls can be chained. The pointwise adjustments
+// (AdjustBrightness, AdjustContrast, AdjustGamma, Invert and Grayscale) reuse the current buffer
+// of the pipeline instead of allocating a new image. The source image is never modified.
+//
+// Usage example:
+//
+//		dstImage := imaging.NewPipeline(srcImage).
+//			Resize(800, 0, imaging.Lanczos).
+//			Grayscale().
+//			AdjustContrast(10).
+//			Result()
+//
+type Pipeline struct {
+	img   *image.NRGBA
+	owned bool // img is a buffer created by the pipeline that can be modified
+}
+
+// NewPipeline creates a new pipeline for the img image.
+func NewPipeline(img image.Image) *Pipeline {
+	return &Pipeline{img: toNRGBA(img)}
+}
+
+// Result returns the resulting image. The pipeline must not be used after calling Result.
+func (p *Pipeline) Result() *image.NRGBA {
+	if !p.owned {
+		return Clone(p.img)
+	}
+	return p.img
+}
+
+// Apply applies the fn function to the current image. The function must not modify its argument.
+func (p *Pipeline) Apply(fn func(img image.Image) *image.NRGBA) *Pipeline {
+	dst := toNRGBA(fn(p.img))
+	if dst != p.img {
+		p.img = dst
+		p.owned = true
+	}
+	return p
+}
+
+// buffer returns the current image ready to be modified in place.
+func (p *Pipeline) buffer() *image.NRGBA {
+	if !p.owned {
+		p.img = Clone(p.img)
+		p.owned = true
+	}
+	return p.img
+}
+
+// Resize resizes the image like Resize does.
+func (p *Pipeline) Resize(width, height int, filter ResampleFilter) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Resize(img, width, height, filter) })
+}
+
+// Fit scales down the image like Fit does.
+func (p *Pipeline) Fit(width, height int, filter ResampleFilter) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Fit(img, width, height, filter) })
+}
+
+// Thumbnail scales and crops the image like Thumbnail does.
+func (p *Pipeline) Thumbnail(width, height int, filter ResampleFilter) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Thumbnail(img, width, height, filter) })
+}
+
+// Crop cuts out the rectangular region like Crop does.
+func (p *Pipeline) Crop(rect image.Rectangle) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Crop(img, rect) })
+}
+
+// CropAnchor cuts out the rectangular region like CropAnchor does.
+func (p *Pipeline) CropAnchor(width, height int, anchor Anchor) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return CropAnchor(img, width, height, anchor) })
+}
+
+// Rotate90 rotates the image like Rotate90 does.
+func (p *Pipeline) Rotate90() *Pipeline {
+	return p.Apply(Rotate90)
+}
+
+// Rotate180 rotates the image like Rotate180 does.
+func (p *Pipeline) Rotate180() *Pipeline {
+	return p.Apply(Rotate180)
+}
+
+// Rotate270 rotates the image like Rotate270 does.
+func (p *Pipeline) Rotate270() *Pipeline {
+	return p.Apply(Rotate270)
+}
+
+// FlipH flips the image horizontally like FlipH does.
+func (p *Pipeline) FlipH() *Pipeline {
+	return p.Apply(FlipH)
+}
+
+// FlipV flips the image vertically like FlipV does.
+func (p *Pipeline) FlipV() *Pipeline {
+	return p.Apply(FlipV)
+}
+
+// Blur blurs the image like Blur does.
+func (p *Pipeline) Blur(sigma float64) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Blur(img, sigma) })
+}
+
+// Sharpen sharpens the image like Sharpen does.
+func (p *Pipeline) Sharpen(sigma float64) *Pipeline {
+	return p.Apply(func(img image.Image) *image.NRGBA { return Sharpen(img, sigma) })
+}
+
+// AdjustBrightness changes the brightness of the image in place like AdjustBrightness does.
+func (p *Pipeline) AdjustBrightness(percentage float64) *Pipeline {
+	AdjustBrightnessInPlace(p.buffer(), percentage)
+	return p
+}
+
+// AdjustContrast changes the contrast of the image in place like AdjustContrast does.
+func (p *Pipeline) AdjustContrast(percentage float64) *Pipeline {
+	AdjustContrastInPlace(p.buffer(), percentage)
+	return p
+}
+
+// AdjustGamma performs a gamma correction of the image in place like AdjustGamma does.
+func (p *Pipeline) AdjustGamma(gamma float64) *Pipeline {
+	AdjustGammaInPlace(p.buffer(), gamma)
+	return p
+}
+
+// Invert inverts the image in place like Invert does.
+func (p *Pipeline) Invert() *Pipeline {
+	InvertInPlace(p.buffer())
+	return p
+}
+
+// Grayscale converts the image to grayscale in place like Grayscale does.
+func (p *Pipeline) Grayscale() *Pipeline {
+	GrayscaleInPlace(p.buffer())
+	return p
+}
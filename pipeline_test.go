@@ -0,0 +1,64 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	newSrc := func() *image.NRGBA {
+		return &image.NRGBA{
+			Rect:   image.Rect(0, 0, 3, 2),
+			Stride: 3 * 4,
+			Pix: []uint8{
+				0xcc, 0x00, 0x00, 0x01, 0x00, 0xcc, 0x00, 0x02, 0x00, 0x00, 0xcc, 0x03,
+				0x11, 0x22, 0x33, 0xff, 0x33, 0x22, 0x11, 0xff, 0xaa, 0x33, 0xbb, 0xff,
+			},
+		}
+	}
+	src := newSrc()
+
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"Pipeline empty",
+			NewPipeline(src).Result(),
+			Clone(src),
+		},
+		{
+			"Pipeline pointwise",
+			NewPipeline(src).Grayscale().AdjustContrast(10).AdjustBrightness(-5).AdjustGamma(1.2).Invert().Result(),
+			Invert(AdjustGamma(AdjustBrightness(AdjustContrast(Grayscale(src), 10), -5), 1.2)),
+		},
+		{
+			"Pipeline transforms",
+			NewPipeline(src).Resize(6, 4, Linear).Rotate90().FlipH().Crop(image.Rect(1, 1, 3, 4)).Blur(0.5).Result(),
+			Blur(Crop(FlipH(Rotate90(Resize(src, 6, 4, Linear))), image.Rect(1, 1, 3, 4)), 0.5),
+		},
+		{
+			"Pipeline mixed",
+			NewPipeline(src).Invert().Fit(2, 2, Box).Grayscale().Rotate180().Sharpen(1).Result(),
+			Sharpen(Rotate180(Grayscale(Fit(Invert(src), 2, 2, Box))), 1),
+		},
+		{
+			"Pipeline apply",
+			NewPipeline(src).Apply(Transpose).CropAnchor(2, 2, Center).Result(),
+			CropAnchor(Transpose(src), 2, 2, Center),
+		},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+
+	// the function returning its argument
+	NewPipeline(src).Apply(func(img image.Image) *image.NRGBA { return img.(*image.NRGBA) }).Invert().Result()
+
+	if !compareNRGBA(src, newSrc(), 0) {
+		t.Errorf("test [Pipeline source] failed: source image modified: %#v", src)
+	}
+}
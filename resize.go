@@ -1,8 +1,11 @@
 package imaging
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"math"
+	"strings"
 )
 
 type iwpair struct {
@@ -22,6 +25,7 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) []pweights {
 		scale = 1.0
 	}
 	ru := math.Ceil(scale * filter.Support)
+	kscale := kernelScale(filter)
 
 	out := make([]pweights, dstSize)
 
@@ -39,18 +43,54 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) []pweights {
 
 		wsum := int32(0)
 		for u := startu; u <= endu; u++ {
-			w := int32(0xff * filter.Kernel((float64(u)-fu)/scale))
+			w := int32(0xff * kscale * filter.Kernel((float64(u)-fu)/scale))
 			if w != 0 {
 				wsum += w
 				out[v].iwpairs = append(out[v].iwpairs, iwpair{u, w})
 			}
 		}
+		if wsum == 0 {
+			// the kernel of a custom filter may be zero at all the sample points,
+			// the closest source pixel is used in this case
+			u := int(math.Floor(fu + 0.5))
+			if u < 0 {
+				u = 0
+			}
+			if u > srcSize-1 {
+				u = srcSize - 1
+			}
+			out[v].iwpairs = []iwpair{{u, 0xff}}
+			wsum = 0xff
+		}
 		out[v].wsum = wsum
 	}
 
 	return out
 }
 
+// kernelScale returns the power of two that brings the integral of the filter kernel close to one.
+// The weights are quantized to 8 bits, so the kernels of a much larger or smaller magnitude would
+// overflow the sums or lose all the precision. The scaling by a power of two is exact: the built-in
+// filters integrate to one and keep their weights, the kernel multiplied by a power of two gives
+// the same weights as the original one.
+func kernelScale(filter ResampleFilter) float64 {
+	const steps = 64 // samples per unit
+	n := int(math.Ceil(filter.Support)) * steps
+	sum := 0.0
+	for i := -n; i < n; i++ {
+		sum += filter.Kernel((float64(i) + 0.5) / steps)
+	}
+	sum /= steps
+	if !(sum > 0) || math.IsInf(sum, 0) {
+		return 1
+	}
+	exp := math.Floor(math.Log2(sum) + 0.5)
+	if exp == 0 {
+		return 1
+	}
+	return math.Ldexp(1, -int(exp))
+}
+
 // Resize resizes the image to the specified width and height using the specified resampling
 // filter and returns the transformed image. If one of width or height is 0, the image aspect
 // ratio is preserved.
@@ -63,6 +103,24 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) []pweights {
 //		dstImage := imaging.Resize(srcImage, 800, 600, imaging.Lanczos)
 //
 func Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	return resize(img, width, height, filter, false)
+}
+
+// ResizeClamped resizes the image like Resize does, but each resulting color value is clamped to
+// the range of the source pixels that contribute to it. It removes the overshoot (ringing and halos
+// at high-contrast edges) produced by the filters with negative lobes, like Lanczos or CatmullRom,
+// which is useful for clean text thumbnails. The results for the filters without negative lobes
+// are the same as the Resize results.
+//
+// Usage example:
+//
+//		dstImage := imaging.ResizeClamped(srcImage, 200, 0, imaging.Lanczos)
+//
+func ResizeClamped(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	return resize(img, width, height, filter, true)
+}
+
+func resize(img image.Image, width, height int, filter ResampleFilter, clampRange bool) *image.NRGBA {
 	dstW, dstH := width, height
 
 	if dstW < 0 || dstH < 0 {
@@ -100,20 +158,251 @@ func Resize(img image.Image, width, height int, filter ResampleFilter) *image.NR
 	} else {
 		// two-pass resize
 		if srcW != dstW {
-			dst = resizeHorizontal(src, dstW, filter)
+			dst = resizeHorizontal(src, dstW, filter, clampRange)
 		} else {
 			dst = src
 		}
 
 		if srcH != dstH {
-			dst = resizeVertical(dst, dstH, filter)
+			dst = resizeVertical(dst, dstH, filter, clampRange)
 		}
 	}
 
 	return dst
 }
 
-func resizeHorizontal(src *image.NRGBA, width int, filter ResampleFilter) *image.NRGBA {
+// ResizeLinear resizes the image like Resize does, but the color channels are converted to
+// linear light before the filtering and back to sRGB after it. It gives more accurate results,
+// especially when downscaling high-contrast images, which look too dark when resized in sRGB space.
+//
+// Usage example:
+//
+//		dstImage := imaging.ResizeLinear(srcImage, 800, 600, imaging.Lanczos)
+//
+func ResizeLinear(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	if filter.Support <= 0.0 {
+		// nearest-neighbor doesn't mix colors
+		return Resize(img, width, height, filter)
+	}
+
+	dstW, dstH := width, height
+
+	if dstW < 0 || dstH < 0 {
+		return &image.NRGBA{}
+	}
+	if dstW == 0 && dstH == 0 {
+		return &image.NRGBA{}
+	}
+
+	src := toNRGBA(img)
+
+	srcW := src.Bounds().Max.X
+	srcH := src.Bounds().Max.Y
+
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	// if new width or height is 0 then preserve aspect ratio, minimum 1px
+	if dstW == 0 {
+		tmpW := float64(dstH) * float64(srcW) / float64(srcH)
+		dstW = int(math.Max(1.0, math.Floor(tmpW+0.5)))
+	}
+	if dstH == 0 {
+		tmpH := float64(dstW) * float64(srcH) / float64(srcW)
+		dstH = int(math.Max(1.0, math.Floor(tmpH+0.5)))
+	}
+
+	// convert to linear light, alpha is kept in the 0..1 range too
+	buf := make([]float64, srcW*srcH*4)
+	parallel(srcH, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < srcW; x++ {
+				i := y*src.Stride + x*4
+				j := (y*srcW + x) * 4
+				buf[j+0] = srgbToLinear(src.Pix[i+0])
+				buf[j+1] = srgbToLinear(src.Pix[i+1])
+				buf[j+2] = srgbToLinear(src.Pix[i+2])
+				buf[j+3] = float64(src.Pix[i+3]) / 255.0
+			}
+		}
+	})
+
+	if srcW != dstW {
+		buf = resizeLinearPass(buf, srcW, srcH, dstW, filter, true)
+	}
+	if srcH != dstH {
+		buf = resizeLinearPass(buf, dstW, srcH, dstH, filter, false)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	parallel(dstH, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < dstW; x++ {
+				i := (y*dstW + x) * 4
+				j := y*dst.Stride + x*4
+				dst.Pix[j+0] = linearToSRGB8(buf[i+0])
+				dst.Pix[j+1] = linearToSRGB8(buf[i+1])
+				dst.Pix[j+2] = linearToSRGB8(buf[i+2])
+				dst.Pix[j+3] = clamp(buf[i+3] * 255.0)
+			}
+		}
+	})
+
+	return dst
+}
+
+// Downscale resizes the image to the specified width and height like Resize does,
+// but when the source dimensions are close to multiples of the new dimensions, each output pixel
+// is computed as the average of the corresponding block of source pixels. This fast path is
+// much faster than the filtered resize for large downscale factors and gives clean results.
+// The dimension is close to a multiple if the remaining pixels don't exceed half of a block,
+// they are cropped evenly from both sides of the image, e.g. 4001x3000 is downscaled to 400x300
+// by averaging the 10x10 blocks of the central 4000x3000 pixels.
+// In all other cases the image is resized using Resize with the specified filter.
+//
+// Usage example:
+//
+//		dstImage := imaging.Downscale(srcImage, 400, 300, imaging.Lanczos)
+//
+func Downscale(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+
+	dstW, dstH := width, height
+	if dstW <= 0 || dstH <= 0 || srcW < dstW || srcH < dstH {
+		return Resize(img, width, height, filter)
+	}
+	factorX, factorY := srcW/dstW, srcH/dstH
+	remX, remY := srcW%dstW, srcH%dstH
+	if 2*remX > factorX || 2*remY > factorY || (factorX == 1 && factorY == 1) {
+		return Resize(img, width, height, filter)
+	}
+
+	src := toNRGBA(img)
+	if remX != 0 || remY != 0 {
+		src = CropCenter(src, srcW-remX, srcH-remY)
+	}
+	return resizeArea(src, factorX, factorY)
+}
+
+// ScaleInt upscales the image by the integer factor and returns the transformed image. Each source
+// pixel is replicated into a factor x factor block of pixels, no interpolation is applied, which is
+// useful for pixel art. It gives the same result as Resize with NearestNeighbor, but it's faster.
+// If factor is less than 1, an empty image is returned. To downscale the image by the integer
+// factor averaging the blocks of pixels, use Downscale.
+//
+// Usage example:
+//
+//		dstImage := imaging.ScaleInt(spriteImage, 4)
+//
+func ScaleInt(img image.Image, factor int) *image.NRGBA {
+	if factor < 1 {
+		return &image.NRGBA{}
+	}
+	src := toNRGBA(img)
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, srcW*factor, srcH*factor))
+	rowSize := srcW * factor * 4
+
+	parallel(srcH, func(partStart, partEnd int) {
+		for srcY := partStart; srcY < partEnd; srcY++ {
+			i := srcY * src.Stride
+			j0 := srcY * factor * dst.Stride
+			j := j0
+			for x := 0; x < srcW; x++ {
+				for k := 0; k < factor; k++ {
+					copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+					j += 4
+				}
+				i += 4
+			}
+			// the other rows of the block are the copies of the first one
+			for k := 1; k < factor; k++ {
+				copy(dst.Pix[j0+k*dst.Stride:j0+k*dst.Stride+rowSize], dst.Pix[j0:j0+rowSize])
+			}
+		}
+	})
+
+	return dst
+}
+
+// resizeArea downscales the image by the integer factors averaging the blocks of source pixels.
+func resizeArea(src *image.NRGBA, factorX, factorY int) *image.NRGBA {
+	dstW := src.Bounds().Max.X / factorX
+	dstH := src.Bounds().Max.Y / factorY
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	n := uint64(factorX * factorY)
+
+	parallel(dstH, func(partStart, partEnd int) {
+		for dstY := partStart; dstY < partEnd; dstY++ {
+			for dstX := 0; dstX < dstW; dstX++ {
+				var c [4]uint64
+				for y := dstY * factorY; y < (dstY+1)*factorY; y++ {
+					i := y*src.Stride + dstX*factorX*4
+					for x := 0; x < factorX; x++ {
+						c[0] += uint64(src.Pix[i+0])
+						c[1] += uint64(src.Pix[i+1])
+						c[2] += uint64(src.Pix[i+2])
+						c[3] += uint64(src.Pix[i+3])
+						i += 4
+					}
+				}
+				j := dstY*dst.Stride + dstX*4
+				dst.Pix[j+0] = uint8((c[0] + n/2) / n)
+				dst.Pix[j+1] = uint8((c[1] + n/2) / n)
+				dst.Pix[j+2] = uint8((c[2] + n/2) / n)
+				dst.Pix[j+3] = uint8((c[3] + n/2) / n)
+			}
+		}
+	})
+
+	return dst
+}
+
+// resizeLinearPass resizes the w x h buffer of float64 pixels horizontally (to the width size)
+// or vertically (to the height size) and returns the new buffer.
+func resizeLinearPass(src []float64, w, h, size int, filter ResampleFilter, horizontal bool) []float64 {
+	srcSize, lines, dstW := h, w, w
+	if horizontal {
+		srcSize, lines, dstW = w, h, size
+	}
+
+	dst := make([]float64, lines*size*4)
+	weights := precomputeWeights(size, srcSize, filter)
+
+	parallel(lines, func(partStart, partEnd int) {
+		for line := partStart; line < partEnd; line++ {
+			for v := 0; v < size; v++ {
+				var c [4]float64
+				for _, iw := range weights[v].iwpairs {
+					i := (iw.i*w + line) * 4
+					if horizontal {
+						i = (line*w + iw.i) * 4
+					}
+					wf := float64(iw.w)
+					c[0] += src[i+0] * wf
+					c[1] += src[i+1] * wf
+					c[2] += src[i+2] * wf
+					c[3] += src[i+3] * wf
+				}
+				j := (v*dstW + line) * 4
+				if horizontal {
+					j = (line*dstW + v) * 4
+				}
+				sum := float64(weights[v].wsum)
+				for k := 0; k < 4; k++ {
+					dst[j+k] = c[k] / sum
+				}
+			}
+		}
+	})
+
+	return dst
+}
+
+func resizeHorizontal(src *image.NRGBA, width int, filter ResampleFilter, clampRange bool) *image.NRGBA {
 	srcBounds := src.Bounds()
 	srcW := srcBounds.Max.X
 	srcH := srcBounds.Max.Y
@@ -128,13 +417,14 @@ func resizeHorizontal(src *image.NRGBA, width int, filter ResampleFilter) *image
 	parallel(dstH, func(partStart, partEnd int) {
 		for dstY := partStart; dstY < partEnd; dstY++ {
 			for dstX := 0; dstX < dstW; dstX++ {
-				var c [4]int32
+				var c [4]int64
 				for _, iw := range weights[dstX].iwpairs {
 					i := dstY*src.Stride + iw.i*4
-					c[0] += int32(src.Pix[i+0]) * iw.w
-					c[1] += int32(src.Pix[i+1]) * iw.w
-					c[2] += int32(src.Pix[i+2]) * iw.w
-					c[3] += int32(src.Pix[i+3]) * iw.w
+					w := int64(iw.w)
+					c[0] += int64(src.Pix[i+0]) * w
+					c[1] += int64(src.Pix[i+1]) * w
+					c[2] += int64(src.Pix[i+2]) * w
+					c[3] += int64(src.Pix[i+3]) * w
 				}
 				j := dstY*dst.Stride + dstX*4
 				sum := weights[dstX].wsum
@@ -142,6 +432,9 @@ func resizeHorizontal(src *image.NRGBA, width int, filter ResampleFilter) *image
 				dst.Pix[j+1] = clampint32(int32(float32(c[1])/float32(sum) + 0.5))
 				dst.Pix[j+2] = clampint32(int32(float32(c[2])/float32(sum) + 0.5))
 				dst.Pix[j+3] = clampint32(int32(float32(c[3])/float32(sum) + 0.5))
+				if clampRange {
+					clampToSources(dst.Pix[j:j+4], src.Pix, weights[dstX].iwpairs, dstY*src.Stride, 4)
+				}
 			}
 		}
 	})
@@ -149,7 +442,7 @@ func resizeHorizontal(src *image.NRGBA, width int, filter ResampleFilter) *image
 	return dst
 }
 
-func resizeVertical(src *image.NRGBA, height int, filter ResampleFilter) *image.NRGBA {
+func resizeVertical(src *image.NRGBA, height int, filter ResampleFilter, clampRange bool) *image.NRGBA {
 	srcBounds := src.Bounds()
 	srcW := srcBounds.Max.X
 	srcH := srcBounds.Max.Y
@@ -165,13 +458,14 @@ func resizeVertical(src *image.NRGBA, height int, filter ResampleFilter) *image.
 
 		for dstX := partStart; dstX < partEnd; dstX++ {
 			for dstY := 0; dstY < dstH; dstY++ {
-				var c [4]int32
+				var c [4]int64
 				for _, iw := range weights[dstY].iwpairs {
 					i := iw.i*src.Stride + dstX*4
-					c[0] += int32(src.Pix[i+0]) * iw.w
-					c[1] += int32(src.Pix[i+1]) * iw.w
-					c[2] += int32(src.Pix[i+2]) * iw.w
-					c[3] += int32(src.Pix[i+3]) * iw.w
+					w := int64(iw.w)
+					c[0] += int64(src.Pix[i+0]) * w
+					c[1] += int64(src.Pix[i+1]) * w
+					c[2] += int64(src.Pix[i+2]) * w
+					c[3] += int64(src.Pix[i+3]) * w
 				}
 				j := dstY*dst.Stride + dstX*4
 				sum := weights[dstY].wsum
@@ -179,6 +473,9 @@ func resizeVertical(src *image.NRGBA, height int, filter ResampleFilter) *image.
 				dst.Pix[j+1] = clampint32(int32(float32(c[1])/float32(sum) + 0.5))
 				dst.Pix[j+2] = clampint32(int32(float32(c[2])/float32(sum) + 0.5))
 				dst.Pix[j+3] = clampint32(int32(float32(c[3])/float32(sum) + 0.5))
+				if clampRange {
+					clampToSources(dst.Pix[j:j+4], src.Pix, weights[dstY].iwpairs, dstX*4, src.Stride)
+				}
 			}
 		}
 
@@ -187,6 +484,34 @@ func resizeVertical(src *image.NRGBA, height int, filter ResampleFilter) *image.
 	return dst
 }
 
+// clampToSources clamps the pixel channels to the min/max values of the source pixels
+// with non-zero weights. The source pixel offset is base + i*step.
+func clampToSources(px, srcPix []uint8, iwpairs []iwpair, base, step int) {
+	for k := 0; k < 4; k++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, iw := range iwpairs {
+			if iw.w == 0 {
+				continue
+			}
+			v := srcPix[base+iw.i*step+k]
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if lo > hi {
+			continue
+		}
+		if px[k] < lo {
+			px[k] = lo
+		} else if px[k] > hi {
+			px[k] = hi
+		}
+	}
+}
+
 // fast nearest-neighbor resize, no filtering
 func resizeNearest(src *image.NRGBA, width, height int) *image.NRGBA {
 	dstW, dstH := width, height
@@ -197,20 +522,19 @@ func resizeNearest(src *image.NRGBA, width, height int) *image.NRGBA {
 
 	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
 
-	dx := float64(srcW) / float64(dstW)
-	dy := float64(srcH) / float64(dstH)
+	// the source pixel closest to the center of the destination pixel,
+	// computed exactly in integers: floor((dstX + 0.5) * srcW / dstW)
+	srcXs := make([]int, dstW)
+	for dstX := range srcXs {
+		srcXs[dstX] = (2*dstX + 1) * srcW / (2 * dstW)
+	}
 
 	parallel(dstH, func(partStart, partEnd int) {
 
 		for dstY := partStart; dstY < partEnd; dstY++ {
-			fy := (float64(dstY)+0.5)*dy - 0.5
-
-			for dstX := 0; dstX < dstW; dstX++ {
-				fx := (float64(dstX)+0.5)*dx - 0.5
-
-				srcX := int(math.Min(math.Max(math.Floor(fx+0.5), 0.0), float64(srcW)))
-				srcY := int(math.Min(math.Max(math.Floor(fy+0.5), 0.0), float64(srcH)))
+			srcY := (2*dstY + 1) * srcH / (2 * dstH)
 
+			for dstX, srcX := range srcXs {
 				srcOff := srcY*src.Stride + srcX*4
 				dstOff := dstY*dst.Stride + dstX*4
 
@@ -267,6 +591,88 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 	return Resize(img, newW, newH, filter)
 }
 
+// ResizeToFit scales down the image using the specified resample filter to fit within
+// the maxW x maxH bounding box preserving the aspect ratio and returns the transformed image.
+// The image is never scaled up: if it already fits, an unchanged copy is returned.
+//
+// The side that limits the scale factor is set exactly to the maximum size, the other side is
+// the source size multiplied by the scale factor, rounded to the nearest integer (halves are
+// rounded up), and at least 1 pixel. For example, a 1000x333 image fitted into 100x100 becomes 100x33.
+//
+// Usage example:
+//
+//		dstImage := imaging.ResizeToFit(srcImage, 800, 600, imaging.Lanczos)
+//
+func ResizeToFit(img image.Image, maxW, maxH int, filter ResampleFilter) *image.NRGBA {
+	if maxW <= 0 || maxH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	srcBounds := img.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
+
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	if srcW <= maxW && srcH <= maxH {
+		return Clone(img)
+	}
+
+	newW, newH := FitDimensions(srcW, srcH, maxW, maxH)
+	return Resize(img, newW, newH, filter)
+}
+
+// AspectRatio returns the aspect ratio (width / height) of the image.
+// Zero is returned for the empty image.
+func AspectRatio(img image.Image) float64 {
+	size := img.Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return 0
+	}
+	return float64(size.X) / float64(size.Y)
+}
+
+// FitDimensions returns the size of the largest rectangle with the aspect ratio of srcW x srcH
+// that fits within the boxW x boxH box. The side that limits the scale factor is set exactly
+// to the box size, the other side is rounded to the nearest integer and is at least 1.
+// The size is scaled up or down, it's the size of the image scaled by Contain (and by ResizeToFit
+// if the image doesn't fit into the box). If any of the dimensions is not positive, (0, 0) is returned.
+//
+// Usage example:
+//
+//		w, h := imaging.FitDimensions(4000, 3000, 800, 800) // 800, 600
+//
+func FitDimensions(srcW, srcH, boxW, boxH int) (int, int) {
+	if srcW <= 0 || srcH <= 0 || boxW <= 0 || boxH <= 0 {
+		return 0, 0
+	}
+	if srcW*boxH > srcH*boxW {
+		return boxW, int(math.Max(1.0, math.Floor(float64(srcH)*float64(boxW)/float64(srcW)+0.5)))
+	}
+	return int(math.Max(1.0, math.Floor(float64(srcW)*float64(boxH)/float64(srcH)+0.5))), boxH
+}
+
+// FillDimensions returns the size of the smallest rectangle with the aspect ratio of srcW x srcH
+// that covers the boxW x boxH box. The side that limits the scale factor is set exactly to the box
+// size, the other side is rounded to the nearest integer. It's the size the image is scaled to by
+// Thumbnail before cropping. If any of the dimensions is not positive, (0, 0) is returned.
+//
+// Usage example:
+//
+//		w, h := imaging.FillDimensions(4000, 3000, 800, 800) // 1067, 800
+//
+func FillDimensions(srcW, srcH, boxW, boxH int) (int, int) {
+	if srcW <= 0 || srcH <= 0 || boxW <= 0 || boxH <= 0 {
+		return 0, 0
+	}
+	if srcW*boxH > srcH*boxW {
+		return int(math.Floor(float64(boxH)*float64(srcW)/float64(srcH) + 0.5)), boxH
+	}
+	return boxW, int(math.Floor(float64(boxW)*float64(srcH)/float64(srcW) + 0.5))
+}
+
 // Thumbnail scales the image up or down using the specified resample filter, crops it
 // to the specified width and hight and returns the transformed image.
 //
@@ -292,21 +698,149 @@ func Thumbnail(img image.Image, width, height int, filter ResampleFilter) *image
 		return &image.NRGBA{}
 	}
 
+	tmpW, tmpH := FillDimensions(srcW, srcH, thumbW, thumbH)
+	tmp := Resize(img, tmpW, tmpH, filter)
+
+	return CropCenter(tmp, thumbW, thumbH)
+}
+
+// QualityThumbnail scales and crops the image like Thumbnail does, but when the image is
+// downscaled by a large factor, it's first reduced to about twice the thumbnail size by averaging
+// the blocks of source pixels, then the specified resample filter is applied to the intermediate
+// image. Every source pixel contributes to the result, so the fine details don't alias, and this
+// step-down approach is much faster than the filtered resize of the full size image.
+//
+// Usage example:
+//
+//		dstImage := imaging.QualityThumbnail(srcImage, 150, 150, imaging.Lanczos)
+//
+func QualityThumbnail(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	srcBounds := img.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
+
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	tmpW, tmpH := FillDimensions(srcW, srcH, width, height)
+	factor := srcW / (2 * tmpW)
+	if f := srcH / (2 * tmpH); f < factor {
+		factor = f
+	}
+	if factor < 2 {
+		return Thumbnail(img, width, height, filter)
+	}
+
+	// the remaining pixels that don't fill a whole block are cropped evenly from both sides
+	area := CropCenter(img, srcW-srcW%factor, srcH-srcH%factor)
+	tmp := resizeArea(area, factor, factor)
+	tmp = Resize(tmp, tmpW, tmpH, filter)
+
+	return CropCenter(tmp, width, height)
+}
+
+// Contain scales the image up or down using the specified resample filter to fit entirely
+// within the specified width and height preserving the aspect ratio, then pads it to exactly
+// width x height centering it on the bg color and returns the transformed image.
+// Unlike Thumbnail, the image is not cropped.
+//
+// Usage example:
+//
+//		dstImage := imaging.Contain(srcImage, 200, 200, color.White, imaging.Lanczos)
+//
+func Contain(img image.Image, width, height int, bg color.Color, filter ResampleFilter) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	srcBounds := img.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
+
+	if srcW <= 0 || srcH <= 0 {
+		return New(width, height, bg)
+	}
+
+	newW, newH := FitDimensions(srcW, srcH, width, height)
+	var tmp image.Image = img
+	if newW != srcW || newH != srcH {
+		tmp = Resize(img, newW, newH, filter)
+	}
+
+	return Pad(tmp, width, height, bg, Center)
+}
+
+// SmartThumbnail scales the image up or down using the specified resample filter, crops it
+// to the specified width and height and returns the transformed image. Unlike Thumbnail,
+// the crop region is chosen using the same edge energy heuristic as SmartCrop, which keeps
+// the off-center subjects in the thumbnail. If the image is smaller than the thumbnail size,
+// Thumbnail is used instead.
+//
+// Usage example:
+//
+//		dstImage := imaging.SmartThumbnail(srcImage, 100, 100, imaging.Lanczos)
+//
+func SmartThumbnail(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	thumbW, thumbH := width, height
+
+	if thumbW <= 0 || thumbH <= 0 {
+		return &image.NRGBA{}
+	}
+
+	srcBounds := img.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
+
+	if srcW < thumbW || srcH < thumbH {
+		return Thumbnail(img, thumbW, thumbH, filter)
+	}
+
 	srcAspectRatio := float64(srcW) / float64(srcH)
 	thumbAspectRatio := float64(thumbW) / float64(thumbH)
 
-	var tmp image.Image
+	// the largest region with the thumbnail aspect ratio
+	cropW, cropH := srcW, srcH
 	if srcAspectRatio > thumbAspectRatio {
-		tmp = Resize(img, 0, thumbH, filter)
+		cropW = int(math.Max(1.0, math.Min(float64(srcW), math.Floor(float64(srcH)*thumbAspectRatio+0.5))))
 	} else {
-		tmp = Resize(img, thumbW, 0, filter)
+		cropH = int(math.Max(1.0, math.Min(float64(srcH), math.Floor(float64(srcW)/thumbAspectRatio+0.5))))
 	}
 
-	return CropCenter(tmp, thumbW, thumbH)
+	src := toNRGBA(img)
+	return Resize(Crop(src, smartCropRect(src, cropW, cropH)), thumbW, thumbH, filter)
 }
 
 // Resample filter struct. It can be used to make custom filters.
 //
+// Kernel is the filter function, it's called with the distance between the source pixel and
+// the sampling point measured in source pixels (scaled when downscaling), and it must be zero
+// outside of the [-Support, Support] interval. The weights are normalized, so the kernel doesn't
+// need to integrate to one, the kernel is rescaled by the power of two close to its integral
+// before the weights are quantized. If Support is zero or negative, the Kernel is ignored and
+// the nearest-neighbor resampling is used. All the built-in filters are the values of this type.
+//
+// Custom filter example:
+//
+//		// triangle filter twice as wide as Linear
+//		tent := imaging.ResampleFilter{
+//			Support: 2.0,
+//			Kernel: func(x float64) float64 {
+//				x = math.Abs(x)
+//				if x < 2.0 {
+//					return 2.0 - x
+//				}
+//				return 0
+//			},
+//		}
+//		dstImage := imaging.Resize(srcImage, 800, 600, tent)
+//
+// The windowed sinc filters with the custom number of lobes can also be made with LanczosFilter.
+//
 // Supported resample filters: NearestNeighbor, Box, Linear, Hermite, MitchellNetravali,
 // CatmullRom, BSpline, Gaussian, Lanczos, Hann, Hamming, Blackman, Bartlett, Welch, Cosine.
 //
@@ -333,7 +867,9 @@ func Thumbnail(img image.Image, width, height int, filter ResampleFilter) *image
 //		When upscaling it's similar to NearestNeighbor.
 //
 //	- NearestNeighbor
-//		Fastest resample filter, no antialiasing at all. Rarely used.
+//		Fastest resample filter, no antialiasing at all. Each destination pixel is a copy of
+//		the closest source pixel, so upscaling by an integer factor replicates every source pixel
+//		into a block of pixels, which keeps the pixel art crisp.
 //
 type ResampleFilter struct {
 	Support float64
@@ -385,6 +921,111 @@ var Welch ResampleFilter
 // Cosine-windowed sinc filter (3 lobes).
 var Cosine ResampleFilter
 
+// FilterByName returns the resample filter with the specified name. The name is case-insensitive,
+// the dashes, underscores and spaces are ignored, e.g. "Lanczos", "catmull-rom" and "CATMULL_ROM"
+// are all valid. The supported names are the names of the filter variables ("nearestneighbor",
+// "box", "linear", "hermite", "mitchellnetravali", "catmullrom", "bspline", "gaussian", "bartlett",
+// "lanczos", "hann", "hamming", "blackman", "welch", "cosine") and the aliases "nearest", "bilinear",
+// "mitchell", "cubic" and "bicubic" (CatmullRom). An error is returned for unknown names.
+//
+// Usage example:
+//
+//		filter, err := imaging.FilterByName(r.FormValue("filter"))
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//			return
+//		}
+//		dstImage := imaging.Resize(srcImage, 800, 0, filter)
+//
+func FilterByName(name string) (ResampleFilter, error) {
+	key := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+
+	switch key {
+	case "nearestneighbor", "nearest":
+		return NearestNeighbor, nil
+	case "box":
+		return Box, nil
+	case "linear", "bilinear":
+		return Linear, nil
+	case "hermite":
+		return Hermite, nil
+	case "mitchellnetravali", "mitchell":
+		return MitchellNetravali, nil
+	case "catmullrom", "cubic", "bicubic":
+		return CatmullRom, nil
+	case "bspline":
+		return BSpline, nil
+	case "gaussian":
+		return Gaussian, nil
+	case "bartlett":
+		return Bartlett, nil
+	case "lanczos":
+		return Lanczos, nil
+	case "hann":
+		return Hann, nil
+	case "hamming":
+		return Hamming, nil
+	case "blackman":
+		return Blackman, nil
+	case "welch":
+		return Welch, nil
+	case "cosine":
+		return Cosine, nil
+	}
+	return ResampleFilter{}, fmt.Errorf("imaging: unknown resample filter %q", name)
+}
+
+// CubicFilter returns a BC-spline cubic filter with the given B and C parameters.
+// MitchellNetravali is CubicFilter(1.0/3.0, 1.0/3.0), CatmullRom is CubicFilter(0, 0.5)
+// and BSpline is CubicFilter(1, 0).
+//
+// Usage example:
+//
+//		dstImage := imaging.Resize(srcImage, 800, 600, imaging.CubicFilter(0.0, 0.75))
+//
+func CubicFilter(b, c float64) ResampleFilter {
+	return ResampleFilter{
+		Support: 2.0,
+		Kernel: func(x float64) float64 {
+			x = math.Abs(x)
+			if x < 2.0 {
+				return bcspline(x, b, c)
+			}
+			return 0
+		},
+	}
+}
+
+// LanczosFilter returns a Lanczos filter with the given number of lobes. The larger numbers
+// give the sharper results with more ringing. Lanczos is LanczosFilter(3). If lobes is less than 1,
+// the 1-lobe filter is returned.
+//
+// Usage example:
+//
+//		dstImage := imaging.Resize(srcImage, 800, 600, imaging.LanczosFilter(5))
+//
+func LanczosFilter(lobes int) ResampleFilter {
+	if lobes < 1 {
+		lobes = 1
+	}
+	n := float64(lobes)
+	return ResampleFilter{
+		Support: n,
+		Kernel: func(x float64) float64 {
+			x = math.Abs(x)
+			if x < n {
+				return sinc(x) * sinc(x/n)
+			}
+			return 0
+		},
+	}
+}
+
 func bcspline(x, b, c float64) float64 {
 	x = math.Abs(x)
 	if x < 1.0 {
@@ -1,7 +1,11 @@
 package imaging
 
 import (
+	"fmt"
+	"hash/crc32"
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -125,6 +129,545 @@ func TestResize(t *testing.T) {
 	}
 }
 
+func TestResizeNearest(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+			0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c,
+		},
+	}
+	srcCopy := Clone(src)
+	for _, f := range []int{1, 2, 3, 7} {
+		got := Resize(src, 3*f, 2*f, NearestNeighbor)
+		if got.Bounds() != image.Rect(0, 0, 3*f, 2*f) {
+			t.Fatalf("test [ResizeNearest %dx] failed: bounds %v", f, got.Bounds())
+		}
+		for y := 0; y < 2*f; y++ {
+			for x := 0; x < 3*f; x++ {
+				if c, want := got.NRGBAAt(x, y), srcCopy.NRGBAAt(x/f, y/f); c != want {
+					t.Fatalf("test [ResizeNearest %dx] failed: pixel (%d, %d) is %v, want %v", f, x, y, c, want)
+				}
+			}
+		}
+	}
+
+	// downscaling by an integer factor picks the pixel closest to the block center
+	src = image.NewNRGBA(image.Rect(0, 0, 9, 6))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i / 4)
+	}
+	got := Resize(src, 3, 2, NearestNeighbor)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			if c, want := got.NRGBAAt(x, y), src.NRGBAAt(x*3+1, y*3+1); c != want {
+				t.Errorf("test [ResizeNearest 1/3x] failed: pixel (%d, %d) is %v, want %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+func TestScaleInt(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	td := []struct {
+		desc   string
+		factor int
+		want   *image.NRGBA
+	}{
+		{
+			"ScaleInt 2x1 1",
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			},
+		},
+		{
+			"ScaleInt 2x1 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 2),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0x01, 0x02, 0x03, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x05, 0x06, 0x07, 0x08,
+					0x01, 0x02, 0x03, 0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x05, 0x06, 0x07, 0x08,
+				},
+			},
+		},
+		{
+			"ScaleInt 2x1 0",
+			0,
+			&image.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		got := ScaleInt(src, d.factor)
+		if !compareNRGBA(got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	img := compareTestImage()
+	for _, f := range []int{3, 5} {
+		w, h := img.Bounds().Dx()*f, img.Bounds().Dy()*f
+		if got := ScaleInt(img, f); !compareNRGBA(got, Resize(img, w, h, NearestNeighbor), 0) {
+			t.Errorf("test [ScaleInt %d nearest] failed", f)
+		}
+	}
+}
+
+func TestResizeLinear(t *testing.T) {
+	checkerboard := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		src  image.Image
+		w, h int
+		f    ResampleFilter
+		want *image.NRGBA
+	}{
+		{
+			"ResizeLinear checkerboard 2x2 1x1 box",
+			checkerboard,
+			1, 1,
+			Box,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0xbc, 0xbc, 0xbc, 0xff},
+			},
+		},
+		{
+			"ResizeLinear checkerboard 2x2 0x1 linear",
+			checkerboard,
+			0, 1,
+			Linear,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0xbc, 0xbc, 0xbc, 0xff},
+			},
+		},
+		{
+			"ResizeLinear 2x1 4x1 nearest",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80},
+			},
+			4, 1,
+			NearestNeighbor,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 1),
+				Stride: 4 * 4,
+				Pix:    []uint8{0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80, 0xff, 0xff, 0xff, 0x80},
+			},
+		},
+		{
+			"ResizeLinear 2x2 2x2 box",
+			checkerboard,
+			2, 2,
+			Box,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := ResizeLinear(d.src, d.w, d.h, d.f)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	// sRGB resize gives the average of the sRGB values
+	if got := Resize(checkerboard, 1, 1, Box); got.Pix[0] != 0x80 {
+		t.Errorf("test [Resize checkerboard 2x2 1x1 box] failed: %#v", got)
+	}
+}
+
+func TestResizeClamped(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			i := y*src.Stride + x*4
+			v := uint8(0x40)
+			if x >= 4 {
+				v = 0xc0
+			}
+			src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = v, v, v, 0xff
+		}
+	}
+
+	inRange := func(img *image.NRGBA) bool {
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] < 0x40 || img.Pix[i] > 0xc0 || img.Pix[i+3] != 0xff {
+				return false
+			}
+		}
+		return true
+	}
+
+	if inRange(Resize(src, 20, 20, Lanczos)) {
+		t.Errorf("test [Resize 8x8 20x20 lanczos] failed: expected overshoot")
+	}
+	for _, f := range []ResampleFilter{Lanczos, CatmullRom, Box, Linear} {
+		got := ResizeClamped(src, 20, 20, f)
+		if got.Bounds() != image.Rect(0, 0, 20, 20) || !inRange(got) {
+			t.Errorf("test [ResizeClamped 8x8 20x20] failed: %#v", got)
+		}
+		got = ResizeClamped(src, 3, 0, f)
+		if got.Bounds() != image.Rect(0, 0, 3, 3) || !inRange(got) {
+			t.Errorf("test [ResizeClamped 8x8 3x3] failed: %#v", got)
+		}
+	}
+
+	// no negative lobes, no changes
+	for _, f := range []ResampleFilter{Box, Linear, BSpline} {
+		got := ResizeClamped(src, 5, 13, f)
+		want := Resize(src, 5, 13, f)
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [ResizeClamped 8x8 5x13] failed: %#v", got)
+		}
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x10, 0x20, 0xff, 0x01, 0x11, 0x21, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00,
+			0x02, 0x12, 0x22, 0xff, 0x04, 0x14, 0x24, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff,
+		},
+	}
+	td := []struct {
+		desc string
+		w, h int
+		f    ResampleFilter
+		want *image.NRGBA
+	}{
+		{
+			"Downscale 4x2 2x1 area",
+			2, 1,
+			Lanczos,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x02, 0x12, 0x22, 0xff, 0x80, 0x00, 0x80, 0x80},
+			},
+		},
+		{
+			"Downscale 4x2 1x1 area",
+			1, 1,
+			Lanczos,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x41, 0x09, 0x51, 0xbf},
+			},
+		},
+		{
+			"Downscale 4x2 3x1 fallback",
+			3, 1,
+			Box,
+			Resize(src, 3, 1, Box),
+		},
+		{
+			"Downscale 4x2 4x2 fallback",
+			4, 2,
+			Box,
+			Resize(src, 4, 2, Box),
+		},
+	}
+	for _, d := range td {
+		got := Downscale(src, d.w, d.h, d.f)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	big := image.NewNRGBA(image.Rect(0, 0, 41, 31))
+	for i := range big.Pix {
+		big.Pix[i] = uint8((i * 37) % 256)
+	}
+	td2 := []struct {
+		desc string
+		w, h int
+		want *image.NRGBA
+	}{
+		{"Downscale 41x31 4x3 cropped area", 4, 3, Downscale(Crop(big, image.Rect(0, 0, 40, 30)), 4, 3, Lanczos)},
+		{"Downscale 41x31 8x6 cropped area", 8, 6, Downscale(Crop(big, image.Rect(0, 0, 40, 30)), 8, 6, Lanczos)},
+		{"Downscale 41x31 20x10 cropped area", 20, 10, Downscale(Crop(big, image.Rect(0, 0, 40, 30)), 20, 10, Lanczos)},
+		{"Downscale 41x31 7x5 fallback", 7, 5, Resize(big, 7, 5, Lanczos)},
+		{"Downscale 41x31 13x10 fallback", 13, 10, Resize(big, 13, 10, Lanczos)},
+	}
+	for _, d := range td2 {
+		got := Downscale(big, d.w, d.h, Lanczos)
+		if !compareNRGBA(got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func BenchmarkDownscale(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2048, 2048))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Downscale(src, 256, 256, Lanczos)
+	}
+}
+
+func BenchmarkResizeLanczosDownscale(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2048, 2048))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Resize(src, 256, 256, Lanczos)
+	}
+}
+
+func BenchmarkThumbnail(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3000, 2000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Thumbnail(src, 150, 150, Lanczos)
+	}
+}
+
+func BenchmarkQualityThumbnail(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3000, 2000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		QualityThumbnail(src, 150, 150, Lanczos)
+	}
+}
+
+func BenchmarkResizeProcs(b *testing.B) {
+	defer SetMaxProcs(0)
+	src := image.NewNRGBA(image.Rect(0, 0, 2000, 2000))
+	for _, procs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			SetMaxProcs(procs)
+			for i := 0; i < b.N; i++ {
+				Resize(src, 1000, 1000, Lanczos)
+			}
+		})
+	}
+}
+
+func TestFilterByName(t *testing.T) {
+	td := []struct {
+		name string
+		want ResampleFilter
+	}{
+		{"nearest", NearestNeighbor},
+		{"NearestNeighbor", NearestNeighbor},
+		{"box", Box},
+		{"bilinear", Linear},
+		{"Linear", Linear},
+		{"hermite", Hermite},
+		{"mitchell", MitchellNetravali},
+		{"Mitchell-Netravali", MitchellNetravali},
+		{"catmull_rom", CatmullRom},
+		{"cubic", CatmullRom},
+		{"B-Spline", BSpline},
+		{"gaussian", Gaussian},
+		{"bartlett", Bartlett},
+		{"LANCZOS", Lanczos},
+		{"hann", Hann},
+		{"hamming", Hamming},
+		{"blackman", Blackman},
+		{"welch", Welch},
+		{"cosine", Cosine},
+	}
+	for _, d := range td {
+		got, err := FilterByName(d.name)
+		if err != nil || got.Support != d.want.Support {
+			t.Errorf("test [FilterByName %s] failed: %v %v", d.name, got.Support, err)
+			continue
+		}
+		if d.want.Kernel == nil {
+			if got.Kernel != nil {
+				t.Errorf("test [FilterByName %s] failed: unexpected kernel", d.name)
+			}
+			continue
+		}
+		for _, x := range []float64{0, 0.3, 0.5, 1.2, 2.5} {
+			if got.Kernel(x) != d.want.Kernel(x) {
+				t.Errorf("test [FilterByName %s] failed: kernel(%v) = %v", d.name, x, got.Kernel(x))
+			}
+		}
+	}
+
+	for _, name := range []string{"", "lanczos3", "sinc"} {
+		if _, err := FilterByName(name); err == nil {
+			t.Errorf("expected FilterByName error for %q", name)
+		}
+	}
+}
+
+func TestCubicFilter(t *testing.T) {
+	td := []struct {
+		desc string
+		f    ResampleFilter
+		b, c float64
+	}{
+		{"CubicFilter MitchellNetravali", MitchellNetravali, 1.0 / 3.0, 1.0 / 3.0},
+		{"CubicFilter CatmullRom", CatmullRom, 0.0, 0.5},
+		{"CubicFilter BSpline", BSpline, 1.0, 0.0},
+	}
+	for _, d := range td {
+		got := CubicFilter(d.b, d.c)
+		if got.Support != d.f.Support {
+			t.Errorf("test [%s] failed: support %v != %v", d.desc, got.Support, d.f.Support)
+		}
+		for x := -2.5; x <= 2.5; x += 0.125 {
+			if got.Kernel(x) != d.f.Kernel(x) {
+				t.Errorf("test [%s] failed: kernel(%v) %v != %v", d.desc, x, got.Kernel(x), d.f.Kernel(x))
+			}
+		}
+	}
+}
+
+func TestLanczosFilter(t *testing.T) {
+	got := LanczosFilter(3)
+	if got.Support != Lanczos.Support {
+		t.Errorf("test [LanczosFilter 3] failed: support %v != %v", got.Support, Lanczos.Support)
+	}
+	for x := -3.5; x <= 3.5; x += 0.125 {
+		if got.Kernel(x) != Lanczos.Kernel(x) {
+			t.Errorf("test [LanczosFilter 3] failed: kernel(%v) %v != %v", x, got.Kernel(x), Lanczos.Kernel(x))
+		}
+	}
+	if f := LanczosFilter(0); f.Support != 1 || f.Kernel(0) != 1 || f.Kernel(1) != 0 {
+		t.Errorf("test [LanczosFilter 0] failed: support %v", f.Support)
+	}
+}
+
+// TestResizeFilterOutput pins the output of the built-in filters, the checksums must only change
+// if the resampling is changed on purpose.
+func TestResizeFilterOutput(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 97, 61))
+	for i := range src.Pix {
+		src.Pix[i] = uint8((i*37 + i/7*11) % 256)
+	}
+	td := []struct {
+		desc string
+		f    ResampleFilter
+		want [4]uint32
+	}{
+		{"Box", Box, [4]uint32{0x64a00d29, 0x006e7c80, 0xe9859bd5, 0x26107e0b}},
+		{"Linear", Linear, [4]uint32{0x8d9cb801, 0xb0e63bfb, 0x6341e75d, 0xa4f569eb}},
+		{"Hermite", Hermite, [4]uint32{0x3122a7e0, 0x983c3799, 0x6f76792c, 0xac057486}},
+		{"MitchellNetravali", MitchellNetravali, [4]uint32{0x3e4a2051, 0x34b93645, 0x9a9345aa, 0xe39aa755}},
+		{"CatmullRom", CatmullRom, [4]uint32{0x6357a8a2, 0x026acde4, 0x8733ad1d, 0x80ac793a}},
+		{"BSpline", BSpline, [4]uint32{0x6b7900dc, 0xa3be3211, 0xeb799ffa, 0x77d1b6bf}},
+		{"Gaussian", Gaussian, [4]uint32{0xc36aa8e3, 0xeb633699, 0xd8432f72, 0xc4259912}},
+		{"Bartlett", Bartlett, [4]uint32{0xa5e2ed45, 0xd47c3c7c, 0xc3cc0bca, 0xc5299a3b}},
+		{"Lanczos", Lanczos, [4]uint32{0x6a31f131, 0x7ec059d9, 0x5c1653a3, 0x9529fac5}},
+		{"Hann", Hann, [4]uint32{0x14f4363f, 0x99656f04, 0x9d38c3c9, 0x2d141dc2}},
+		{"Hamming", Hamming, [4]uint32{0x6e524b4f, 0xd1bee6fa, 0xce6adc10, 0x6ca74b6f}},
+		{"Blackman", Blackman, [4]uint32{0x3fcbe742, 0x73a597c2, 0xa2ce7dde, 0x7c13657e}},
+		{"Welch", Welch, [4]uint32{0xba07c87b, 0x735a0efd, 0x84469c3d, 0x34445849}},
+		{"Cosine", Cosine, [4]uint32{0x3326b06d, 0x53f88b8c, 0x2106b2e0, 0x920b98fa}},
+		{"CubicFilter(0, 0.75)", CubicFilter(0, 0.75), [4]uint32{0x734ef85c, 0xb788ca1a, 0x60351723, 0x6e823974}},
+		{"LanczosFilter(5)", LanczosFilter(5), [4]uint32{0xab1e1a65, 0x0457067b, 0xeecf4366, 0x72657743}},
+	}
+	for _, d := range td {
+		got := [4]uint32{
+			crc32.ChecksumIEEE(Resize(src, 31, 200, d.f).Pix),
+			crc32.ChecksumIEEE(Resize(src, 300, 7, d.f).Pix),
+			crc32.ChecksumIEEE(ResizeClamped(src, 40, 25, d.f).Pix),
+			crc32.ChecksumIEEE(ResizeLinear(src, 40, 25, d.f).Pix),
+		}
+		if got != d.want {
+			t.Errorf("test [Resize %s] failed: %#x", d.desc, got)
+		}
+	}
+}
+
+func TestResizeCustomFilter(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for i := range src.Pix {
+		src.Pix[i] = uint8((i * 37) % 256)
+	}
+	td := []struct {
+		desc string
+		f    ResampleFilter
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"Resize custom linear",
+			ResampleFilter{Support: 1.0, Kernel: Linear.Kernel},
+			11, 3,
+			Resize(src, 11, 3, Linear),
+		},
+		{
+			"Resize custom scaled box",
+			ResampleFilter{
+				Support: 0.5,
+				Kernel: func(x float64) float64 {
+					if math.Abs(x) <= 0.5 {
+						return 3.0
+					}
+					return 0
+				},
+			},
+			4, 9,
+			Resize(src, 4, 9, Box),
+		},
+		{
+			"Resize custom zero kernel",
+			ResampleFilter{Support: 1.0, Kernel: func(float64) float64 { return 0 }},
+			3, 12,
+			Resize(src, 3, 12, NearestNeighbor),
+		},
+	}
+	for _, d := range td {
+		got := Resize(src, d.w, d.h, d.f)
+		if !compareNRGBA(got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	// the weights are normalized, the magnitude of the kernel doesn't matter
+	checker := image.NewNRGBA(image.Rect(0, 0, 400, 1))
+	for x := 0; x < 400; x += 2 {
+		checker.SetNRGBA(x, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		checker.SetNRGBA(x+1, 0, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	}
+	want := Resize(checker, 10, 1, Linear)
+	for _, k := range []float64{1024, 1.0 / 1024, 1000, 0.001, 1e-9} {
+		f := ResampleFilter{
+			Support: Linear.Support,
+			Kernel:  func(x float64) float64 { return k * Linear.Kernel(x) },
+		}
+		if got := Resize(checker, 10, 1, f); !compareNRGBA(got, want, 0) {
+			t.Errorf("test [Resize scaled kernel %v] failed: %#v", k, got)
+		}
+	}
+}
+
 func TestFit(t *testing.T) {
 	td := []struct {
 		desc string
@@ -200,6 +743,80 @@ func TestFit(t *testing.T) {
 	}
 }
 
+func TestResizeToFit(t *testing.T) {
+	td := []struct {
+		desc       string
+		srcW, srcH int
+		maxW, maxH int
+		wantW      int
+		wantH      int
+	}{
+		{"ResizeToFit 1000x333 100x100", 1000, 333, 100, 100, 100, 33},
+		{"ResizeToFit 1000x335 100x100", 1000, 335, 100, 100, 100, 34},
+		{"ResizeToFit 333x1000 100x100", 333, 1000, 100, 100, 33, 100},
+		{"ResizeToFit 300x200 150x150", 300, 200, 150, 150, 150, 100},
+		{"ResizeToFit 300x200 600x50", 300, 200, 600, 50, 75, 50},
+		{"ResizeToFit 1000x1 10x10", 1000, 1, 10, 10, 10, 1},
+		{"ResizeToFit 30x20 100x100 no upscale", 30, 20, 100, 100, 30, 20},
+		{"ResizeToFit 30x20 30x20 no upscale", 30, 20, 30, 20, 30, 20},
+		{"ResizeToFit 30x20 0x20", 30, 20, 0, 20, 0, 0},
+	}
+	for _, d := range td {
+		src := image.NewNRGBA(image.Rect(0, 0, d.srcW, d.srcH))
+		got := ResizeToFit(src, d.maxW, d.maxH, Lanczos)
+		if got.Bounds().Dx() != d.wantW || got.Bounds().Dy() != d.wantH {
+			t.Errorf("test [%s] failed: got size %v", d.desc, got.Bounds().Size())
+		}
+	}
+
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	got := ResizeToFit(src, 10, 10, Lanczos)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [ResizeToFit unchanged] failed: %#v", got)
+	}
+}
+
+func TestFitFillDimensions(t *testing.T) {
+	td := []struct {
+		srcW, srcH, boxW, boxH int
+		fitW, fitH             int
+		fillW, fillH           int
+	}{
+		{4000, 3000, 800, 800, 800, 600, 1067, 800},
+		{3000, 4000, 800, 800, 600, 800, 800, 1067},
+		{100, 50, 400, 300, 400, 200, 600, 300},
+		{100, 100, 30, 30, 30, 30, 30, 30},
+		{1000, 1, 10, 10, 10, 1, 10000, 10},
+		{1, 1000, 100, 1, 1, 1, 100, 100000},
+		{0, 10, 10, 10, 0, 0, 0, 0},
+		{10, 10, 10, -1, 0, 0, 0, 0},
+	}
+	for _, d := range td {
+		if w, h := FitDimensions(d.srcW, d.srcH, d.boxW, d.boxH); w != d.fitW || h != d.fitH {
+			t.Errorf("test [FitDimensions %dx%d %dx%d] failed: %dx%d", d.srcW, d.srcH, d.boxW, d.boxH, w, h)
+		}
+		if w, h := FillDimensions(d.srcW, d.srcH, d.boxW, d.boxH); w != d.fillW || h != d.fillH {
+			t.Errorf("test [FillDimensions %dx%d %dx%d] failed: %dx%d", d.srcW, d.srcH, d.boxW, d.boxH, w, h)
+		}
+	}
+
+	if r := AspectRatio(image.NewNRGBA(image.Rect(-2, -1, 2, 1))); r != 2 {
+		t.Errorf("test [AspectRatio 4x2] failed: %v", r)
+	}
+	if r := AspectRatio(&image.NRGBA{}); r != 0 {
+		t.Errorf("test [AspectRatio 0x0] failed: %v", r)
+	}
+}
+
 func TestThumbnail(t *testing.T) {
 	td := []struct {
 		desc string
@@ -279,3 +896,184 @@ func TestThumbnail(t *testing.T) {
 		}
 	}
 }
+
+func TestQualityThumbnail(t *testing.T) {
+	checker := image.NewNRGBA(image.Rect(0, 0, 1200, 800))
+	for y := 0; y < 800; y++ {
+		for x := 0; x < 1200; x++ {
+			if (x+y)%2 == 0 {
+				checker.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+			} else {
+				checker.SetNRGBA(x, y, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+			}
+		}
+	}
+	small := image.NewNRGBA(image.Rect(0, 0, 30, 20))
+	for i := range small.Pix {
+		small.Pix[i] = uint8((i * 37) % 256)
+	}
+
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"QualityThumbnail checkerboard",
+			QualityThumbnail(checker, 100, 100, Lanczos),
+			New(100, 100, color.NRGBA{0x80, 0x80, 0x80, 0xff}),
+		},
+		{
+			"QualityThumbnail step-down",
+			QualityThumbnail(checker.SubImage(image.Rect(3, 1, 1200, 800)), 50, 50, CatmullRom),
+			CropCenter(Resize(Downscale(Crop(checker, image.Rect(3, 1, 1200, 799)), 171, 114, Box), 75, 50, CatmullRom), 50, 50),
+		},
+		{
+			"QualityThumbnail small factor",
+			QualityThumbnail(small, 10, 10, Linear),
+			Thumbnail(small, 10, 10, Linear),
+		},
+		{
+			"QualityThumbnail empty",
+			QualityThumbnail(small, 0, 10, Linear),
+			&image.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+
+	// the quality matches the single-step filtered resize
+	zone := image.NewNRGBA(image.Rect(0, 0, 1203, 901))
+	for y := 0; y < 901; y++ {
+		for x := 0; x < 1203; x++ {
+			v := uint8(127.5 + 127.5*math.Cos(float64((x-600)*(x-600)+(y-450)*(y-450))/1203))
+			zone.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	if ssim := SSIM(QualityThumbnail(zone, 120, 90, Lanczos), Thumbnail(zone, 120, 90, Lanczos)); ssim < 0.9 {
+		t.Errorf("test [QualityThumbnail SSIM] failed: %v", ssim)
+	}
+}
+
+func TestContain(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff},
+	}
+	bg := color.NRGBA{0x00, 0xff, 0x00, 0xff}
+	g := []uint8{0x00, 0xff, 0x00, 0xff}
+	r := []uint8{0xff, 0x00, 0x00, 0xff}
+	b := []uint8{0x00, 0x00, 0xff, 0xff}
+	pix := func(px ...[]uint8) []uint8 {
+		var p []uint8
+		for _, v := range px {
+			p = append(p, v...)
+		}
+		return p
+	}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"Contain 2x1 4x4 up",
+			Contain(src, 4, 4, bg, NearestNeighbor),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 4),
+				Stride: 4 * 4,
+				Pix: pix(
+					g, g, g, g,
+					r, r, b, b,
+					r, r, b, b,
+					g, g, g, g,
+				),
+			},
+		},
+		{
+			"Contain 2x1 1x3 down",
+			Contain(src, 1, 3, bg, NearestNeighbor),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 3),
+				Stride: 1 * 4,
+				Pix:    pix(g, b, g),
+			},
+		},
+		{
+			"Contain 2x1 3x1 same",
+			Contain(src, 3, 1, bg, NearestNeighbor),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    pix(r, b, g),
+			},
+		},
+		{
+			"Contain 0x0",
+			Contain(&image.NRGBA{}, 2, 1, bg, NearestNeighbor),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    pix(g, g),
+			},
+		},
+		{
+			"Contain 2x1 0x1",
+			Contain(src, 0, 1, bg, NearestNeighbor),
+			&image.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestSmartThumbnail(t *testing.T) {
+	// uniform image with the detailed region on the right side
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			i := y*src.Stride + x*4
+			v := uint8(0x80)
+			if x >= 30 && x < 38 && y >= 6 && y < 14 && (x+y)%2 == 0 {
+				v = 0xff
+			}
+			src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = v, v, v, 0xff
+		}
+	}
+
+	td := []struct {
+		desc string
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"SmartThumbnail 40x20 10x10",
+			10, 10,
+			Resize(Crop(src, image.Rect(19, 0, 39, 20)), 10, 10, Box),
+		},
+		{
+			"SmartThumbnail 40x20 20x5",
+			20, 5,
+			Resize(Crop(src, image.Rect(0, 5, 40, 15)), 20, 5, Box),
+		},
+		{
+			"SmartThumbnail 40x20 50x10 fallback",
+			50, 10,
+			Thumbnail(src, 50, 10, Box),
+		},
+	}
+	for _, d := range td {
+		got := SmartThumbnail(src, d.w, d.h, Box)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+var errInvalidPNG = errors.New("imaging: invalid PNG data")
+
+// DecodeStream reads an image from r and calls onRow for each row of pixels from top to bottom.
+// The row contains the pixels in the NRGBA format (4 bytes per pixel), it's only valid until
+// onRow returns. Non-interlaced PNG images are decoded row by row without holding the whole
+// decoded image in memory, the images wider than 16777216 pixels are rejected. Images of other formats (and interlaced PNG images) are fully decoded
+// first. The image config is returned.
+//
+// Usage example:
+//
+//		// compute the average red value of a huge image
+//		sum := 0
+//		cfg, err := imaging.DecodeStream(r, func(y int, row []uint8) {
+//			for x := 0; x < len(row); x += 4 {
+//				sum += int(row[x])
+//			}
+//		})
+//		avg := sum / (cfg.Width * cfg.Height)
+//
+func DecodeStream(r io.Reader, onRow func(y int, row []uint8)) (image.Config, error) {
+	br := bufio.NewReader(r)
+	if sig, err := br.Peek(len(pngSignature)); err == nil && string(sig) == pngSignature {
+		// keep the header data in case the full decoding is needed
+		header := &bytes.Buffer{}
+		cfg, err := decodePNGStream(io.TeeReader(br, header), br, onRow)
+		if err != errPNGInterlaced {
+			return cfg, err
+		}
+		return decodeFullStream(io.MultiReader(header, br), onRow)
+	}
+	return decodeFullStream(br, onRow)
+}
+
+func decodeFullStream(r io.Reader, onRow func(y int, row []uint8)) (image.Config, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		i := y * src.Stride
+		onRow(y, src.Pix[i:i+width*4])
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: width, Height: height}, nil
+}
+
+var errPNGInterlaced = errors.New("imaging: interlaced PNG")
+
+// pngMaxChunkLength is the maximum chunk length allowed by the PNG specification.
+const pngMaxChunkLength = 0x7fffffff
+
+// pngMaxWidth is the maximum width of the PNG images decoded row by row.
+// It limits the size of the row buffers (up to 128 MiB for 64-bit pixels).
+const pngMaxWidth = 1 << 24
+
+type pngHeader struct {
+	width, height int
+	depth         int
+	colorType     int
+	palette       []color.NRGBA
+	trns          []byte
+}
+
+// decodePNGStream reads the PNG header chunks from hr (which records the consumed data)
+// and then decodes the image data from r row by row.
+func decodePNGStream(hr io.Reader, r io.Reader, onRow func(y int, row []uint8)) (image.Config, error) {
+	if _, err := io.CopyN(ioutil.Discard, hr, int64(len(pngSignature))); err != nil {
+		return image.Config{}, err
+	}
+
+	var hdr pngHeader
+	for {
+		var length uint32
+		var typ [4]byte
+		if err := binary.Read(hr, binary.BigEndian, &length); err != nil {
+			return image.Config{}, errInvalidPNG
+		}
+		if _, err := io.ReadFull(hr, typ[:]); err != nil {
+			return image.Config{}, errInvalidPNG
+		}
+
+		if length > pngMaxChunkLength {
+			return image.Config{}, errInvalidPNG
+		}
+
+		if string(typ[:]) == "IDAT" {
+			if hdr.width == 0 {
+				return image.Config{}, errInvalidPNG
+			}
+			cfg := image.Config{ColorModel: color.NRGBAModel, Width: hdr.width, Height: hdr.height}
+			idat := &pngIDATReader{r: r, remaining: length}
+			return cfg, decodePNGRows(&hdr, idat, onRow)
+		}
+
+		// only the header chunks that are needed for decoding are buffered,
+		// the other chunks are skipped without allocating memory for them
+		var maxLength uint32
+		switch string(typ[:]) {
+		case "IHDR":
+			maxLength = 13
+		case "PLTE":
+			maxLength = 256 * 3
+		case "tRNS":
+			maxLength = 256
+		case "IEND":
+			return image.Config{}, errInvalidPNG
+		default:
+			// the skipped chunks aren't recorded for the full decoding fallback
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)+4); err != nil { // data and CRC
+				return image.Config{}, errInvalidPNG
+			}
+			continue
+		}
+		if length > maxLength {
+			return image.Config{}, errInvalidPNG
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(hr, data); err != nil {
+			return image.Config{}, errInvalidPNG
+		}
+		if _, err := io.CopyN(ioutil.Discard, hr, 4); err != nil { // CRC
+			return image.Config{}, errInvalidPNG
+		}
+
+		switch string(typ[:]) {
+		case "IHDR":
+			if len(data) != 13 {
+				return image.Config{}, errInvalidPNG
+			}
+			width := binary.BigEndian.Uint32(data[0:4])
+			height := binary.BigEndian.Uint32(data[4:8])
+			// the row buffers are allocated from the width, so it's checked before anything else
+			if width == 0 || height == 0 || width > pngMaxWidth || height > pngMaxChunkLength {
+				return image.Config{}, errInvalidPNG
+			}
+			hdr.width = int(width)
+			hdr.height = int(height)
+			hdr.depth = int(data[8])
+			hdr.colorType = int(data[9])
+			switch hdr.depth {
+			case 1, 2, 4, 8, 16:
+			default:
+				return image.Config{}, errInvalidPNG
+			}
+			if data[12] != 0 {
+				return image.Config{}, errPNGInterlaced
+			}
+		case "PLTE":
+			for i := 0; i+2 < len(data); i += 3 {
+				hdr.palette = append(hdr.palette, color.NRGBA{data[i], data[i+1], data[i+2], 0xff})
+			}
+		case "tRNS":
+			hdr.trns = data
+		}
+	}
+}
+
+// pngIDATReader reads the data of the consecutive IDAT chunks.
+type pngIDATReader struct {
+	r         io.Reader
+	remaining uint32
+	done      bool
+}
+
+func (d *pngIDATReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		// skip the CRC of the current chunk and read the header of the next one
+		var hdr [12]byte
+		if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+			return 0, errInvalidPNG
+		}
+		if string(hdr[8:12]) != "IDAT" {
+			d.done = true
+			return 0, io.EOF
+		}
+		d.remaining = binary.BigEndian.Uint32(hdr[4:8])
+	}
+
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	d.remaining -= uint32(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func decodePNGRows(hdr *pngHeader, idat io.Reader, onRow func(y int, row []uint8)) error {
+	var channels int
+	switch hdr.colorType {
+	case 0, 3:
+		channels = 1
+	case 2:
+		channels = 3
+	case 4:
+		channels = 2
+	case 6:
+		channels = 4
+	default:
+		return errInvalidPNG
+	}
+
+	bitsPerPixel := channels * hdr.depth
+	rowSize := (hdr.width*bitsPerPixel + 7) / 8
+	bytesPerPixel := (bitsPerPixel + 7) / 8
+
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		return errInvalidPNG
+	}
+	defer zr.Close()
+
+	cur := make([]byte, rowSize+1)
+	prev := make([]byte, rowSize+1)
+	row := make([]uint8, hdr.width*4)
+
+	for y := 0; y < hdr.height; y++ {
+		if _, err := io.ReadFull(zr, cur); err != nil {
+			return errInvalidPNG
+		}
+		if err := pngUnfilter(cur[0], cur[1:], prev[1:], bytesPerPixel); err != nil {
+			return err
+		}
+		hdr.convertRow(cur[1:], row)
+		onRow(y, row)
+		cur, prev = prev, cur
+	}
+
+	return nil
+}
+
+// pngUnfilter reverses the PNG filter of the row in place using the previous (unfiltered) row.
+func pngUnfilter(filter byte, cur, prev []byte, bpp int) error {
+	switch filter {
+	case 0:
+	case 1:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3:
+		for i := range cur {
+			left := 0
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += uint8((left + int(prev[i])) / 2)
+		}
+	case 4:
+		for i := range cur {
+			var a, c int
+			if i >= bpp {
+				a, c = int(cur[i-bpp]), int(prev[i-bpp])
+			}
+			b := int(prev[i])
+			p := a + b - c
+			pa, pb, pc := absint(p-a), absint(p-b), absint(p-c)
+			switch {
+			case pa <= pb && pa <= pc:
+				cur[i] += uint8(a)
+			case pb <= pc:
+				cur[i] += uint8(b)
+			default:
+				cur[i] += uint8(c)
+			}
+		}
+	default:
+		return errInvalidPNG
+	}
+	return nil
+}
+
+// sample returns the n-th sample of the row with the given bit depth.
+func (hdr *pngHeader) sample(data []byte, n int) int {
+	switch hdr.depth {
+	case 16:
+		return int(data[n*2])<<8 | int(data[n*2+1])
+	case 8:
+		return int(data[n])
+	}
+	perByte := 8 / hdr.depth
+	shift := uint(8 - hdr.depth*(n%perByte+1))
+	return int(data[n/perByte]>>shift) & (1<<uint(hdr.depth) - 1)
+}
+
+// convertRow converts the unfiltered row data to NRGBA pixels.
+func (hdr *pngHeader) convertRow(data []byte, row []uint8) {
+	maxValue := 1<<uint(hdr.depth) - 1
+	scale := func(v int) uint8 {
+		if hdr.depth == 16 {
+			return uint8(v >> 8)
+		}
+		return uint8(v * 255 / maxValue)
+	}
+
+	for x := 0; x < hdr.width; x++ {
+		var r, g, b, a uint8
+		switch hdr.colorType {
+		case 0:
+			v := hdr.sample(data, x)
+			r, g, b, a = scale(v), scale(v), scale(v), 0xff
+			if len(hdr.trns) >= 2 && v == int(binary.BigEndian.Uint16(hdr.trns)) {
+				a = 0
+			}
+		case 2:
+			vr, vg, vb := hdr.sample(data, x*3), hdr.sample(data, x*3+1), hdr.sample(data, x*3+2)
+			r, g, b, a = scale(vr), scale(vg), scale(vb), 0xff
+			if len(hdr.trns) >= 6 &&
+				vr == int(binary.BigEndian.Uint16(hdr.trns[0:])) &&
+				vg == int(binary.BigEndian.Uint16(hdr.trns[2:])) &&
+				vb == int(binary.BigEndian.Uint16(hdr.trns[4:])) {
+				a = 0
+			}
+		case 3:
+			i := hdr.sample(data, x)
+			if i < len(hdr.palette) {
+				c := hdr.palette[i]
+				r, g, b, a = c.R, c.G, c.B, c.A
+				if i < len(hdr.trns) {
+					a = hdr.trns[i]
+				}
+			}
+		case 4:
+			v := scale(hdr.sample(data, x*2))
+			r, g, b, a = v, v, v, scale(hdr.sample(data, x*2+1))
+		case 6:
+			r = scale(hdr.sample(data, x*4))
+			g = scale(hdr.sample(data, x*4+1))
+			b = scale(hdr.sample(data, x*4+2))
+			a = scale(hdr.sample(data, x*4+3))
+		}
+
+		row[x*4+0] = r
+		row[x*4+1] = g
+		row[x*4+2] = b
+		row[x*4+3] = a
+	}
+}
@@ -0,0 +1,162 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestDecodeStream(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	for i := range nrgba.Pix {
+		nrgba.Pix[i] = uint8(i * 13)
+	}
+	nrgba64 := image.NewNRGBA64(image.Rect(0, 0, 3, 2))
+	for i := range nrgba64.Pix {
+		nrgba64.Pix[i] = uint8(i * 29)
+	}
+	gray := image.NewGray(image.Rect(0, 0, 7, 2))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 17)
+	}
+	gray16 := image.NewGray16(image.Rect(0, 0, 3, 3))
+	for i := range gray16.Pix {
+		gray16.Pix[i] = uint8(i * 31)
+	}
+	rgb := New(4, 4, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	rgb.Pix[5] = 0xee
+	bw := image.NewPaletted(image.Rect(0, 0, 11, 3), color.Palette{color.Black, color.White})
+	for i := range bw.Pix {
+		bw.Pix[i] = uint8(i % 3 % 2)
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 5, 2), color.Palette{
+		color.NRGBA{0xff, 0x00, 0x00, 0xff},
+		color.NRGBA{0x00, 0xff, 0x00, 0x80},
+		color.NRGBA{0x00, 0x00, 0xff, 0x00},
+		color.NRGBA{0x10, 0x20, 0x30, 0xff},
+		color.NRGBA{0x40, 0x50, 0x60, 0xff},
+	})
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(i % 5)
+	}
+
+	td := []struct {
+		desc   string
+		img    image.Image
+		format Format
+	}{
+		{"DecodeStream PNG NRGBA", nrgba, PNG},
+		{"DecodeStream PNG NRGBA64", nrgba64, PNG},
+		{"DecodeStream PNG Gray", gray, PNG},
+		{"DecodeStream PNG Gray16", gray16, PNG},
+		{"DecodeStream PNG RGB", rgb, PNG},
+		{"DecodeStream PNG 1-bit paletted", bw, PNG},
+		{"DecodeStream PNG paletted", paletted, PNG},
+		{"DecodeStream JPEG", rgb, JPEG},
+		{"DecodeStream BMP", rgb, BMP},
+	}
+	for _, d := range td {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, d.img, d.format, PNGCompressionLevel(png.BestSpeed)); err != nil {
+			t.Fatalf("fail encoding %s: %v", d.format, err)
+		}
+		data := buf.Bytes()
+
+		want, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("fail decoding %s: %v", d.format, err)
+		}
+
+		got := image.NewNRGBA(want.Bounds())
+		rows := 0
+		cfg, err := DecodeStream(bytes.NewReader(data), func(y int, row []uint8) {
+			copy(got.Pix[y*got.Stride:], row)
+			rows++
+		})
+		if err != nil {
+			t.Errorf("test [%s] failed: %v", d.desc, err)
+			continue
+		}
+		if cfg.Width != want.Bounds().Dx() || cfg.Height != want.Bounds().Dy() || rows != cfg.Height {
+			t.Errorf("test [%s] failed: config %#v, %d rows", d.desc, cfg, rows)
+		}
+		if !compareNRGBA(got, want.(*image.NRGBA), 0) {
+			t.Errorf("test [%s] failed: %#v %#v", d.desc, got, want)
+		}
+	}
+
+	if _, err := DecodeStream(bytes.NewReader([]byte(pngSignature+"garbage")), func(int, []uint8) {}); err == nil {
+		t.Errorf("expected DecodeStream error")
+	}
+}
+
+func TestDecodeStreamChunks(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatalf("fail encoding png: %v", err)
+	}
+	data := buf.Bytes()
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+
+	chunk := func(typ string, length uint32, data []byte) []byte {
+		b := make([]byte, 8, 12+len(data))
+		binary.BigEndian.PutUint32(b[0:4], length)
+		copy(b[4:8], typ)
+		b = append(b, data...)
+		crc := crc32.ChecksumIEEE(b[4:])
+		return append(b, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	}
+	withChunk := func(c []byte) []byte {
+		var b []byte
+		b = append(b, data[:ihdrEnd]...)
+		b = append(b, c...)
+		return append(b, data[ihdrEnd:]...)
+	}
+
+	withIHDR := func(width, height uint32) []byte {
+		ihdr := append([]byte{}, data[len(pngSignature)+8:ihdrEnd-4]...)
+		binary.BigEndian.PutUint32(ihdr[0:4], width)
+		binary.BigEndian.PutUint32(ihdr[4:8], height)
+		var b []byte
+		b = append(b, pngSignature...)
+		b = append(b, chunk("IHDR", 13, ihdr)...)
+		return append(b, data[ihdrEnd:]...)
+	}
+
+	td := []struct {
+		desc string
+		data []byte
+		ok   bool
+	}{
+		{"DecodeStream large ancillary chunk", withChunk(chunk("tEXt", 1<<20, make([]byte, 1<<20))), true},
+		{"DecodeStream oversized length", withChunk(chunk("tEXt", 0xfffffff0, nil)), false},
+		{"DecodeStream truncated ancillary chunk", withChunk(chunk("tEXt", 0x7fffffff, make([]byte, 100))), false},
+		{"DecodeStream oversized PLTE", withChunk(chunk("PLTE", 769, make([]byte, 769))), false},
+		{"DecodeStream oversized tRNS", withChunk(chunk("tRNS", 0x10000000, nil)), false},
+		{"DecodeStream huge width", withIHDR(0xffffffff, 2), false},
+		{"DecodeStream huge height", withIHDR(4, 0x80000000), false},
+		{"DecodeStream too wide", withIHDR(1<<24+1, 2), false},
+		{"DecodeStream zero width", withIHDR(0, 2), false},
+	}
+	for _, d := range td {
+		got := image.NewNRGBA(src.Rect)
+		_, err := DecodeStream(bytes.NewReader(d.data), func(y int, row []uint8) {
+			copy(got.Pix[y*got.Stride:], row)
+		})
+		if d.ok {
+			if err != nil || !compareNRGBA(got, src, 0) {
+				t.Errorf("test [%s] failed: %v", d.desc, err)
+			}
+		} else if err == nil {
+			t.Errorf("test [%s] failed: expected error", d.desc)
+		}
+	}
+}
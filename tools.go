@@ -1,56 +1,400 @@
 package imaging
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"math"
 )
 
 // Crop cuts out a rectangular region with the specified bounds
 // from the image and returns the cropped image.
+// If the region doesn't overlap the image, an empty image is returned.
 func Crop(img image.Image, rect image.Rectangle) *image.NRGBA {
+	dst, err := CropBounds(img, rect)
+	if err != nil {
+		return &image.NRGBA{}
+	}
+	return dst
+}
+
+// CropBounds cuts out the intersection of the specified rectangular region
+// and the image bounds and returns the cropped image.
+// An error is returned if the intersection is empty.
+func CropBounds(img image.Image, rect image.Rectangle) (*image.NRGBA, error) {
+	srcBounds := img.Bounds()
+	r := rect.Intersect(srcBounds)
+	if r.Empty() {
+		return nil, fmt.Errorf("imaging: crop rectangle %v doesn't overlap image bounds %v", rect, srcBounds)
+	}
+
 	src := toNRGBA(img)
-	srcRect := rect.Sub(img.Bounds().Min)
-	sub := src.SubImage(srcRect)
-	return Clone(sub) // New image Bounds().Min point will be (0, 0)
+	sub := src.SubImage(r.Sub(srcBounds.Min))
+	return Clone(sub), nil // New image Bounds().Min point will be (0, 0)
+}
+
+// CropRel cuts out a rectangular region from the image using the bounds specified
+// relative to the image size and returns the cropped image. The x0, y0, x1, y1 parameters
+// are fractions of the image width and height, they are clamped to the range from 0.0 to 1.0
+// and rounded to the nearest pixel. If the resulting region is empty, an empty image is returned.
+//
+// Usage example:
+//
+//		// cut out the central area of the image, a half of its width and height
+//		dstImage := imaging.CropRel(srcImage, 0.25, 0.25, 0.75, 0.75)
+//
+func CropRel(img image.Image, x0, y0, x1, y1 float64) *image.NRGBA {
+	x0 = math.Min(math.Max(x0, 0.0), 1.0)
+	y0 = math.Min(math.Max(y0, 0.0), 1.0)
+	x1 = math.Min(math.Max(x1, 0.0), 1.0)
+	y1 = math.Min(math.Max(y1, 0.0), 1.0)
+
+	if x0 >= x1 || y0 >= y1 {
+		return &image.NRGBA{}
+	}
+
+	srcBounds := img.Bounds()
+	srcW := float64(srcBounds.Dx())
+	srcH := float64(srcBounds.Dy())
+
+	r := image.Rect(
+		int(math.Floor(x0*srcW+0.5)),
+		int(math.Floor(y0*srcH+0.5)),
+		int(math.Floor(x1*srcW+0.5)),
+		int(math.Floor(y1*srcH+0.5)),
+	).Add(srcBounds.Min)
+
+	if r.Empty() {
+		return &image.NRGBA{}
+	}
+
+	return Crop(img, r)
+}
+
+// Anchor is the anchor point used to position a rectangular region within an image.
+type Anchor int
+
+const (
+	Center Anchor = iota
+	Top
+	TopLeft
+	TopRight
+	Left
+	Right
+	Bottom
+	BottomLeft
+	BottomRight
+)
+
+// anchorPt returns the top-left point of a rectangle of the specified size
+// positioned inside the bounds b according to the anchor. If the rectangle is
+// larger than the bounds in some dimension, the point is clamped to the bounds minimum.
+func anchorPt(b image.Rectangle, width, height int, anchor Anchor) image.Point {
+	var x, y int
+
+	switch anchor {
+	case TopLeft, Left, BottomLeft:
+		x = b.Min.X
+	case TopRight, Right, BottomRight:
+		x = b.Max.X - width
+	default:
+		x = b.Min.X + b.Dx()/2 - width/2
+	}
+
+	switch anchor {
+	case TopLeft, Top, TopRight:
+		y = b.Min.Y
+	case BottomLeft, Bottom, BottomRight:
+		y = b.Max.Y - height
+	default:
+		y = b.Min.Y + b.Dy()/2 - height/2
+	}
+
+	if width > b.Dx() {
+		x = b.Min.X
+	}
+	if height > b.Dy() {
+		y = b.Min.Y
+	}
+
+	return image.Pt(x, y)
+}
+
+// CropAnchor cuts out a rectangular region with the specified size
+// from the image using the specified anchor point and returns the cropped image.
+//
+// Usage example:
+//
+//		dstImage := imaging.CropAnchor(srcImage, 100, 100, imaging.BottomRight)
+//
+func CropAnchor(img image.Image, width, height int, anchor Anchor) *image.NRGBA {
+	srcBounds := img.Bounds()
+	pt := anchorPt(srcBounds, width, height, anchor)
+	r := image.Rect(0, 0, width, height).Add(pt)
+	return Crop(img, r.Intersect(srcBounds))
 }
 
 // CropCenter cuts out a rectangular region with the specified size
 // from the center of the image and returns the cropped image.
 func CropCenter(img image.Image, width, height int) *image.NRGBA {
-	cropW, cropH := width, height
+	return CropAnchor(img, width, height, Center)
+}
 
-	srcBounds := img.Bounds()
-	srcW := srcBounds.Dx()
-	srcH := srcBounds.Dy()
-	srcMinX := srcBounds.Min.X
-	srcMinY := srcBounds.Min.Y
+// CropTop cuts out a rectangular region with the specified size
+// from the top of the image, centered horizontally, and returns the cropped image.
+func CropTop(img image.Image, width, height int) *image.NRGBA {
+	return CropAnchor(img, width, height, Top)
+}
 
-	centerX := srcMinX + srcW/2
-	centerY := srcMinY + srcH/2
+// CropBottom cuts out a rectangular region with the specified size
+// from the bottom of the image, centered horizontally, and returns the cropped image.
+func CropBottom(img image.Image, width, height int) *image.NRGBA {
+	return CropAnchor(img, width, height, Bottom)
+}
 
-	x0 := centerX - cropW/2
-	y0 := centerY - cropH/2
-	x1 := x0 + cropW
-	y1 := y0 + cropH
+// CropLeft cuts out a rectangular region with the specified size
+// from the left side of the image, centered vertically, and returns the cropped image.
+func CropLeft(img image.Image, width, height int) *image.NRGBA {
+	return CropAnchor(img, width, height, Left)
+}
 
-	return Crop(img, image.Rect(x0, y0, x1, y1))
+// CropRight cuts out a rectangular region with the specified size
+// from the right side of the image, centered vertically, and returns the cropped image.
+func CropRight(img image.Image, width, height int) *image.NRGBA {
+	return CropAnchor(img, width, height, Right)
 }
 
-func CropTop(img image.Image, width, height int) *image.NRGBA {
-	cropW, cropH := width, height
+// smartCropSearchSize is the maximum size of the downscaled image used by SmartCrop
+// to search for the crop region.
+const smartCropSearchSize = 256
+
+// SmartCrop cuts out a rectangular region with the specified size from the most
+// detailed part of the image and returns the cropped image. The region is chosen to
+// maximize the total Sobel edge energy inside it. For speed, the search is performed on
+// a downscaled copy of the image. If the image is smaller than the specified size,
+// CropCenter is used instead.
+//
+// Usage example:
+//
+//		dstImage := imaging.SmartCrop(srcImage, 100, 100)
+//
+func SmartCrop(img image.Image, width, height int) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
 
 	srcBounds := img.Bounds()
-	srcW := srcBounds.Dx()
-	srcMinX := srcBounds.Min.X
+	if srcBounds.Dx() < width || srcBounds.Dy() < height {
+		return CropCenter(img, width, height)
+	}
+
+	src := toNRGBA(img)
+	return Crop(src, smartCropRect(src, width, height))
+}
+
+// smartCropRect returns the region of the specified size with the maximum edge energy.
+// The region size must not be larger than the src image size.
+func smartCropRect(src *image.NRGBA, width, height int) image.Rectangle {
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+
+	small := src
+	if srcW > smartCropSearchSize || srcH > smartCropSearchSize {
+		small = Fit(src, smartCropSearchSize, smartCropSearchSize, Box)
+	}
+	smallW := small.Bounds().Dx()
+	smallH := small.Bounds().Dy()
+	scaleX := float64(smallW) / float64(srcW)
+	scaleY := float64(smallH) / float64(srcH)
+
+	// summed-area table of the edge energy
+	energy := sobelMagnitude(small)
+	sat := make([]float64, (smallW+1)*(smallH+1))
+	for y := 0; y < smallH; y++ {
+		rowSum := 0.0
+		for x := 0; x < smallW; x++ {
+			rowSum += energy[y*smallW+x]
+			sat[(y+1)*(smallW+1)+x+1] = sat[y*(smallW+1)+x+1] + rowSum
+		}
+	}
+
+	cropW := int(math.Max(1.0, math.Min(float64(smallW), math.Floor(float64(width)*scaleX+0.5))))
+	cropH := int(math.Max(1.0, math.Min(float64(smallH), math.Floor(float64(height)*scaleY+0.5))))
+
+	// prefer the central region among the regions with the same energy
+	centerX := float64(smallW-cropW) / 2
+	centerY := float64(smallH-cropH) / 2
+	bestX, bestY := 0, 0
+	bestSum, bestDist := -1.0, 0.0
+
+	for y := 0; y <= smallH-cropH; y++ {
+		for x := 0; x <= smallW-cropW; x++ {
+			sum := sat[(y+cropH)*(smallW+1)+x+cropW] - sat[y*(smallW+1)+x+cropW] -
+				sat[(y+cropH)*(smallW+1)+x] + sat[y*(smallW+1)+x]
+			dist := math.Abs(float64(x)-centerX) + math.Abs(float64(y)-centerY)
+			if sum > bestSum || (sum == bestSum && dist < bestDist) {
+				bestX, bestY = x, y
+				bestSum, bestDist = sum, dist
+			}
+		}
+	}
+
+	x0 := int(math.Floor(float64(bestX)/scaleX + 0.5))
+	y0 := int(math.Floor(float64(bestY)/scaleY + 0.5))
+	if x0 > srcW-width {
+		x0 = srcW - width
+	}
+	if y0 > srcH-height {
+		y0 = srcH - height
+	}
+
+	return image.Rect(x0, y0, x0+width, y0+height)
+}
+
+// AutoCrop removes the uniform borders of the image and returns the cropped image.
+// The border color is detected from the corner pixels of the image (the color shared
+// by most of the corners is used). The tolerance parameter is the maximum allowed
+// difference between a border pixel channel and the border color channel as a fraction
+// of the full channel range, it must be from 0.0 to 1.0. If the whole image is uniform,
+// a 1x1 image is returned.
+//
+// Usage example:
+//
+//		dstImage := imaging.AutoCrop(srcImage, 0.05)
+//
+func AutoCrop(img image.Image, tolerance float64) *image.NRGBA {
+	src := toNRGBA(img)
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
 
-	centerX := srcMinX + srcW/2
+	if srcW <= 0 || srcH <= 0 {
+		return &image.NRGBA{}
+	}
 
-	x0 := centerX - cropW/2
-	y0 := 0
-	x1 := x0 + cropW
-	y1 := y0 + cropH
+	tolerance = math.Min(math.Max(tolerance, 0.0), 1.0)
+	maxDiff := int(tolerance*255.0 + 0.5)
 
-	return Crop(img, image.Rect(x0, y0, x1, y1))
+	corners := []int{
+		src.PixOffset(0, 0),
+		src.PixOffset(srcW-1, 0),
+		src.PixOffset(0, srcH-1),
+		src.PixOffset(srcW-1, srcH-1),
+	}
+	bg, bgVotes := corners[0], 0
+	for _, i := range corners {
+		votes := 0
+		for _, j := range corners {
+			if pixelDiff(src.Pix[i:i+4], src.Pix[j:j+4]) <= maxDiff {
+				votes++
+			}
+		}
+		if votes > bgVotes {
+			bg, bgVotes = i, votes
+		}
+	}
+	bgColor := src.Pix[bg : bg+4]
+
+	r := contentBounds(src, func(i int) bool {
+		return pixelDiff(src.Pix[i:i+4], bgColor) <= maxDiff
+	})
+	if r.Empty() {
+		r = image.Rect(0, 0, 1, 1)
+	}
+
+	return Crop(src, r)
+}
+
+// OpaqueBounds returns the smallest rectangle containing all the pixels of the image that are
+// not fully transparent. The rectangle is in the image coordinates, so it can be passed to Crop
+// to trim the transparent padding. If the image is fully transparent, an empty rectangle is returned.
+//
+// Usage example:
+//
+//		dstImage := imaging.Crop(srcImage, imaging.OpaqueBounds(srcImage))
+//
+func OpaqueBounds(img image.Image) image.Rectangle {
+	return OpaqueBoundsThreshold(img, 0)
+}
+
+// OpaqueBoundsThreshold returns the smallest rectangle containing all the pixels of the image with
+// the alpha greater than the threshold like OpaqueBounds does. The threshold is a fraction of the
+// full alpha range, it must be from 0.0 to 1.0.
+//
+// Usage example:
+//
+//		// ignore the faint shadow around the sprite
+//		r := imaging.OpaqueBoundsThreshold(spriteImage, 0.1)
+//
+func OpaqueBoundsThreshold(img image.Image, threshold float64) image.Rectangle {
+	src := toNRGBA(img)
+	threshold = math.Min(math.Max(threshold, 0.0), 1.0)
+	maxAlpha := uint8(threshold*255.0 + 0.5)
+
+	r := contentBounds(src, func(i int) bool {
+		return src.Pix[i+3] <= maxAlpha
+	})
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r.Add(img.Bounds().Min)
+}
+
+// pixelDiff returns the maximum absolute difference between the channels of two NRGBA pixels.
+func pixelDiff(p1, p2 []uint8) int {
+	diff := 0
+	for k := 0; k < 4; k++ {
+		if d := absint(int(p1[k]) - int(p2[k])); d > diff {
+			diff = d
+		}
+	}
+	return diff
+}
+
+// contentBounds scans the image inward from each of its edges while the pixels
+// are considered background by the isBackground function (which receives a pixel offset)
+// and returns the bounds of the remaining content. If all the pixels are background,
+// an empty rectangle is returned.
+func contentBounds(src *image.NRGBA, isBackground func(i int) bool) image.Rectangle {
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+
+	isBackgroundRow := func(y, x0, x1 int) bool {
+		for x := x0; x < x1; x++ {
+			if !isBackground(src.PixOffset(x, y)) {
+				return false
+			}
+		}
+		return true
+	}
+	isBackgroundCol := func(x, y0, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			if !isBackground(src.PixOffset(x, y)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	y0, y1 := 0, srcH
+	for y0 < y1 && isBackgroundRow(y0, 0, srcW) {
+		y0++
+	}
+	if y0 == y1 {
+		return image.Rectangle{}
+	}
+	for y1 > y0 && isBackgroundRow(y1-1, 0, srcW) {
+		y1--
+	}
+
+	x0, x1 := 0, srcW
+	for x0 < x1 && isBackgroundCol(x0, y0, y1) {
+		x0++
+	}
+	for x1 > x0 && isBackgroundCol(x1-1, y0, y1) {
+		x1--
+	}
+
+	return image.Rect(x0, y0, x1, y1)
 }
 
 // Paste pastes the img image to the background image at the specified position and returns the combined image.
@@ -58,6 +402,33 @@ func Paste(background, img image.Image, pos image.Point) *image.NRGBA {
 	src := toNRGBA(img)
 	dst := Clone(background)                    // cloned image bounds start at (0, 0)
 	startPt := pos.Sub(background.Bounds().Min) // so we should translate start point
+	pasteAt(dst, src, startPt)
+	return dst
+}
+
+// ApplyRect applies the fn function to the rectangular region of the image and returns the image
+// with the region replaced by the fn result. The rectangle is clipped to the image bounds,
+// the pixels outside of it are unchanged. The fn function receives the region as a separate image
+// with the bounds starting at (0, 0), the part of its result that exceeds the region size is ignored.
+//
+// Usage example:
+//
+//		// grayscale the left half of the image
+//		b := srcImage.Bounds()
+//		dstImage := imaging.ApplyRect(srcImage, image.Rect(b.Min.X, b.Min.Y, b.Min.X+b.Dx()/2, b.Max.Y), imaging.Grayscale)
+//
+func ApplyRect(img image.Image, rect image.Rectangle, fn func(img image.Image) *image.NRGBA) *image.NRGBA {
+	r := rect.Intersect(img.Bounds())
+	if r.Empty() {
+		return Clone(img)
+	}
+	res := toNRGBA(fn(Crop(img, r)))
+	return Paste(img, res.SubImage(image.Rect(0, 0, r.Dx(), r.Dy())), r.Min)
+}
+
+// pasteAt copies the pixels of the src image to the dst image at the specified
+// position. Both images bounds must start at (0, 0).
+func pasteAt(dst, src *image.NRGBA, startPt image.Point) {
 	endPt := startPt.Add(src.Bounds().Size())
 	pasteBounds := image.Rectangle{startPt, endPt}
 
@@ -82,6 +453,433 @@ func Paste(background, img image.Image, pos image.Point) *image.NRGBA {
 			j0 += dj
 		}
 	}
+}
+
+// PasteTile fills the background image with the tile image repeated horizontally and vertically
+// and returns the combined image. The offset parameter is the position of one of the tiles,
+// the other tiles are placed around it so that the whole background is covered.
+//
+// Usage example:
+//
+//		dstImage := imaging.PasteTile(backgroundImage, patternImage, image.Pt(0, 0))
+//
+func PasteTile(background, tile image.Image, offset image.Point) *image.NRGBA {
+	src := toNRGBA(tile)
+	dst := Clone(background)
+
+	tileW := src.Bounds().Dx()
+	tileH := src.Bounds().Dy()
+	if tileW <= 0 || tileH <= 0 {
+		return dst
+	}
+
+	startPt := offset.Sub(background.Bounds().Min)
+	x0 := startPt.X % tileW
+	if x0 > 0 {
+		x0 -= tileW
+	}
+	y0 := startPt.Y % tileH
+	if y0 > 0 {
+		y0 -= tileH
+	}
+
+	dstW := dst.Bounds().Dx()
+	dstH := dst.Bounds().Dy()
+	for y := y0; y < dstH; y += tileH {
+		for x := x0; x < dstW; x += tileW {
+			pasteAt(dst, src, image.Pt(x, y))
+		}
+	}
+
+	return dst
+}
+
+// PasteOver draws the img image over the background image at the specified position
+// using the source-over alpha compositing and returns the combined image.
+// Unlike Paste, the alpha channel of each img pixel is respected,
+// unlike Overlay, no global opacity is applied.
+//
+// Usage example:
+//
+//		dstImage := imaging.PasteOver(backgroundImage, spriteImage, image.Pt(50, 50))
+//
+func PasteOver(background, img image.Image, pos image.Point) *image.NRGBA {
+	src := toNRGBA(img)
+	dst := Clone(background)                    // cloned image bounds start at (0, 0)
+	startPt := pos.Sub(background.Bounds().Min) // so we should translate start point
+	endPt := startPt.Add(src.Bounds().Size())
+	pasteBounds := image.Rectangle{startPt, endPt}
+
+	if dst.Bounds().Overlaps(pasteBounds) {
+		intersectBounds := dst.Bounds().Intersect(pasteBounds)
+
+		parallel(intersectBounds.Dy(), func(partStart, partEnd int) {
+			for y := intersectBounds.Min.Y + partStart; y < intersectBounds.Min.Y+partEnd; y++ {
+				for x := intersectBounds.Min.X; x < intersectBounds.Max.X; x++ {
+					i := y*dst.Stride + x*4
+
+					srcX := x - pasteBounds.Min.X
+					srcY := y - pasteBounds.Min.Y
+					j := srcY*src.Stride + srcX*4
+
+					blendOver(dst.Pix[i:i+4], src.Pix[j:j+4])
+				}
+			}
+		})
+	}
+
+	return dst
+}
+
+// Flatten composites the img image over the solid background color using the source-over
+// alpha compositing like PasteOver does and returns the result. If the background color is
+// opaque, the resulting image has no transparency, e.g. it can be saved as JPEG without
+// losing the alpha channel unpredictably. Fully transparent pixels become exactly the bg color.
+//
+// Usage example:
+//
+//		dstImage := imaging.Flatten(srcImage, color.White)
+//		err := imaging.Save(dstImage, "out.jpg")
+//
+func Flatten(img image.Image, bg color.Color) *image.NRGBA {
+	b := img.Bounds()
+	return PasteOver(New(b.Dx(), b.Dy(), bg), img, image.Pt(0, 0))
+}
+
+// PasteFeather draws the img image over the background image at the specified position
+// using the source-over alpha compositing and returns the combined image. The alpha channel
+// of the img image is linearly faded out towards zero within radius pixels of its borders,
+// which gives a soft edge instead of a hard rectangular one. If radius is not positive,
+// PasteFeather is equivalent to PasteOver.
+//
+// Usage example:
+//
+//		dstImage := imaging.PasteFeather(backgroundImage, logoImage, image.Pt(50, 50), 8.0)
+//
+func PasteFeather(background, img image.Image, pos image.Point, radius float64) *image.NRGBA {
+	if radius <= 0 {
+		return PasteOver(background, img, pos)
+	}
+
+	src := toNRGBA(img)
+	dst := Clone(background)                    // cloned image bounds start at (0, 0)
+	startPt := pos.Sub(background.Bounds().Min) // so we should translate start point
+	endPt := startPt.Add(src.Bounds().Size())
+	pasteBounds := image.Rectangle{startPt, endPt}
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+
+	if dst.Bounds().Overlaps(pasteBounds) {
+		intersectBounds := dst.Bounds().Intersect(pasteBounds)
+
+		parallel(intersectBounds.Dy(), func(partStart, partEnd int) {
+			pixel := make([]uint8, 4)
+			for y := intersectBounds.Min.Y + partStart; y < intersectBounds.Min.Y+partEnd; y++ {
+				for x := intersectBounds.Min.X; x < intersectBounds.Max.X; x++ {
+					i := y*dst.Stride + x*4
+
+					srcX := x - pasteBounds.Min.X
+					srcY := y - pasteBounds.Min.Y
+					j := srcY*src.Stride + srcX*4
+
+					// distance to the nearest edge of the img image
+					dist := srcX
+					if d := srcY; d < dist {
+						dist = d
+					}
+					if d := srcW - 1 - srcX; d < dist {
+						dist = d
+					}
+					if d := srcH - 1 - srcY; d < dist {
+						dist = d
+					}
+					weight := math.Min((float64(dist)+0.5)/radius, 1.0)
+
+					copy(pixel, src.Pix[j:j+4])
+					pixel[3] = clamp(float64(pixel[3]) * weight)
+					blendOver(dst.Pix[i:i+4], pixel)
+				}
+			}
+		})
+	}
+
+	return dst
+}
+
+// blendOver composes the NRGBA pixel s over the NRGBA pixel d
+// using the source-over operator and stores the result to d.
+func blendOver(d, s []uint8) {
+	switch s[3] {
+	case 0:
+		return
+	case 0xff:
+		copy(d[0:4], s[0:4])
+		return
+	}
+
+	a2 := float64(s[3]) / 255.0
+	a1 := float64(d[3]) / 255.0 * (1 - a2)
+	a := a2 + a1
+
+	d[0] = clamp((float64(s[0])*a2 + float64(d[0])*a1) / a)
+	d[1] = clamp((float64(s[1])*a2 + float64(d[1])*a1) / a)
+	d[2] = clamp((float64(s[2])*a2 + float64(d[2])*a1) / a)
+	d[3] = clamp(a * 255.0)
+}
+
+// PasteMask pastes the img image to the background image at the specified position
+// using the mask image and returns the combined image. The mask is aligned with the img image,
+// the luminance of each mask pixel (multiplied by its alpha) determines how much of the img pixel
+// replaces the background pixel: 0 keeps the background, 255 gives the img pixel.
+// The img pixels outside of the mask bounds are not pasted.
+//
+// Usage example:
+//
+//		dstImage := imaging.PasteMask(backgroundImage, srcImage, image.Pt(50, 50), maskImage)
+//
+func PasteMask(background, img image.Image, pos image.Point, mask image.Image) *image.NRGBA {
+	src := toNRGBA(img)
+	msk := toNRGBA(mask)
+	dst := Clone(background)                    // cloned image bounds start at (0, 0)
+	startPt := pos.Sub(background.Bounds().Min) // so we should translate start point
+	size := src.Bounds().Size()
+	if msk.Bounds().Dx() < size.X {
+		size.X = msk.Bounds().Dx()
+	}
+	if msk.Bounds().Dy() < size.Y {
+		size.Y = msk.Bounds().Dy()
+	}
+	endPt := startPt.Add(size)
+	pasteBounds := image.Rectangle{startPt, endPt}
+
+	if dst.Bounds().Overlaps(pasteBounds) {
+		intersectBounds := dst.Bounds().Intersect(pasteBounds)
+
+		parallel(intersectBounds.Dy(), func(partStart, partEnd int) {
+			for y := intersectBounds.Min.Y + partStart; y < intersectBounds.Min.Y+partEnd; y++ {
+				for x := intersectBounds.Min.X; x < intersectBounds.Max.X; x++ {
+					i := y*dst.Stride + x*4
+
+					srcX := x - pasteBounds.Min.X
+					srcY := y - pasteBounds.Min.Y
+					j := srcY*src.Stride + srcX*4
+					k := srcY*msk.Stride + srcX*4
+
+					lum := luma(msk.Pix[k+0], msk.Pix[k+1], msk.Pix[k+2])
+					coef := lum * float64(msk.Pix[k+3]) / (255.0 * 255.0)
+					if coef == 0 {
+						continue
+					}
+
+					for c := 0; c < 4; c++ {
+						v := float64(dst.Pix[i+c])
+						dst.Pix[i+c] = clamp(v + (float64(src.Pix[j+c])-v)*coef)
+					}
+				}
+			}
+		})
+	}
+
+	return dst
+}
+
+// HasAlpha reports whether the image has any pixels that are not fully opaque.
+// The scanning stops at the first such pixel.
+//
+// Usage example:
+//
+//		format := imaging.JPEG
+//		if imaging.HasAlpha(img) {
+//			format = imaging.PNG
+//		}
+//
+func HasAlpha(img image.Image) bool {
+	// the standard image types implement the Opaque method that scans their pixel data
+	if o, ok := img.(interface {
+		Opaque() bool
+	}); ok {
+		return !o.Opaque()
+	}
+
+	src := toNRGBA(img)
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		i := y * src.Stride
+		if !isOpaqueRow(src.Pix[i : i+width*4]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractAlpha returns the alpha channel of the image as a grayscale image.
+//
+// Usage example:
+//
+//		mask := imaging.ExtractAlpha(srcImage)
+//
+func ExtractAlpha(img image.Image) *image.Gray {
+	src := toNRGBA(img)
+	width := src.Bounds().Dx()
+	height := src.Bounds().Dy()
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			i := y * src.Stride
+			j := y * dst.Stride
+			for x := 0; x < width; x++ {
+				dst.Pix[j+x] = src.Pix[i+x*4+3]
+			}
+		}
+	})
+
+	return dst
+}
+
+// SetAlpha replaces the alpha channel of the image using the mask image and returns the result.
+// The mask is aligned with the image, the luminance of each mask pixel (multiplied by its alpha)
+// becomes the alpha of the image pixel: 0 gives a fully transparent pixel, 255 gives an opaque pixel.
+// The image pixels outside of the mask bounds become fully transparent.
+//
+// Usage example:
+//
+//		dstImage := imaging.SetAlpha(srcImage, maskImage)
+//
+func SetAlpha(img image.Image, mask image.Image) *image.NRGBA {
+	dst := Clone(img)
+	msk := toNRGBA(mask)
+	width := dst.Bounds().Dx()
+	height := dst.Bounds().Dy()
+	mskW := msk.Bounds().Dx()
+	mskH := msk.Bounds().Dy()
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				i := y*dst.Stride + x*4
+				if x >= mskW || y >= mskH {
+					dst.Pix[i+3] = 0
+					continue
+				}
+				k := y*msk.Stride + x*4
+				lum := luma(msk.Pix[k+0], msk.Pix[k+1], msk.Pix[k+2])
+				dst.Pix[i+3] = clamp(lum * float64(msk.Pix[k+3]) / 255.0)
+			}
+		}
+	})
+
+	return dst
+}
+
+// ChromaKey makes the pixels of the image that are close to the key color transparent and returns
+// the result. It's equivalent to ChromaKeySoft with the softness of half the tolerance, which gives
+// antialiased edges around the removed areas.
+//
+// Usage example:
+//
+//		// remove the green screen and put the result over a new background
+//		cutout := imaging.ChromaKey(srcImage, color.NRGBA{0, 255, 0, 255}, 0.3)
+//		dstImage := imaging.PasteOver(backgroundImage, cutout, image.Pt(0, 0))
+//
+func ChromaKey(img image.Image, key color.Color, tolerance float64) *image.NRGBA {
+	return ChromaKeySoft(img, key, tolerance, tolerance/2)
+}
+
+// ChromaKeySoft makes the pixels of the image that are close to the key color transparent and
+// returns the result. The distance between colors is the Euclidean distance in the RGB space
+// as a fraction of the maximum distance (from 0.0 to 1.0). Pixels within the tolerance distance
+// from the key become fully transparent. The alpha of pixels within the next softness band
+// is scaled linearly from 0 to its original value, other pixels are unchanged.
+//
+// Usage example:
+//
+//		dstImage := imaging.ChromaKeySoft(srcImage, color.White, 0.05, 0.1)
+//
+func ChromaKeySoft(img image.Image, key color.Color, tolerance, softness float64) *image.NRGBA {
+	k := color.NRGBAModel.Convert(key).(color.NRGBA)
+	maxDist := math.Sqrt(3) * 255.0
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		dr := float64(c.R) - float64(k.R)
+		dg := float64(c.G) - float64(k.G)
+		db := float64(c.B) - float64(k.B)
+		d := math.Sqrt(dr*dr+dg*dg+db*db) / maxDist
+		switch {
+		case d <= tolerance:
+			c.A = 0
+		case d < tolerance+softness:
+			c.A = clamp(float64(c.A) * (d - tolerance) / softness)
+		}
+		return c
+	})
+}
+
+// RoundCorners makes the corners of the image transparent so that only the rounded rectangle
+// with the specified corner radius (in pixels) remains visible and returns the result.
+// The radius is limited to half of the smaller image dimension. The corner edges are antialiased.
+//
+// Usage example:
+//
+//		dstImage := imaging.RoundCorners(srcImage, 16)
+//
+func RoundCorners(img image.Image, radius int) *image.NRGBA {
+	b := img.Bounds()
+	halfW, halfH := float64(b.Dx())/2.0, float64(b.Dy())/2.0
+	r := math.Max(math.Min(float64(radius), math.Min(halfW, halfH)), 0.0)
+
+	return maskCoverage(img, func(x, y float64) float64 {
+		// signed distance from the rounded rectangle border, negative inside
+		qx := math.Abs(x) - (halfW - r)
+		qy := math.Abs(y) - (halfH - r)
+		return math.Hypot(math.Max(qx, 0), math.Max(qy, 0)) + math.Min(math.Max(qx, qy), 0) - r
+	})
+}
+
+// Ellipse makes the pixels outside of the ellipse inscribed in the image transparent and returns
+// the result. For square images the circle is kept. The ellipse edge is antialiased.
+//
+// Usage example:
+//
+//		avatar := imaging.Ellipse(imaging.Thumbnail(srcImage, 64, 64, imaging.Lanczos))
+//
+func Ellipse(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	a2 := math.Pow(float64(b.Dx())/2.0, 2)
+	b2 := math.Pow(float64(b.Dy())/2.0, 2)
+
+	return maskCoverage(img, func(x, y float64) float64 {
+		// approximate signed distance from the ellipse border, negative inside
+		f := x*x/a2 + y*y/b2 - 1.0
+		g := math.Hypot(2.0*x/a2, 2.0*y/b2)
+		if g == 0 {
+			return math.Inf(-1)
+		}
+		return f / g
+	})
+}
+
+// maskCoverage scales the alpha channel of the image pixels by the part of each pixel that lies
+// inside the shape. The dist function returns the signed distance from the shape border to the
+// point with the coordinates relative to the image center, negative inside the shape.
+func maskCoverage(img image.Image, dist func(x, y float64) float64) *image.NRGBA {
+	dst := Clone(img)
+	width := dst.Bounds().Dx()
+	height := dst.Bounds().Dy()
+	halfW, halfH := float64(width)/2.0, float64(height)/2.0
+
+	parallel(height, func(partStart, partEnd int) {
+		for y := partStart; y < partEnd; y++ {
+			for x := 0; x < width; x++ {
+				coverage := 0.5 - dist(float64(x)+0.5-halfW, float64(y)+0.5-halfH)
+				if coverage >= 1 {
+					continue
+				}
+				i := y*dst.Stride + x*4 + 3
+				dst.Pix[i] = clamp(float64(dst.Pix[i]) * math.Max(coverage, 0))
+			}
+		}
+	})
 
 	return dst
 }
@@ -103,6 +901,158 @@ func PasteCenter(background, img image.Image) *image.NRGBA {
 	return Paste(background, img, image.Pt(x0, y0))
 }
 
+// AddBorder returns a new image enlarged by the border of the specified width on all sides,
+// filled with the col color, with the img image in the center.
+//
+// Usage example:
+//
+//		dstImage := imaging.AddBorder(srcImage, 10, color.White)
+//
+func AddBorder(img image.Image, width int, col color.Color) *image.NRGBA {
+	return AddBorderXY(img, width, width, width, width, col)
+}
+
+// AddBorderXY returns a new image enlarged by the borders of the specified widths on the top,
+// right, bottom and left sides, filled with the col color. Negative widths are treated as zero.
+//
+// Usage example:
+//
+//		// a passe-partout with the wider bottom margin
+//		dstImage := imaging.AddBorderXY(srcImage, 20, 20, 60, 20, color.White)
+//
+func AddBorderXY(img image.Image, top, right, bottom, left int, col color.Color) *image.NRGBA {
+	top, right, bottom, left = maxint(top, 0), maxint(right, 0), maxint(bottom, 0), maxint(left, 0)
+	src := toNRGBA(img)
+	size := src.Bounds().Size()
+	dst := New(size.X+left+right, size.Y+top+bottom, col)
+	pasteAt(dst, src, image.Pt(left, top))
+	return dst
+}
+
+// Pad resizes the canvas of the image to the specified width and height without scaling it
+// and returns the result. The img image is positioned on the canvas according to the anchor,
+// the remaining area is filled with the col color. If the canvas is smaller than the image
+// in some dimension, the image is cropped in that dimension using the same anchor.
+//
+// Usage example:
+//
+//		// letterbox the image into the 1920x1080 frame
+//		dstImage := imaging.Pad(srcImage, 1920, 1080, color.Black, imaging.Center)
+//
+func Pad(img image.Image, width, height int, col color.Color, anchor Anchor) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	src := CropAnchor(img, width, height, anchor)
+	dst := New(width, height, col)
+	pasteAt(dst, src, anchorPt(dst.Bounds(), src.Bounds().Dx(), src.Bounds().Dy(), anchor))
+	return dst
+}
+
+// Montage arranges the images in a grid with the specified number of columns separated by gap
+// pixels on the bg background and returns the combined image. Each column is as wide as its widest
+// image and each row is as tall as its tallest image, the images are centered in their cells.
+// If cols is not positive, all the images are placed in a single row.
+//
+// Usage example:
+//
+//		// a contact sheet of thumbnails, 5 per row
+//		var thumbs []image.Image
+//		for _, img := range images {
+//			thumbs = append(thumbs, imaging.Thumbnail(img, 100, 100, imaging.Lanczos))
+//		}
+//		dstImage := imaging.Montage(thumbs, 5, 4, color.White)
+//
+func Montage(images []image.Image, cols int, gap int, bg color.Color) *image.NRGBA {
+	if len(images) == 0 {
+		return &image.NRGBA{}
+	}
+	if cols <= 0 || cols > len(images) {
+		cols = len(images)
+	}
+	gap = maxint(gap, 0)
+	rows := (len(images) + cols - 1) / cols
+
+	colW := make([]int, cols)
+	rowH := make([]int, rows)
+	for i, img := range images {
+		size := img.Bounds().Size()
+		colW[i%cols] = maxint(colW[i%cols], size.X)
+		rowH[i/cols] = maxint(rowH[i/cols], size.Y)
+	}
+
+	// cell positions
+	colX := make([]int, cols+1)
+	for c, w := range colW {
+		colX[c+1] = colX[c] + w + gap
+	}
+	rowY := make([]int, rows+1)
+	for r, h := range rowH {
+		rowY[r+1] = rowY[r] + h + gap
+	}
+
+	dst := New(colX[cols]-gap, rowY[rows]-gap, bg)
+	for i, img := range images {
+		c, r := i%cols, i/cols
+		cell := image.Rect(colX[c], rowY[r], colX[c]+colW[c], rowY[r]+rowH[r])
+		src := toNRGBA(img)
+		pasteAt(dst, src, anchorPt(cell, src.Bounds().Dx(), src.Bounds().Dy(), Center))
+	}
+
+	return dst
+}
+
+// AppendH places the images side by side from left to right, aligned to the top, and returns
+// the combined image. The area not covered by the images is transparent.
+//
+// Usage example:
+//
+//		// before and after
+//		dstImage := imaging.AppendH(srcImage, imaging.Sharpen(srcImage, 1.5))
+//
+func AppendH(imgs ...image.Image) *image.NRGBA {
+	width, height := 0, 0
+	for _, img := range imgs {
+		size := img.Bounds().Size()
+		width += size.X
+		height = maxint(height, size.Y)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	x := 0
+	for _, img := range imgs {
+		src := toNRGBA(img)
+		pasteAt(dst, src, image.Pt(x, 0))
+		x += src.Bounds().Dx()
+	}
+	return dst
+}
+
+// AppendV stacks the images from top to bottom, aligned to the left, and returns
+// the combined image. The area not covered by the images is transparent.
+//
+// Usage example:
+//
+//		dstImage := imaging.AppendV(frame1, frame2, frame3)
+//
+func AppendV(imgs ...image.Image) *image.NRGBA {
+	width, height := 0, 0
+	for _, img := range imgs {
+		size := img.Bounds().Size()
+		width = maxint(width, size.X)
+		height += size.Y
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for _, img := range imgs {
+		src := toNRGBA(img)
+		pasteAt(dst, src, image.Pt(0, y))
+		y += src.Bounds().Dy()
+	}
+	return dst
+}
+
 // Overlay draws the img image over the background image at given position
 // and returns the combined image. Opacity parameter is the opacity of the img
 // image layer, used to compose the images, it must be from 0.0 to 1.0.
@@ -116,6 +1066,170 @@ func PasteCenter(background, img image.Image) *image.NRGBA {
 //		dstImage := imaging.Overlay(imageOne, imageTwo, image.Pt(0, 0), 0.5)
 //
 func Overlay(background, img image.Image, pos image.Point, opacity float64) *image.NRGBA {
+	return OverlayWithOp(background, img, pos, opacity, BlendNormal)
+}
+
+// BlendMode is the blending mode used to combine the colors of two image layers.
+type BlendMode int
+
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendOverlay
+	BlendDarken
+	BlendLighten
+	BlendDifference
+)
+
+// blend computes the blended value of the background (b) and source (s) color
+// channels (0..255) using the specified blending mode.
+func blend(mode BlendMode, b, s float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return b * s / 255.0
+	case BlendScreen:
+		return 255.0 - (255.0-b)*(255.0-s)/255.0
+	case BlendOverlay:
+		if b < 128 {
+			return 2 * b * s / 255.0
+		}
+		return 255.0 - 2*(255.0-b)*(255.0-s)/255.0
+	case BlendDarken:
+		return math.Min(b, s)
+	case BlendLighten:
+		return math.Max(b, s)
+	case BlendDifference:
+		return math.Abs(b - s)
+	}
+	return s
+}
+
+// isOpaqueRow reports whether all the pixels in the row of NRGBA pixel data are fully opaque.
+func isOpaqueRow(row []uint8) bool {
+	for i := 3; i < len(row); i += 4 {
+		if row[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// OverlayWithOp draws the img image over the background image at given position
+// using the specified blending mode and returns the combined image.
+// Opacity parameter is the opacity of the img image layer, it must be from 0.0 to 1.0.
+// The blended colors are composed using the same alpha weighting as Overlay,
+// where the background is transparent the source colors are used as is.
+//
+// Supported blend modes: BlendNormal, BlendMultiply, BlendScreen, BlendOverlay,
+// BlendDarken, BlendLighten, BlendDifference.
+//
+// Usage example:
+//
+//		dstImage := imaging.OverlayWithOp(backgroundImage, spriteImage, image.Pt(50, 50), 1.0, imaging.BlendMultiply)
+//
+func OverlayWithOp(background, img image.Image, pos image.Point, opacity float64, mode BlendMode) *image.NRGBA {
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0) // check: 0.0 <= opacity <= 1.0
+
+	src := toNRGBA(img)
+	dst := Clone(background)                    // cloned image bounds start at (0, 0)
+	startPt := pos.Sub(background.Bounds().Min) // so we should translate start point
+	endPt := startPt.Add(src.Bounds().Size())
+	pasteBounds := image.Rectangle{startPt, endPt}
+
+	if dst.Bounds().Overlaps(pasteBounds) {
+		intersectBounds := dst.Bounds().Intersect(pasteBounds)
+
+		// fully opaque rows of the img image drawn with normal blending
+		// and full opacity are simply copied to the background
+		fastPath := mode == BlendNormal && opacity == 1.0
+		rowSize := intersectBounds.Dx() * 4
+
+		for y := intersectBounds.Min.Y; y < intersectBounds.Max.Y; y++ {
+			if fastPath {
+				i0 := y*dst.Stride + intersectBounds.Min.X*4
+				j0 := (y-pasteBounds.Min.Y)*src.Stride + (intersectBounds.Min.X-pasteBounds.Min.X)*4
+				if isOpaqueRow(src.Pix[j0 : j0+rowSize]) {
+					copy(dst.Pix[i0:i0+rowSize], src.Pix[j0:j0+rowSize])
+					continue
+				}
+			}
+
+			for x := intersectBounds.Min.X; x < intersectBounds.Max.X; x++ {
+				i := y*dst.Stride + x*4
+
+				srcX := x - pasteBounds.Min.X
+				srcY := y - pasteBounds.Min.Y
+				j := srcY*src.Stride + srcX*4
+
+				overlayPixel(dst.Pix[i:i+4], src.Pix[j:j+4], opacity, mode)
+			}
+		}
+	}
+
+	return dst
+}
+
+// overlayPixel draws the s pixel over the d pixel with the specified opacity and blending mode
+// like OverlayWithOp does. The d pixel is modified in place. If both pixels are transparent,
+// the d pixel is left unchanged.
+func overlayPixel(d, s []uint8, opacity float64, mode BlendMode) {
+	a1 := float64(d[3])
+	a2 := float64(s[3])
+
+	coef2 := opacity * a2 / 255.0
+	coef1 := (1 - coef2) * a1 / 255.0
+	coefSum := coef1 + coef2
+	if coefSum == 0 {
+		return
+	}
+	coef1 /= coefSum
+	coef2 /= coefSum
+
+	for k := 0; k < 3; k++ {
+		b := float64(d[k])
+		c := float64(s[k])
+		if mode != BlendNormal {
+			c = (1-a1/255.0)*c + a1/255.0*blend(mode, b, c)
+		}
+		d[k] = uint8(b*coef1 + c*coef2)
+	}
+	d[3] = uint8(math.Min(a1+a2*opacity*(255.0-a1)/255.0, 255.0))
+}
+
+// Tint draws the solid col color over the img image with the specified opacity
+// and returns the result. It's equivalent to the Overlay of the solid color image
+// of the same size, but no such image is created. Opacity must be from 0.0 to 1.0.
+//
+// Usage example:
+//
+//		// darken the image before drawing the text over it
+//		dstImage := imaging.Tint(srcImage, color.Black, 0.4)
+//
+func Tint(img image.Image, col color.Color, opacity float64) *image.NRGBA {
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0) // check: 0.0 <= opacity <= 1.0
+
+	c2 := color.NRGBAModel.Convert(col).(color.NRGBA)
+	s := []uint8{c2.R, c2.G, c2.B, c2.A}
+
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		d := []uint8{c.R, c.G, c.B, c.A}
+		overlayPixel(d, s, opacity, BlendNormal)
+		return color.NRGBA{d[0], d[1], d[2], d[3]}
+	})
+}
+
+// OverlayLinear draws the img image over the background image at given position
+// and returns the combined image. It works like Overlay, but the color channels are
+// converted to linear light before blending and back to sRGB afterwards. This avoids
+// the dark fringes around semi-transparent edges produced by blending in sRGB space.
+// Opacity parameter is the opacity of the img image layer, it must be from 0.0 to 1.0.
+//
+// Usage example:
+//
+//		dstImage := imaging.OverlayLinear(backgroundImage, spriteImage, image.Pt(50, 50), 1.0)
+//
+func OverlayLinear(background, img image.Image, pos image.Point, opacity float64) *image.NRGBA {
 	opacity = math.Min(math.Max(opacity, 0.0), 1.0) // check: 0.0 <= opacity <= 1.0
 
 	src := toNRGBA(img)
@@ -144,9 +1258,10 @@ func Overlay(background, img image.Image, pos image.Point, opacity float64) *ima
 				coef1 /= coefSum
 				coef2 /= coefSum
 
-				dst.Pix[i+0] = uint8(float64(dst.Pix[i+0])*coef1 + float64(src.Pix[j+0])*coef2)
-				dst.Pix[i+1] = uint8(float64(dst.Pix[i+1])*coef1 + float64(src.Pix[j+1])*coef2)
-				dst.Pix[i+2] = uint8(float64(dst.Pix[i+2])*coef1 + float64(src.Pix[j+2])*coef2)
+				for k := 0; k < 3; k++ {
+					v := srgbToLinear(dst.Pix[i+k])*coef1 + srgbToLinear(src.Pix[j+k])*coef2
+					dst.Pix[i+k] = clamp(linearToSRGB(v))
+				}
 				dst.Pix[i+3] = uint8(math.Min(a1+a2*opacity*(255.0-a1)/255.0, 255.0))
 			}
 		}
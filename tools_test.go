@@ -1,7 +1,9 @@
 package imaging
 
 import (
+	"bytes"
 	"image"
+	"image/color"
 	"testing"
 )
 
@@ -42,6 +44,41 @@ func TestCrop(t *testing.T) {
 	}
 }
 
+func TestCropBounds(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+			0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+		},
+	}
+
+	got, err := CropBounds(src, image.Rect(0, -5, 5, 0))
+	if err != nil {
+		t.Errorf("test [CropBounds partial] failed: %v", err)
+	} else {
+		want := &image.NRGBA{
+			Rect:   image.Rect(0, 0, 1, 1),
+			Stride: 1 * 4,
+			Pix:    []uint8{0xcc, 0xdd, 0xee, 0xff},
+		}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [CropBounds partial] failed: %#v", got)
+		}
+	}
+
+	_, err = CropBounds(src, image.Rect(1, 1, 3, 3))
+	if err == nil {
+		t.Errorf("test [CropBounds outside] failed: expected error")
+	}
+
+	got = Crop(src, image.Rect(1, 1, 3, 3))
+	if !compareNRGBA(got, &image.NRGBA{}, 0) {
+		t.Errorf("test [Crop outside] failed: %#v", got)
+	}
+}
+
 func TestCropCenter(t *testing.T) {
 	td := []struct {
 		desc string
@@ -62,68 +99,1323 @@ func TestCropCenter(t *testing.T) {
 			},
 			2, 1,
 			&image.NRGBA{
-				Rect:   image.Rect(0, 0, 2, 1),
-				Stride: 2 * 4,
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := CropCenter(d.src, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestCropRel(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+			0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+		},
+	}
+	td := []struct {
+		desc           string
+		x0, y0, x1, y1 float64
+		want           *image.NRGBA
+	}{
+		{
+			"CropRel 4x2 0.25 0.0 0.75 0.5",
+			0.25, 0.0, 0.75, 0.5,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b},
+			},
+		},
+		{
+			"CropRel 4x2 0.7 0.6 2.0 2.0",
+			0.7, 0.6, 2.0, 2.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x1c, 0x1d, 0x1e, 0x1f},
+			},
+		},
+		{
+			"CropRel 4x2 0.5 0.5 0.4 1.0",
+			0.5, 0.5, 0.4, 1.0,
+			&image.NRGBA{},
+		},
+		{
+			"CropRel 4x2 0.1 0.0 0.2 1.0",
+			0.1, 0.0, 0.2, 1.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x00, 0x01, 0x02, 0x03, 0x10, 0x11, 0x12, 0x13},
+			},
+		},
+		{
+			"CropRel 4x2 0.0 0.0 0.1 1.0",
+			0.0, 0.0, 0.1, 1.0,
+			&image.NRGBA{},
+		},
+	}
+	for _, d := range td {
+		got := CropRel(src, d.x0, d.y0, d.x1, d.y1)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestCropAnchor(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+			0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+			0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+		},
+	}
+	td := []struct {
+		desc   string
+		w, h   int
+		anchor Anchor
+		want   *image.NRGBA
+	}{
+		{
+			"CropAnchor 3x3 1x1 TopLeft",
+			1, 1,
+			TopLeft,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03,
+				},
+			},
+		},
+		{
+			"CropAnchor 3x3 2x2 BottomRight",
+			2, 2,
+			BottomRight,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+		},
+		{
+			"CropAnchor 3x3 1x1 Top",
+			1, 1,
+			Top,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x04, 0x05, 0x06, 0x07,
+				},
+			},
+		},
+		{
+			"CropAnchor 3x3 1x2 BottomLeft",
+			1, 2,
+			BottomLeft,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x10, 0x11, 0x12, 0x13,
+					0x20, 0x21, 0x22, 0x23,
+				},
+			},
+		},
+		{
+			"CropAnchor 3x3 5x1 TopRight",
+			5, 1,
+			TopRight,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+				},
+			},
+		},
+		{
+			"CropAnchor 3x3 1x1 Center",
+			1, 1,
+			Center,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x14, 0x15, 0x16, 0x17,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := CropAnchor(src, d.w, d.h, d.anchor)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestCropBottom(t *testing.T) {
+	td := []struct {
+		desc string
+		src  image.Image
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"CropBottom 3x3 1x2",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			1, 2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x14, 0x15, 0x16, 0x17,
+					0x24, 0x25, 0x26, 0x27,
+				},
+			},
+		},
+		{
+			"CropBottom 3x3 4x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			4, 1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := CropBottom(d.src, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestCropLeft(t *testing.T) {
+	td := []struct {
+		desc string
+		src  image.Image
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"CropLeft 3x3 2x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			2, 1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+				},
+			},
+		},
+		{
+			"CropLeft 3x3 1x4",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			1, 4,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 3),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03,
+					0x10, 0x11, 0x12, 0x13,
+					0x20, 0x21, 0x22, 0x23,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := CropLeft(d.src, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestCropRight(t *testing.T) {
+	td := []struct {
+		desc string
+		src  image.Image
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"CropRight 3x3 2x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			2, 1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+				},
+			},
+		},
+		{
+			"CropRight 3x3 4x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 2, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+					0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b,
+				},
+			},
+			4, 1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := CropRight(d.src, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestSmartCrop(t *testing.T) {
+	src := New(40, 30, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	detail := New(4, 4, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	src = Paste(src, detail, image.Pt(32, 2))
+
+	td := []struct {
+		desc string
+		src  image.Image
+		w, h int
+		want *image.NRGBA
+	}{
+		{
+			"SmartCrop 40x30 10x10 detail",
+			src,
+			10, 10,
+			Crop(src, image.Rect(27, 1, 37, 11)),
+		},
+		{
+			"SmartCrop 40x30 10x10 uniform",
+			New(40, 30, color.NRGBA{0x10, 0x20, 0x30, 0xff}),
+			10, 10,
+			New(10, 10, color.NRGBA{0x10, 0x20, 0x30, 0xff}),
+		},
+		{
+			"SmartCrop 40x30 50x10 fallback",
+			src,
+			50, 10,
+			CropCenter(src, 50, 10),
+		},
+	}
+	for _, d := range td {
+		got := SmartCrop(d.src, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	large := New(1000, 500, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	large = Paste(large, New(40, 40, color.NRGBA{0x00, 0x00, 0x00, 0xff}), image.Pt(100, 400))
+	got := SmartCrop(large, 200, 200)
+	if got.Bounds().Dx() != 200 || got.Bounds().Dy() != 200 {
+		t.Errorf("test [SmartCrop 1000x500 200x200] failed: %v", got.Bounds())
+	}
+	if AutoCrop(got, 0).Bounds().Size() != image.Pt(40, 40) {
+		t.Errorf("test [SmartCrop 1000x500 200x200] failed: detail not found")
+	}
+}
+
+func TestAutoCrop(t *testing.T) {
+	td := []struct {
+		desc string
+		src  image.Image
+		tol  float64
+		want *image.NRGBA
+	}{
+		{
+			"AutoCrop 4x3 0.0",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 3, 2),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+			0.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"AutoCrop 3x3 0.1",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xf0, 0xf0, 0xf0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0x80, 0x80, 0x80, 0xff, 0xf8, 0xf8, 0xf8, 0xff,
+					0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+			0.1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x80, 0x80, 0x80, 0xff},
+			},
+		},
+		{
+			"AutoCrop 2x2 uniform",
+			New(2, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff}),
+			0.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff},
+			},
+		},
+	}
+	for _, d := range td {
+		got := AutoCrop(d.src, d.tol)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestPaste(t *testing.T) {
+	td := []struct {
+		desc string
+		src1 image.Image
+		src2 image.Image
+		p    image.Point
+		want *image.NRGBA
+	}{
+		{
+			"Paste 2x3 2x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(1, 1, 3, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+				},
+			},
+			image.Pt(-1, 0),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 3),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := Paste(d.src1, d.src2, d.p)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestPasteTile(t *testing.T) {
+	bg := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix:    make([]uint8, 3*2*4),
+	}
+	tile := &image.NRGBA{
+		Rect:   image.Rect(1, 1, 3, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		},
+	}
+	td := []struct {
+		desc   string
+		offset image.Point
+		want   *image.NRGBA
+	}{
+		{
+			"PasteTile 3x2 2x1 (-1, -1)",
+			image.Pt(-1, -1),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04,
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04,
+				},
+			},
+		},
+		{
+			"PasteTile 3x2 2x1 (0, 5)",
+			image.Pt(0, 5),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+					0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+				},
+			},
+		},
+		{
+			"PasteTile 3x2 2x1 (-4, 0)",
+			image.Pt(-4, 0),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+					0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := PasteTile(bg, tile, d.offset)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0xff, 0x00, 0x00, 0x80, 0x10, 0x20, 0x30, 0x00, 0x11, 0x22, 0x33, 0xff},
+	}
+	td := []struct {
+		desc string
+		src  image.Image
+		bg   color.Color
+		want *image.NRGBA
+	}{
+		{
+			"Flatten 3x1 blue",
+			src,
+			color.NRGBA{0x00, 0x00, 0xff, 0xff},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0x80, 0x00, 0x7f, 0xff, 0x00, 0x00, 0xff, 0xff, 0x11, 0x22, 0x33, 0xff},
+			},
+		},
+		{
+			"Flatten 3x1 white",
+			src,
+			color.White,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xff, 0x7f, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0x11, 0x22, 0x33, 0xff},
+			},
+		},
+		{
+			"Flatten 0x0",
+			&image.NRGBA{},
+			color.White,
+			&image.NRGBA{Rect: image.Rect(0, 0, 0, 0)},
+		},
+	}
+	for _, d := range td {
+		got := Flatten(d.src, d.bg)
+		if !compareNRGBA(got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestChromaKey(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 4, 0),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			0x00, 0xff, 0x00, 0xff, 0x00, 0xc8, 0x00, 0xff, 0x00, 0xe6, 0x00, 0x80,
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x80,
+		},
+	}
+	green := color.NRGBA{0x00, 0xff, 0x00, 0xff}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want []uint8
+	}{
+		{
+			"ChromaKey",
+			ChromaKey(src, green, 0.1),
+			[]uint8{0x00, 0x7d, 0x00, 0xff, 0x80},
+		},
+		{
+			"ChromaKeySoft 0.1 0.1",
+			ChromaKeySoft(src, green, 0.1, 0.1),
+			[]uint8{0x00, 0x3f, 0x00, 0xff, 0x80},
+		},
+		{
+			"ChromaKeySoft 0.1 0",
+			ChromaKeySoft(src, green, 0.1, 0),
+			[]uint8{0x00, 0xff, 0x00, 0xff, 0x80},
+		},
+		{
+			"ChromaKeySoft 0 0",
+			ChromaKeySoft(src, green, 0, 0),
+			[]uint8{0x00, 0xff, 0x80, 0xff, 0x80},
+		},
+	}
+	for _, d := range td {
+		want := Clone(src)
+		for x, a := range d.want {
+			want.Pix[x*4+3] = a
+		}
+		if !compareNRGBA(d.got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestRoundCorners(t *testing.T) {
+	src := New(5, 4, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	src.Rect = image.Rect(-1, -1, 4, 3)
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want []uint8
+	}{
+		{
+			"RoundCorners 0",
+			RoundCorners(src, 0),
+			[]uint8{
+				0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff,
+			},
+		},
+		{
+			"RoundCorners 1",
+			RoundCorners(src, 1),
+			[]uint8{
+				0xca, 0xff, 0xff, 0xff, 0xca,
+				0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff,
+				0xca, 0xff, 0xff, 0xff, 0xca,
+			},
+		},
+		{
+			"RoundCorners 2",
+			RoundCorners(src, 2),
+			[]uint8{
+				0x61, 0xea, 0xff, 0xea, 0x61,
+				0xea, 0xff, 0xff, 0xff, 0xea,
+				0xea, 0xff, 0xff, 0xff, 0xea,
+				0x61, 0xea, 0xff, 0xea, 0x61,
+			},
+		},
+		{
+			"RoundCorners 10",
+			RoundCorners(src, 10),
+			[]uint8{
+				0x61, 0xea, 0xff, 0xea, 0x61,
+				0xea, 0xff, 0xff, 0xff, 0xea,
+				0xea, 0xff, 0xff, 0xff, 0xea,
+				0x61, 0xea, 0xff, 0xea, 0x61,
+			},
+		},
+	}
+	for _, d := range td {
+		want := New(5, 4, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+		for i, a := range d.want {
+			want.Pix[i*4+3] = a
+		}
+		if !compareNRGBA(d.got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestEllipse(t *testing.T) {
+	src := New(6, 4, color.NRGBA{0x10, 0x20, 0x30, 0x80})
+	want := []uint8{
+		0x1d, 0x5d, 0x80, 0x80, 0x5d, 0x1d,
+		0x73, 0x80, 0x80, 0x80, 0x80, 0x73,
+		0x73, 0x80, 0x80, 0x80, 0x80, 0x73,
+		0x1d, 0x5d, 0x80, 0x80, 0x5d, 0x1d,
+	}
+	got := Ellipse(src)
+	for i, a := range want {
+		if got.Pix[i*4+3] != a || got.Pix[i*4] != 0x10 {
+			t.Fatalf("test [Ellipse] failed: %#v", got)
+		}
+	}
+
+	got = Ellipse(New(1, 1, color.White))
+	if got.Pix[3] != 0xff {
+		t.Errorf("test [Ellipse 1x1] failed: %#v", got)
+	}
+}
+
+func TestPasteOver(t *testing.T) {
+	td := []struct {
+		desc string
+		src1 image.Image
+		src2 image.Image
+		p    image.Point
+		want *image.NRGBA
+	}{
+		{
+			"PasteOver 2x2 2x1",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0x00,
+				},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(1, 1, 3, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0xff, 0x80, 0xff, 0xff, 0x00, 0x80,
+				},
+			},
+			image.Pt(-1, 0),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0x7f, 0x00, 0x80, 0xff, 0xff, 0xff, 0x00, 0x80,
+				},
+			},
+		},
+		{
+			"PasteOver 1x1 1x1 transparent",
+			New(1, 1, color.NRGBA{0x10, 0x20, 0x30, 0x40}),
+			New(1, 1, color.NRGBA{0xff, 0xff, 0xff, 0x00}),
+			image.Pt(0, 0),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0x40},
+			},
+		},
+	}
+	for _, d := range td {
+		got := PasteOver(d.src1, d.src2, d.p)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestPasteFeather(t *testing.T) {
+	bg := New(5, 5, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	fg := New(5, 5, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	td := []struct {
+		desc   string
+		radius float64
+		want   *image.NRGBA
+	}{
+		{
+			"PasteFeather 5x5 2.0",
+			2.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 5, 1),
+				Stride: 5 * 4,
+				Pix: []uint8{
+					0x40, 0x40, 0x40, 0xff, 0xbf, 0xbf, 0xbf, 0xff, 0xff, 0xff, 0xff, 0xff,
+					0xbf, 0xbf, 0xbf, 0xff, 0x40, 0x40, 0x40, 0xff,
+				},
+			},
+		},
+		{
+			"PasteFeather 5x5 0.0",
+			0.0,
+			New(5, 1, color.NRGBA{0xff, 0xff, 0xff, 0xff}),
+		},
+	}
+	for _, d := range td {
+		got := Crop(PasteFeather(bg, fg, image.Pt(0, 0), d.radius), image.Rect(0, 2, 5, 3))
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestPasteMask(t *testing.T) {
+	bg := New(3, 1, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	fg := New(3, 1, color.NRGBA{0xff, 0x80, 0x00, 0xff})
+	td := []struct {
+		desc string
+		mask image.Image
+		want *image.NRGBA
+	}{
+		{
+			"PasteMask 3x1 gray mask",
+			&image.Gray{
+				Rect:   image.Rect(-1, -1, 2, 0),
+				Stride: 3,
+				Pix:    []uint8{0x00, 0x80, 0xff},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x80, 0x40, 0x00, 0xff, 0xff, 0x80, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"PasteMask 3x1 small mask",
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2,
+				Pix:    []uint8{0xff, 0xff},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xff, 0x80, 0x00, 0xff, 0xff, 0x80, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := PasteMask(bg, fg, image.Pt(0, 0), d.mask)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestHasAlpha(t *testing.T) {
+	opaque := New(3, 2, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	translucent := Clone(opaque)
+	translucent.Pix[len(translucent.Pix)-1] = 0xfe
+
+	td := []struct {
+		desc string
+		src  image.Image
+		want bool
+	}{
+		{"HasAlpha opaque NRGBA", opaque, false},
+		{"HasAlpha translucent NRGBA", translucent, true},
+		{"HasAlpha sub-image", translucent.SubImage(image.Rect(0, 0, 2, 2)), false},
+		{"HasAlpha Gray", image.NewGray(image.Rect(0, 0, 2, 2)), false},
+		{"HasAlpha RGBA", image.NewRGBA(image.Rect(0, 0, 2, 2)), true},
+		{"HasAlpha Uniform", image.NewUniform(color.NRGBA{0, 0, 0, 0x80}), true},
+		{"HasAlpha empty", &image.NRGBA{}, false},
+		{"HasAlpha no Opaque method", struct{ image.Image }{translucent}, true},
+		{"HasAlpha no Opaque method opaque", struct{ image.Image }{opaque}, false},
+	}
+	for _, d := range td {
+		if got := HasAlpha(d.src); got != d.want {
+			t.Errorf("test [%s] failed: %v", d.desc, got)
+		}
+	}
+}
+
+func TestOpaqueBounds(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 2),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0x00, 0xff, 0xff, 0xff, 0x00, 0xff, 0xff, 0xff, 0x00, 0xff, 0xff, 0xff, 0x00,
+			0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x00,
+			0xff, 0xff, 0xff, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x80, 0xff, 0xff, 0xff, 0x00,
+		},
+	}
+	td := []struct {
+		desc string
+		got  image.Rectangle
+		want image.Rectangle
+	}{
+		{"OpaqueBounds", OpaqueBounds(src), image.Rect(0, 0, 2, 2)},
+		{"OpaqueBoundsThreshold 0.1", OpaqueBoundsThreshold(src, 0.1), image.Rect(1, 0, 2, 2)},
+		{"OpaqueBoundsThreshold 0.6", OpaqueBoundsThreshold(src, 0.6), image.Rect(1, 0, 2, 1)},
+		{"OpaqueBoundsThreshold 1", OpaqueBoundsThreshold(src, 1), image.Rectangle{}},
+		{"OpaqueBounds transparent", OpaqueBounds(image.NewNRGBA(image.Rect(0, 0, 3, 3))), image.Rectangle{}},
+		{"OpaqueBounds opaque", OpaqueBounds(New(3, 2, color.White)), image.Rect(0, 0, 3, 2)},
+		{"OpaqueBounds empty", OpaqueBounds(&image.NRGBA{}), image.Rectangle{}},
+	}
+	for _, d := range td {
+		if d.got != d.want {
+			t.Errorf("test [%s] failed: %v", d.desc, d.got)
+		}
+	}
+
+	got := Crop(src, OpaqueBounds(src))
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0xff,
+			0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x80,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [OpaqueBounds crop] failed: %#v", got)
+	}
+}
+
+func TestExtractAlpha(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0x01, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0xff},
+	}
+	got := ExtractAlpha(src)
+	want := &image.Gray{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3,
+		Pix:    []uint8{0x01, 0x80, 0xff},
+	}
+	if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("test [ExtractAlpha] failed: %#v", got)
+	}
+}
+
+func TestSetAlpha(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0x01, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0xff},
+	}
+	td := []struct {
+		desc string
+		mask image.Image
+		want []uint8
+	}{
+		{
+			"SetAlpha gray mask",
+			&image.Gray{
+				Rect:   image.Rect(5, 5, 7, 6),
+				Stride: 2,
+				Pix:    []uint8{0x40, 0xff},
+			},
+			[]uint8{0x10, 0x20, 0x30, 0x40, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x00},
+		},
+		{
+			"SetAlpha color mask",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix:    []uint8{0xff, 0xff, 0xff, 0x80, 0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff},
+			},
+			[]uint8{0x10, 0x20, 0x30, 0x80, 0x40, 0x50, 0x60, 0x4c, 0x70, 0x80, 0x90, 0x00},
+		},
+		{
+			"SetAlpha extracted alpha",
+			ExtractAlpha(src),
+			[]uint8{0x10, 0x20, 0x30, 0x01, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0xff},
+		},
+	}
+	for _, d := range td {
+		got := SetAlpha(src, d.mask)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: d.want}
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestAddBorder(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	c := color.NRGBA{0xaa, 0xbb, 0xcc, 0xff}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{
+			"AddBorder 1",
+			AddBorder(src, 1, c),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 4, 3),
+				Stride: 4 * 4,
+				Pix: []uint8{
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xaa, 0xbb, 0xcc, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+				},
+			},
+		},
+		{
+			"AddBorder 0",
+			AddBorder(src, 0, c),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			},
+		},
+		{
+			"AddBorderXY 0 1 2 -1",
+			AddBorderXY(src, 0, 1, 2, -1, c),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
 				Pix: []uint8{
-					0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xaa, 0xbb, 0xcc, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
 				},
 			},
 		},
 	}
 	for _, d := range td {
-		got := CropCenter(d.src, d.w, d.h)
-		want := d.want
-		if !compareNRGBA(got, want, 0) {
-			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
 		}
 	}
 }
 
-func TestPaste(t *testing.T) {
+func TestPad(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c},
+	}
+	c := color.NRGBA{0xaa, 0xbb, 0xcc, 0xff}
 	td := []struct {
 		desc string
-		src1 image.Image
-		src2 image.Image
-		p    image.Point
+		got  *image.NRGBA
 		want *image.NRGBA
 	}{
 		{
-			"Paste 2x3 2x1",
+			"Pad 3x3 Center",
+			Pad(src, 3, 3, c, Center),
 			&image.NRGBA{
-				Rect:   image.Rect(-1, -1, 1, 2),
-				Stride: 2 * 4,
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3 * 4,
 				Pix: []uint8{
-					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
-					0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
-					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
 				},
 			},
+		},
+		{
+			"Pad 4x2 BottomRight",
+			Pad(src, 4, 2, c, BottomRight),
 			&image.NRGBA{
-				Rect:   image.Rect(1, 1, 3, 2),
-				Stride: 2 * 4,
+				Rect:   image.Rect(0, 0, 4, 2),
+				Stride: 4 * 4,
 				Pix: []uint8{
-					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
 				},
 			},
-			image.Pt(-1, 0),
+		},
+		{
+			"Pad 2x2 Top",
+			Pad(src, 2, 2, c, Top),
 			&image.NRGBA{
-				Rect:   image.Rect(0, 0, 2, 3),
+				Rect:   image.Rect(0, 0, 2, 2),
 				Stride: 2 * 4,
 				Pix: []uint8{
-					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
 					0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
-					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+					0xaa, 0xbb, 0xcc, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+				},
+			},
+		},
+		{
+			"Pad 1x2 Right",
+			Pad(src, 1, 2, c, Right),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0xaa, 0xbb, 0xcc, 0xff,
+					0x09, 0x0a, 0x0b, 0x0c,
 				},
 			},
 		},
+		{
+			"Pad 0x2",
+			Pad(src, 0, 2, c, Center),
+			&image.NRGBA{},
+		},
 	}
 	for _, d := range td {
-		got := Paste(d.src1, d.src2, d.p)
-		want := d.want
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestMontage(t *testing.T) {
+	r := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	g := color.NRGBA{0x00, 0xff, 0x00, 0xff}
+	b := color.NRGBA{0x00, 0x00, 0xff, 0xff}
+	bg := color.NRGBA{0x00, 0x00, 0x00, 0xff}
+	images := []image.Image{New(1, 1, r), New(3, 1, g), New(1, 2, b)}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		rows []string
+	}{
+		{
+			"Montage 2 cols gap 1",
+			Montage(images, 2, 1, bg),
+			[]string{
+				"r.ggg",
+				".....",
+				"b....",
+				"b....",
+			},
+		},
+		{
+			"Montage 1 row gap 0",
+			Montage(images, 0, 0, bg),
+			[]string{
+				"....b",
+				"rgggb",
+			},
+		},
+		{
+			"Montage 1 col gap -1",
+			Montage(images, 1, -1, bg),
+			[]string{
+				".r.",
+				"ggg",
+				".b.",
+				".b.",
+			},
+		},
+	}
+	colors := map[byte]color.NRGBA{'r': r, 'g': g, 'b': b, '.': bg}
+	for _, d := range td {
+		want := image.NewNRGBA(image.Rect(0, 0, len(d.rows[0]), len(d.rows)))
+		for y, row := range d.rows {
+			for x := range row {
+				want.SetNRGBA(x, y, colors[row[x]])
+			}
+		}
+		if !compareNRGBA(d.got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+
+	if got := Montage(nil, 2, 1, bg); !compareNRGBA(got, &image.NRGBA{}, 0) {
+		t.Errorf("test [Montage empty] failed: %#v", got)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	r := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	g := color.NRGBA{0x00, 0xff, 0x00, 0xff}
+	b := color.NRGBA{0x00, 0x00, 0xff, 0x80}
+	imgs := []image.Image{New(1, 2, r), &image.NRGBA{}, New(2, 1, g), New(1, 1, b)}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		rows []string
+	}{
+		{
+			"AppendH",
+			AppendH(imgs...),
+			[]string{
+				"rggb",
+				"r...",
+			},
+		},
+		{
+			"AppendV",
+			AppendV(imgs...),
+			[]string{
+				"r.",
+				"r.",
+				"gg",
+				"b.",
+			},
+		},
+		{
+			"AppendH single",
+			AppendH(imgs[2]),
+			[]string{
+				"gg",
+			},
+		},
+	}
+	colors := map[byte]color.NRGBA{'r': r, 'g': g, 'b': b, '.': {}}
+	for _, d := range td {
+		want := image.NewNRGBA(image.Rect(0, 0, len(d.rows[0]), len(d.rows)))
+		for y, row := range d.rows {
+			for x := range row {
+				want.SetNRGBA(x, y, colors[row[x]])
+			}
+		}
+		if !compareNRGBA(d.got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+
+	if got := AppendV(); !compareNRGBA(got, &image.NRGBA{}, 0) {
+		t.Errorf("test [AppendV empty] failed: %#v", got)
+	}
+}
+
+func TestTint(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0xff, 0x00, 0x00, 0xff, 0x11, 0x22, 0x33, 0x80, 0x40, 0x80, 0xc0, 0x00},
+	}
+	td := []struct {
+		desc    string
+		col     color.Color
+		opacity float64
+		want    []uint8
+	}{
+		{
+			"Tint black 0.5",
+			color.Black,
+			0.5,
+			[]uint8{0x7f, 0x00, 0x00, 0xff, 0x05, 0x0b, 0x11, 0xbf, 0x00, 0x00, 0x00, 0x7f},
+		},
+		{
+			"Tint white 1",
+			color.White,
+			1,
+			[]uint8{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			"Tint transparent",
+			color.NRGBA{0x00, 0x00, 0xff, 0x00},
+			1,
+			[]uint8{0xff, 0x00, 0x00, 0xff, 0x11, 0x22, 0x33, 0x80, 0x40, 0x80, 0xc0, 0x00},
+		},
+		{
+			"Tint semi-transparent 0.8",
+			color.NRGBA{0x00, 0x00, 0xff, 0x80},
+			0.8,
+			[]uint8{0x98, 0x00, 0x66, 0xff, 0x07, 0x0e, 0xa7, 0xb2, 0x00, 0x00, 0xff, 0x66},
+		},
+	}
+	for _, d := range td {
+		got := Tint(src, d.col, d.opacity)
+		want := &image.NRGBA{Rect: image.Rect(0, 0, 3, 1), Stride: 3 * 4, Pix: d.want}
 		if !compareNRGBA(got, want, 0) {
 			t.Errorf("test [%s] failed: %#v", d.desc, got)
 		}
 	}
+
+	// the same as overlaying the solid color image
+	src = compareTestImage()
+	col := color.NRGBA{0x20, 0x40, 0x60, 0xc0}
+	got := Tint(src, col, 0.7)
+	want := Overlay(src, New(src.Bounds().Dx(), src.Bounds().Dy(), col), image.Pt(0, 0), 0.7)
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [Tint Overlay] failed")
+	}
 }
 
 func TestPasteCenter(t *testing.T) {
@@ -210,6 +1502,36 @@ func TestOverlay(t *testing.T) {
 				},
 			},
 		},
+		{
+			"Overlay 2x3 2x1 1.0 opaque",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0x60, 0x00, 0x90, 0xff, 0xff, 0x00, 0x99, 0x7f,
+					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(1, 1, 3, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x20, 0x40, 0x80, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+				},
+			},
+			image.Pt(-1, 0),
+			1.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 3),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+					0x20, 0x40, 0x80, 0xff, 0xaa, 0xbb, 0xcc, 0xff,
+					0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
 		{
 			"Overlay 2x2 2x2 0.5",
 			&image.NRGBA{
@@ -248,3 +1570,194 @@ func TestOverlay(t *testing.T) {
 		}
 	}
 }
+
+func TestOverlayLinear(t *testing.T) {
+	td := []struct {
+		desc string
+		src1 image.Image
+		src2 image.Image
+		p    image.Point
+		a    float64
+		want *image.NRGBA
+	}{
+		{
+			"OverlayLinear 2x1 1x1 1.0",
+			&image.NRGBA{
+				Rect:   image.Rect(-1, -1, 1, 0),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0xff, 0x20, 0x40, 0x80, 0xff,
+				},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0xff, 0xff, 0xff, 0x80,
+				},
+			},
+			image.Pt(-1, -1),
+			1.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0xbc, 0xbc, 0xbc, 0xff, 0x20, 0x40, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayLinear 1x1 1x1 0.0",
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x20, 0x40, 0x80, 0xff,
+				},
+			},
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0xff, 0xff, 0xff, 0xff,
+				},
+			},
+			image.Pt(0, 0),
+			0.0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix: []uint8{
+					0x20, 0x40, 0x80, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := OverlayLinear(d.src1, d.src2, d.p, d.a)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	// 50% opaque white over black must be lighter than the sRGB blend
+	bg := New(1, 1, color.NRGBA{0, 0, 0, 255})
+	fg := New(1, 1, color.NRGBA{255, 255, 255, 128})
+	linear := OverlayLinear(bg, fg, image.Pt(0, 0), 1.0)
+	srgb := Overlay(bg, fg, image.Pt(0, 0), 1.0)
+	if linear.Pix[0] <= srgb.Pix[0]+32 {
+		t.Errorf("test [OverlayLinear vs Overlay] failed: %d %d", linear.Pix[0], srgb.Pix[0])
+	}
+}
+
+func TestOverlayWithOp(t *testing.T) {
+	bg := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x40, 0x80, 0xc0, 0xff, 0x40, 0x80, 0xc0, 0x00,
+		},
+	}
+	fg := New(2, 1, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+
+	td := []struct {
+		desc string
+		mode BlendMode
+		want *image.NRGBA
+	}{
+		{
+			"OverlayWithOp 2x1 BlendNormal",
+			BlendNormal,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendMultiply",
+			BlendMultiply,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x20, 0x40, 0x60, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendScreen",
+			BlendScreen,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x9f, 0xbf, 0xdf, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendOverlay",
+			BlendOverlay,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x40, 0x80, 0xc0, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendDarken",
+			BlendDarken,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x40, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendLighten",
+			BlendLighten,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x80, 0x80, 0xc0, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+		{
+			"OverlayWithOp 2x1 BlendDifference",
+			BlendDifference,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x40, 0x00, 0x40, 0xff, 0x80, 0x80, 0x80, 0xff,
+				},
+			},
+		},
+	}
+	for _, d := range td {
+		got := OverlayWithOp(bg, fg, image.Pt(-1, -1), 1.0, d.mode)
+		want := d.want
+		if !compareNRGBA(got, want, 1) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func BenchmarkOverlayOpaque(b *testing.B) {
+	bg := New(4000, 3000, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	fg := New(4000, 3000, color.NRGBA{0x40, 0x50, 0x60, 0xff})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Overlay(bg, fg, image.Pt(0, 0), 1.0)
+	}
+}
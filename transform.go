@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"image"
+	"image/color"
+	"math"
 )
 
 // Rotate90 rotates the image 90 degrees counterclockwise and returns the transformed image.
@@ -144,7 +146,8 @@ func FlipV(img image.Image) *image.NRGBA {
 	return dst
 }
 
-// Transpose flips the image horizontally and rotates 90 degrees counter-clockwise.
+// Transpose flips the image across its main diagonal (from the top-left to the bottom-right corner).
+// It's equivalent to flipping the image horizontally and rotating 90 degrees counter-clockwise.
 func Transpose(img image.Image) *image.NRGBA {
 	src := toNRGBA(img)
 	srcW := src.Bounds().Max.X
@@ -172,7 +175,8 @@ func Transpose(img image.Image) *image.NRGBA {
 	return dst
 }
 
-// Transverse flips the image vertically and rotates 90 degrees counter-clockwise.
+// Transverse flips the image across its anti-diagonal (from the top-right to the bottom-left corner).
+// It's equivalent to flipping the image vertically and rotating 90 degrees counter-clockwise.
 func Transverse(img image.Image) *image.NRGBA {
 	src := toNRGBA(img)
 	srcW := src.Bounds().Max.X
@@ -199,3 +203,332 @@ func Transverse(img image.Image) *image.NRGBA {
 
 	return dst
 }
+
+// Rotate rotates the image counter-clockwise by the specified angle (in degrees)
+// around its center and returns the transformed image. The canvas is enlarged to fit
+// the whole rotated image, the uncovered areas are filled with the bgColor color.
+// Bilinear interpolation is used for the angles that aren't multiples of 90 degrees.
+//
+// Usage example:
+//
+//		dstImage := imaging.Rotate(srcImage, 30, color.NRGBA{0, 0, 0, 0})
+//
+func Rotate(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
+	angle = angle - math.Floor(angle/360)*360
+
+	switch angle {
+	case 0:
+		return Clone(img)
+	case 90:
+		return Rotate90(img)
+	case 180:
+		return Rotate180(img)
+	case 270:
+		return Rotate270(img)
+	}
+
+	src := toNRGBA(img)
+	srcW := float64(src.Bounds().Max.X)
+	srcH := float64(src.Bounds().Max.Y)
+
+	sin, cos := math.Sincos(math.Pi * angle / 180)
+	dstW := int(math.Ceil(math.Abs(srcW*cos) + math.Abs(srcH*sin) - 1e-9))
+	dstH := int(math.Ceil(math.Abs(srcW*sin) + math.Abs(srcH*cos) - 1e-9))
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	bg := color.NRGBAModel.Convert(bgColor).(color.NRGBA)
+
+	srcXOff := srcW/2 - 0.5
+	srcYOff := srcH/2 - 0.5
+	dstXOff := float64(dstW)/2 - 0.5
+	dstYOff := float64(dstH)/2 - 0.5
+
+	parallel(dstH, func(partStart, partEnd int) {
+		for dstY := partStart; dstY < partEnd; dstY++ {
+			for dstX := 0; dstX < dstW; dstX++ {
+				dx := float64(dstX) - dstXOff
+				dy := float64(dstY) - dstYOff
+				srcX := dx*cos - dy*sin + srcXOff
+				srcY := dx*sin + dy*cos + srcYOff
+
+				c := interpolateBilinear(src, srcX, srcY, bg)
+				i := dstY*dst.Stride + dstX*4
+				dst.Pix[i+0] = c.R
+				dst.Pix[i+1] = c.G
+				dst.Pix[i+2] = c.B
+				dst.Pix[i+3] = c.A
+			}
+		}
+	})
+
+	return dst
+}
+
+// interpolateBilinear returns the color of the src image at the point (x, y) using
+// bilinear interpolation. Pixel centers are located at integer coordinates. Samples outside
+// of the image bounds are replaced with the bg color. Colors are interpolated
+// premultiplied by alpha to avoid dark fringes around transparent areas.
+func interpolateBilinear(src *image.NRGBA, x, y float64, bg color.NRGBA) color.NRGBA {
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	fx := x - x0
+	fy := y - y0
+	ix := int(x0)
+	iy := int(y0)
+
+	srcW := src.Bounds().Max.X
+	srcH := src.Bounds().Max.Y
+	if ix < -1 || iy < -1 || ix >= srcW || iy >= srcH {
+		return bg
+	}
+
+	var r, g, b, a float64
+	for k := 0; k < 4; k++ {
+		px, py := ix+(k&1), iy+(k>>1)
+
+		w := fx
+		if k&1 == 0 {
+			w = 1 - fx
+		}
+		if k>>1 == 0 {
+			w *= 1 - fy
+		} else {
+			w *= fy
+		}
+		if w == 0 {
+			continue
+		}
+
+		var c color.NRGBA
+		if px < 0 || py < 0 || px >= srcW || py >= srcH {
+			c = bg
+		} else {
+			i := py*src.Stride + px*4
+			c = color.NRGBA{src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3]}
+		}
+
+		wa := w * float64(c.A)
+		r += float64(c.R) * wa
+		g += float64(c.G) * wa
+		b += float64(c.B) * wa
+		a += wa
+	}
+
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{clamp(r / a), clamp(g / a), clamp(b / a), clamp(a)}
+}
+
+// AutoOrient transforms the image according to the specified EXIF orientation value
+// (from 1 to 8) and returns the transformed image. Values outside of this range are
+// treated as 1 (no transformation).
+//
+// Usage example:
+//
+//		dstImage := imaging.AutoOrient(srcImage, 6)
+//
+func AutoOrient(img image.Image, orientation int) *image.NRGBA {
+	switch orientation {
+	case 2:
+		return FlipH(img)
+	case 3:
+		return Rotate180(img)
+	case 4:
+		return FlipV(img)
+	case 5:
+		return Transpose(img)
+	case 6:
+		return Rotate270(img)
+	case 7:
+		return Transverse(img)
+	case 8:
+		return Rotate90(img)
+	}
+	return Clone(img)
+}
+
+// ShearH shears the image horizontally by the specified angle (in degrees) and returns
+// the transformed image. The angle must be in range (-90, 90), it's clamped to [-89, 89].
+// Each row of the image is shifted to the right by (cy - y) * tan(angle) pixels, where
+// cy is the vertical center of the image, so a positive angle leans the image to the right.
+// The canvas is enlarged to fit the whole sheared image, the uncovered areas are filled
+// with the bgColor color. Bilinear interpolation is used.
+//
+// Usage example:
+//
+//		dstImage := imaging.ShearH(srcImage, 15, color.NRGBA{0, 0, 0, 0})
+//
+func ShearH(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
+	return shear(img, angle, true, bgColor)
+}
+
+// ShearV shears the image vertically by the specified angle (in degrees) and returns
+// the transformed image. The angle must be in range (-90, 90), it's clamped to [-89, 89].
+// Each column of the image is shifted up by (x - cx) * tan(angle) pixels, where
+// cx is the horizontal center of the image, so a positive angle raises the right side of the image.
+// The canvas is enlarged to fit the whole sheared image, the uncovered areas are filled
+// with the bgColor color. Bilinear interpolation is used.
+//
+// Usage example:
+//
+//		dstImage := imaging.ShearV(srcImage, 15, color.NRGBA{0, 0, 0, 0})
+//
+func ShearV(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
+	return shear(img, angle, false, bgColor)
+}
+
+func shear(img image.Image, angle float64, horizontal bool, bgColor color.Color) *image.NRGBA {
+	angle = math.Min(math.Max(angle, -89.0), 89.0)
+	if angle == 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	srcW := src.Bounds().Max.X
+	srcH := src.Bounds().Max.Y
+	k := math.Tan(math.Pi * angle / 180)
+
+	dstW, dstH := srcW, srcH
+	if horizontal {
+		dstW = int(math.Ceil(float64(srcW) + math.Abs(k)*float64(srcH-1) - 1e-9))
+	} else {
+		dstH = int(math.Ceil(float64(srcH) + math.Abs(k)*float64(srcW-1) - 1e-9))
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	bg := color.NRGBAModel.Convert(bgColor).(color.NRGBA)
+
+	srcXOff := float64(srcW)/2 - 0.5
+	srcYOff := float64(srcH)/2 - 0.5
+	dstXOff := float64(dstW)/2 - 0.5
+	dstYOff := float64(dstH)/2 - 0.5
+
+	parallel(dstH, func(partStart, partEnd int) {
+		for dstY := partStart; dstY < partEnd; dstY++ {
+			for dstX := 0; dstX < dstW; dstX++ {
+				dx := float64(dstX) - dstXOff
+				dy := float64(dstY) - dstYOff
+				if horizontal {
+					dx += dy * k
+				} else {
+					dy += dx * k
+				}
+
+				c := interpolateBilinear(src, dx+srcXOff, dy+srcYOff, bg)
+				i := dstY*dst.Stride + dstX*4
+				dst.Pix[i+0] = c.R
+				dst.Pix[i+1] = c.G
+				dst.Pix[i+2] = c.B
+				dst.Pix[i+3] = c.A
+			}
+		}
+	})
+
+	return dst
+}
+
+// Perspective maps the quadrilateral area of the image specified by its corners onto
+// a rectangle of the specified width and height and returns the transformed image.
+// The corners must be given in the order: top-left, top-right, bottom-right, bottom-left.
+// They are points on the image plane (pixel edges), e.g. the corners of the image bounds
+// rectangle give the whole image. The pixels mapped outside of the image are transparent.
+// Bilinear interpolation is used.
+//
+// Usage example:
+//
+//		corners := [4]image.Point{{12, 30}, {470, 8}, {490, 610}, {3, 640}}
+//		dstImage := imaging.Perspective(srcImage, corners, 480, 640)
+//
+func Perspective(img image.Image, corners [4]image.Point, width, height int) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	src := toNRGBA(img)
+	srcMin := img.Bounds().Min
+
+	// homography mapping the destination rectangle onto the source quadrilateral
+	var quad [4][2]float64
+	for i, p := range corners {
+		p = p.Sub(srcMin)
+		quad[i] = [2]float64{float64(p.X), float64(p.Y)}
+	}
+	h, ok := homography([4][2]float64{{0, 0}, {float64(width), 0}, {float64(width), float64(height)}, {0, float64(height)}}, quad)
+	if !ok {
+		return dst
+	}
+
+	parallel(height, func(partStart, partEnd int) {
+		for dstY := partStart; dstY < partEnd; dstY++ {
+			for dstX := 0; dstX < width; dstX++ {
+				u := float64(dstX) + 0.5
+				v := float64(dstY) + 0.5
+				w := h[6]*u + h[7]*v + 1
+				if w == 0 {
+					continue
+				}
+				x := (h[0]*u + h[1]*v + h[2]) / w
+				y := (h[3]*u + h[4]*v + h[5]) / w
+
+				c := interpolateBilinear(src, x-0.5, y-0.5, color.NRGBA{})
+				i := dstY*dst.Stride + dstX*4
+				dst.Pix[i+0] = c.R
+				dst.Pix[i+1] = c.G
+				dst.Pix[i+2] = c.B
+				dst.Pix[i+3] = c.A
+			}
+		}
+	})
+
+	return dst
+}
+
+// homography computes the coefficients h of the projective transformation mapping
+// the from points onto the to points:
+//
+//	x' = (h0*x + h1*y + h2) / (h6*x + h7*y + 1)
+//	y' = (h3*x + h4*y + h5) / (h6*x + h7*y + 1)
+//
+// It returns false if the transformation is degenerate.
+func homography(from, to [4][2]float64) ([8]float64, bool) {
+	// linear system of 8 equations, the last column is the right-hand side
+	var m [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := from[i][0], from[i][1]
+		u, v := to[i][0], to[i][1]
+		m[2*i] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		m[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+
+	// gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return [8]float64{}, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col] / m[col][col]
+			for k := col; k < 9; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+
+	var h [8]float64
+	for i := 0; i < 8; i++ {
+		h[i] = m[i][8] / m[i][i]
+	}
+	return h, true
+}
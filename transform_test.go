@@ -2,6 +2,7 @@ package imaging
 
 import (
 	"image"
+	"image/color"
 	"testing"
 )
 
@@ -259,3 +260,218 @@ func TestTransverse(t *testing.T) {
 		}
 	}
 }
+
+func TestOrientations(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+			0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+		},
+	}
+	td := []struct {
+		desc string
+		got  *image.NRGBA
+		want *image.NRGBA
+	}{
+		{"Transpose FlipH", Transpose(FlipH(src)), Rotate90(src)},
+		{"FlipH Transpose", FlipH(Transpose(src)), Rotate270(src)},
+		{"Transverse FlipH", Transverse(FlipH(src)), Rotate270(src)},
+		{"Transpose FlipV", Transpose(FlipV(src)), Rotate270(src)},
+		{"Rotate90 FlipH", Rotate90(FlipH(src)), Transpose(src)},
+		{"Rotate90 FlipV", Rotate90(FlipV(src)), Transverse(src)},
+		{"Transpose Transverse", Transpose(Transverse(src)), Rotate180(src)},
+		{"Transpose Transpose", Transpose(Transpose(src)), Clone(src)},
+		{"Transverse Transverse", Transverse(Transverse(src)), Clone(src)},
+	}
+	for _, d := range td {
+		if !compareNRGBA(d.got, d.want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, d.got)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+			0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+		},
+	}
+	td := []struct {
+		desc  string
+		angle float64
+		want  *image.NRGBA
+	}{
+		{"Rotate 0", 0, Clone(src)},
+		{"Rotate 90", 90, Rotate90(src)},
+		{"Rotate 180", 180, Rotate180(src)},
+		{"Rotate 270", 270, Rotate270(src)},
+		{"Rotate -90", -90, Rotate270(src)},
+		{"Rotate 450", 450, Rotate90(src)},
+	}
+	for _, d := range td {
+		got := Rotate(src, d.angle, color.Black)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	white := New(4, 4, color.White)
+	bg := color.NRGBA{0x00, 0x00, 0xff, 0xff}
+	got := Rotate(white, 45, bg)
+	if got.Bounds() != image.Rect(0, 0, 6, 6) {
+		t.Fatalf("test [Rotate 45 bounds] failed: %v", got.Bounds())
+	}
+	if c := got.NRGBAAt(0, 0); c != bg {
+		t.Errorf("test [Rotate 45 corner] failed: %v", c)
+	}
+	if c := got.NRGBAAt(2, 2); c != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("test [Rotate 45 center] failed: %v", c)
+	}
+}
+
+func TestAutoOrient(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x11, 0x22, 0x33, 0xcc, 0xdd, 0xee, 0xff,
+			0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff,
+		},
+	}
+	td := []struct {
+		desc        string
+		orientation int
+		want        *image.NRGBA
+	}{
+		{"AutoOrient 0", 0, Clone(src)},
+		{"AutoOrient 1", 1, Clone(src)},
+		{"AutoOrient 2", 2, FlipH(src)},
+		{"AutoOrient 3", 3, Rotate180(src)},
+		{"AutoOrient 4", 4, FlipV(src)},
+		{"AutoOrient 5", 5, Rotate90(FlipH(src))},
+		{"AutoOrient 6", 6, Rotate270(src)},
+		{"AutoOrient 7", 7, Rotate270(FlipH(src))},
+		{"AutoOrient 8", 8, Rotate90(src)},
+		{"AutoOrient 9", 9, Clone(src)},
+	}
+	for _, d := range td {
+		got := AutoOrient(src, d.orientation)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+}
+
+func TestShear(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x01, 0x01, 0xff, 0x02, 0x02, 0x02, 0xff, 0x03, 0x03, 0x03, 0xff,
+			0x04, 0x04, 0x04, 0xff, 0x05, 0x05, 0x05, 0xff, 0x06, 0x06, 0x06, 0xff,
+			0x07, 0x07, 0x07, 0xff, 0x08, 0x08, 0x08, 0xff, 0x09, 0x09, 0x09, 0xff,
+		},
+	}
+	bg := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+
+	got := ShearH(src, 45, bg)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 5, 3),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0x01, 0x01, 0x01, 0xff, 0x02, 0x02, 0x02, 0xff, 0x03, 0x03, 0x03, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0x04, 0x04, 0x04, 0xff, 0x05, 0x05, 0x05, 0xff, 0x06, 0x06, 0x06, 0xff, 0xff, 0x00, 0x00, 0xff,
+			0x07, 0x07, 0x07, 0xff, 0x08, 0x08, 0x08, 0xff, 0x09, 0x09, 0x09, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [ShearH 3x3 45] failed: %#v", got)
+	}
+
+	got = ShearV(src, 45, bg)
+	want = &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 5),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0x03, 0x03, 0x03, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0x02, 0x02, 0x02, 0xff, 0x06, 0x06, 0x06, 0xff,
+			0x01, 0x01, 0x01, 0xff, 0x05, 0x05, 0x05, 0xff, 0x09, 0x09, 0x09, 0xff,
+			0x04, 0x04, 0x04, 0xff, 0x08, 0x08, 0x08, 0xff, 0xff, 0x00, 0x00, 0xff,
+			0x07, 0x07, 0x07, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Errorf("test [ShearV 3x3 45] failed: %#v", got)
+	}
+
+	got = ShearH(src, 0, bg)
+	if !compareNRGBA(got, Clone(src), 0) {
+		t.Errorf("test [ShearH 3x3 0] failed: %#v", got)
+	}
+}
+
+func TestPerspective(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x01, 0x01, 0xff, 0x02, 0x02, 0x02, 0xff, 0x03, 0x03, 0x03, 0xff,
+			0x04, 0x04, 0x04, 0xff, 0x05, 0x05, 0x05, 0xff, 0x06, 0x06, 0x06, 0xff,
+		},
+	}
+	td := []struct {
+		desc    string
+		corners [4]image.Point
+		w, h    int
+		want    *image.NRGBA
+	}{
+		{
+			"Perspective identity",
+			[4]image.Point{{-1, -1}, {2, -1}, {2, 1}, {-1, 1}},
+			3, 2,
+			Clone(src),
+		},
+		{
+			"Perspective rotate 180",
+			[4]image.Point{{2, 1}, {-1, 1}, {-1, -1}, {2, -1}},
+			3, 2,
+			Rotate180(src),
+		},
+		{
+			"Perspective transpose",
+			[4]image.Point{{-1, -1}, {-1, 1}, {2, 1}, {2, -1}},
+			2, 3,
+			Transpose(src),
+		},
+		{
+			"Perspective degenerate",
+			[4]image.Point{{0, 0}, {0, 0}, {0, 0}, {0, 0}},
+			2, 2,
+			image.NewNRGBA(image.Rect(0, 0, 2, 2)),
+		},
+	}
+	for _, d := range td {
+		got := Perspective(src, d.corners, d.w, d.h)
+		want := d.want
+		if !compareNRGBA(got, want, 0) {
+			t.Errorf("test [%s] failed: %#v", d.desc, got)
+		}
+	}
+
+	// the outer pixels are blended with the transparent area outside of the image
+	corners := [4]image.Point{{-1, -1}, {2, -1}, {2, 1}, {-1, 1}}
+	got := Crop(Perspective(src, corners, 6, 4), image.Rect(1, 1, 5, 3))
+	want := Crop(Resize(src, 6, 4, Linear), image.Rect(1, 1, 5, 3))
+	if !compareNRGBA(got, want, 1) {
+		t.Errorf("test [Perspective scale 2x] failed: %#v", got)
+	}
+}
@@ -9,14 +9,37 @@ import (
 
 var parallelizationEnabled = true
 
+// maximum number of workers used by the image processing functions, 0 means GOMAXPROCS
+var maxProcs int32
+
+// SetMaxProcs limits the number of goroutines used by the image processing functions
+// (Resize, Blur, etc.) to process a single image. If n <= 0, runtime.GOMAXPROCS(0) is used,
+// which is the default. The number of goroutines never exceeds GOMAXPROCS.
+// Setting it to 1 disables the parallel processing, which can be useful when many images are
+// processed concurrently.
+//
+// Usage example:
+//
+//		imaging.SetMaxProcs(4)
+//
+func SetMaxProcs(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&maxProcs, int32(n))
+}
+
 // if GOMAXPROCS = 1: no goroutines used
-// if GOMAXPROCS > 1: spawn N=GOMAXPROCS workers in separate goroutines
+// if GOMAXPROCS > 1: spawn N=GOMAXPROCS workers in separate goroutines (limited by SetMaxProcs)
 func parallel(dataSize int, fn func(partStart, partEnd int)) {
 	numGoroutines := 1
 	partSize := dataSize
 
 	if parallelizationEnabled {
 		numProcs := runtime.GOMAXPROCS(0)
+		if m := int(atomic.LoadInt32(&maxProcs)); m > 0 && m < numProcs {
+			numProcs = m
+		}
 		if numProcs > 1 {
 			numGoroutines = numProcs
 			partSize = dataSize / (numGoroutines * 10)
@@ -61,6 +84,20 @@ func absint(i int) int {
 	return i
 }
 
+func maxint(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minint(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // clamp & round float64 to uint8 (0..255)
 func clamp(v float64) uint8 {
 	return uint8(math.Min(math.Max(v, 0.0), 255.0) + 0.5)
@@ -75,3 +112,48 @@ func clampint32(v int32) uint8 {
 	}
 	return uint8(v)
 }
+
+// sRGB to linear light lookup table, values are in range 0..1
+var srgbToLinearLUT [256]float64
+
+// linearToSRGBLUT maps linear light values quantized to 16 bits to sRGB values
+var linearToSRGBLUT [65536]uint8
+
+func init() {
+	for i := 0; i < 256; i++ {
+		v := float64(i) / 255.0
+		if v <= 0.04045 {
+			srgbToLinearLUT[i] = v / 12.92
+		} else {
+			srgbToLinearLUT[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	for i := 0; i < 65536; i++ {
+		linearToSRGBLUT[i] = clamp(linearToSRGB(float64(i) / 65535.0))
+	}
+}
+
+// convert sRGB uint8 value to linear light (0..1)
+func srgbToLinear(v uint8) float64 {
+	return srgbToLinearLUT[v]
+}
+
+// convert linear light value (0..1) to sRGB uint8 value using the lookup table
+func linearToSRGB8(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return linearToSRGBLUT[int(v*65535.0+0.5)]
+}
+
+// convert linear light value (0..1) to sRGB float64 value (0..255)
+func linearToSRGB(v float64) float64 {
+	v = math.Min(math.Max(v, 0.0), 1.0)
+	if v <= 0.0031308 {
+		return v * 12.92 * 255.0
+	}
+	return (1.055*math.Pow(v, 1.0/2.4) - 0.055) * 255.0
+}
@@ -35,6 +35,32 @@ func TestParallel(t *testing.T) {
 	}
 }
 
+func TestSetMaxProcs(t *testing.T) {
+	defer SetMaxProcs(0)
+	for _, m := range []int{-1, 0, 1, 2, 4} {
+		SetMaxProcs(m)
+		for _, n := range []int{1, 10, 100, 1000} {
+			for _, p := range []int{1, 2, 4, 8} {
+				if testParallelN(true, n, p) != true {
+					t.Errorf("test [SetMaxProcs %d %d %d] failed", m, n, p)
+				}
+			}
+		}
+	}
+
+	SetMaxProcs(1)
+	calls := 0
+	parallel(1000, func(start, end int) {
+		calls++
+		if start != 0 || end != 1000 {
+			t.Errorf("test [SetMaxProcs 1] failed: part %d-%d", start, end)
+		}
+	})
+	if calls != 1 {
+		t.Errorf("test [SetMaxProcs 1] failed: %d calls", calls)
+	}
+}
+
 func TestClamp(t *testing.T) {
 	td := []struct {
 		f float64
@@ -59,3 +85,12 @@ func TestClamp(t *testing.T) {
 		}
 	}
 }
+
+func TestSRGBLinear(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		v := clamp(linearToSRGB(srgbToLinear(uint8(i))))
+		if v != uint8(i) {
+			t.Errorf("test [srgb-linear roundtrip %d] failed: %d", i, v)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build webpenc
+// +build webpenc
+
+package imaging
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// WebP encoding requires cgo, so it's enabled only with the webpenc build tag:
+//
+//	go build -tags webpenc
+//
+func init() {
+	webpEncoder = func(w io.Writer, img image.Image) error {
+		return webp.Encode(w, img, &webp.Options{Quality: 90})
+	}
+}